@@ -0,0 +1,170 @@
+package v1
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"github.com/adamdyszy/sportsnews/internal/admin"
+	"github.com/adamdyszy/sportsnews/internal/poller"
+	"github.com/adamdyszy/sportsnews/storage"
+	"github.com/adamdyszy/sportsnews/types"
+	"github.com/go-logr/logr"
+	"github.com/gorilla/mux"
+	"net/http"
+	"strings"
+)
+
+const unauthorizedMsg = "Missing or invalid admin token"
+const jobNotFoundMsg = "Admin job not found"
+const feedNotFoundMsg = "There is no feed with given teamId"
+
+// Kinds of admin jobs
+const (
+	JobKindPollList       = "pollList"
+	JobKindPollDetails    = "pollDetails"
+	JobKindRefreshArticle = "refreshArticle"
+)
+
+func MakeSuccessAdminJob(job types.AdminJob) types.AdminJobResponse {
+	return types.AdminJobResponse{Status: "success", Data: &job}
+}
+
+func MakeErrorAdminJob(msg string) types.AdminJobResponse {
+	return types.AdminJobResponse{Status: "error", Message: msg}
+}
+
+// AdminAuthMiddleware lets through only requests with "Authorization: Bearer <token>" header
+func AdminAuthMiddleware(token string, logger logr.Logger) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			header := r.Header.Get("Authorization")
+			got := strings.TrimPrefix(header, "Bearer ")
+			// token sent without the scheme is rejected too
+			if token == "" || got == header || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				jsonEncodeErrorResponse(w, MakeErrorAdminJob(unauthorizedMsg), http.StatusUnauthorized, logger)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// startJob starts admin job and responds with it, the job can be then checked with GetAdminJobHandler
+func startJob(w http.ResponseWriter, jobs *admin.Jobs, kind string, fn admin.JobFunc, logger logr.Logger) {
+	job, err := jobs.Start(kind, fn)
+	if err != nil {
+		logger.Error(err, "Could not start admin job.")
+		jsonEncodeErrorResponse(w, MakeErrorAdminJob(internalServerErrorMsg), http.StatusInternalServerError, logger)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	err = json.NewEncoder(w).Encode(MakeSuccessAdminJob(job))
+	if err != nil {
+		logger.Error(err, failJsonEncodeMsg)
+	}
+}
+
+// checkFeed responds with not found when query parameter teamId is set, but there is no such feed
+func checkFeed(w http.ResponseWriter, r *http.Request, pl *poller.Poller, logger logr.Logger) bool {
+	teamId := r.URL.Query().Get("teamId")
+	if teamId != "" && !pl.HasFeed(teamId) {
+		jsonEncodeErrorResponse(w, MakeErrorAdminJob(feedNotFoundMsg), http.StatusNotFound, logger)
+		return false
+	}
+	return true
+}
+
+// PollListHandler starts polling news list, optional query parameter teamId limits it to single feed
+func PollListHandler(pl *poller.Poller, jobs *admin.Jobs, logger logr.Logger) http.HandlerFunc {
+	logger = logger.WithValues("handler", "PollListHandler")
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !checkFeed(w, r, pl, logger) {
+			return
+		}
+		teamId := r.URL.Query().Get("teamId")
+		startJob(w, jobs, JobKindPollList, func(ctx context.Context) (types.PollSummary, error) {
+			return pl.PollList(ctx, teamId)
+		}, logger)
+	}
+}
+
+// PollDetailsHandler starts polling details of news without them, optional query parameter teamId limits it to single feed
+func PollDetailsHandler(pl *poller.Poller, jobs *admin.Jobs, logger logr.Logger) http.HandlerFunc {
+	logger = logger.WithValues("handler", "PollDetailsHandler")
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !checkFeed(w, r, pl, logger) {
+			return
+		}
+		teamId := r.URL.Query().Get("teamId")
+		startJob(w, jobs, JobKindPollDetails, func(ctx context.Context) (types.PollSummary, error) {
+			return pl.PollDetails(ctx, teamId)
+		}, logger)
+	}
+}
+
+// RefreshArticleHandler starts fetching details of the article again, even if it already has them
+func RefreshArticleHandler(pl *poller.Poller, s storage.ArticleStorage, jobs *admin.Jobs, logger logr.Logger) http.HandlerFunc {
+	logger = logger.WithValues("handler", "RefreshArticleHandler")
+	return func(w http.ResponseWriter, r *http.Request) {
+		articleId := types.ArticleId(mux.Vars(r)["id"])
+		_, err := s.Get(r.Context(), articleId)
+		if err != nil {
+			if errors.Is(err, storage.ArticleNotFound) {
+				jsonEncodeErrorResponse(w, MakeErrorAdminJob(articleIdNotFoundMsg), http.StatusNotFound, logger)
+				return
+			}
+			logger.Error(err, failFromStorageMsg)
+			jsonEncodeErrorResponse(w, MakeErrorAdminJob(internalServerErrorMsg), http.StatusInternalServerError, logger)
+			return
+		}
+		startJob(w, jobs, JobKindRefreshArticle, func(ctx context.Context) (types.PollSummary, error) {
+			return pl.RefreshArticle(ctx, articleId)
+		}, logger)
+	}
+}
+
+// PinArticleHandler pins or unpins the article, pinned articles are never removed by retention
+func PinArticleHandler(s storage.ArticleStorage, pinned bool, logger logr.Logger) http.HandlerFunc {
+	logger = logger.WithValues("handler", "PinArticleHandler", "pinned", pinned)
+	return func(w http.ResponseWriter, r *http.Request) {
+		articleId := types.ArticleId(mux.Vars(r)["id"])
+		err := s.SetPinned(r.Context(), articleId, pinned)
+		if err != nil {
+			if errors.Is(err, storage.ArticleNotFound) {
+				jsonEncodeErrorResponse(w, MakeErrorArticleDetailed(articleIdNotFoundMsg), http.StatusNotFound, logger)
+				return
+			}
+			logger.Error(err, "Could not set pinned of article.", "articleId", articleId)
+			jsonEncodeErrorResponse(w, MakeErrorArticleDetailed(internalServerErrorMsg), http.StatusInternalServerError, logger)
+			return
+		}
+		article, err := s.Get(r.Context(), articleId)
+		if err != nil {
+			logger.Error(err, failFromStorageMsg)
+			jsonEncodeErrorResponse(w, MakeErrorArticleDetailed(internalServerErrorMsg), http.StatusInternalServerError, logger)
+			return
+		}
+		jsonEncodeSuccessResponse(w, MakeSuccessArticleDetailed(article), logger)
+	}
+}
+
+// GetAdminJobHandler returns admin job with its summary once it is finished
+func GetAdminJobHandler(jobs *admin.Jobs, logger logr.Logger) http.HandlerFunc {
+	logger = logger.WithValues("handler", "GetAdminJobHandler")
+	return func(w http.ResponseWriter, r *http.Request) {
+		job, err := jobs.Get(mux.Vars(r)["id"])
+		if err != nil {
+			if errors.Is(err, admin.JobNotFound) {
+				jsonEncodeErrorResponse(w, MakeErrorAdminJob(jobNotFoundMsg), http.StatusNotFound, logger)
+				return
+			}
+			logger.Error(err, "Could not get admin job.")
+			jsonEncodeErrorResponse(w, MakeErrorAdminJob(internalServerErrorMsg), http.StatusInternalServerError, logger)
+			return
+		}
+		jsonEncodeSuccessResponse(w, MakeSuccessAdminJob(job), logger)
+	}
+}
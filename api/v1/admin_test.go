@@ -0,0 +1,30 @@
+package v1
+
+import (
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAdminAuthMiddleware(t *testing.T) {
+	handler := AdminAuthMiddleware("secret", logr.Discard())(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	for header, code := range map[string]int{
+		"Bearer secret": http.StatusNoContent,
+		"secret":        http.StatusUnauthorized,
+		"Bearer other":  http.StatusUnauthorized,
+		"Basic secret":  http.StatusUnauthorized,
+		"":              http.StatusUnauthorized,
+	} {
+		r := httptest.NewRequest("POST", "/admin/poll/list", nil)
+		if header != "" {
+			r.Header.Set("Authorization", header)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, r)
+		assert.Equal(t, code, rec.Code, header)
+	}
+}
@@ -0,0 +1,23 @@
+package v1
+
+// Config is the api section of the config file
+type Config struct {
+	Address string `mapstructure:"address"`
+	List    struct {
+		DefaultLimit int `mapstructure:"defaultLimit"`
+		MaxLimit     int `mapstructure:"maxLimit"`
+	} `mapstructure:"list"`
+	Feeds struct {
+		Title  string `mapstructure:"title"`
+		Link   string `mapstructure:"link"`
+		Author string `mapstructure:"author"`
+		Limit  int    `mapstructure:"limit"`
+	} `mapstructure:"feeds"`
+	Stream struct {
+		KeepAliveSeconds int `mapstructure:"keepAliveSeconds"`
+	} `mapstructure:"stream"`
+	Admin struct {
+		Token       string `mapstructure:"token"`
+		HistorySize int    `mapstructure:"historySize"`
+	} `mapstructure:"admin"`
+}
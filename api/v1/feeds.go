@@ -0,0 +1,327 @@
+package v1
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"github.com/adamdyszy/sportsnews/storage"
+	"github.com/adamdyszy/sportsnews/types"
+	"github.com/go-logr/logr"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"time"
+)
+
+const failFeedEncodeMsg = "Failure during feed encoding"
+
+type rssFeed struct {
+	XMLName      xml.Name   `xml:"rss"`
+	Version      string     `xml:"version,attr"`
+	XMLNSContent string     `xml:"xmlns:content,attr"`
+	Channel      rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	LastBuildDate string    `xml:"lastBuildDate"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string        `xml:"title,omitempty"`
+	Link        string        `xml:"link,omitempty"`
+	Description string        `xml:"description,omitempty"`
+	GUID        rssGUID       `xml:"guid"`
+	PubDate     string        `xml:"pubDate"`
+	Categories  []string      `xml:"category"`
+	Enclosure   *rssEnclosure `xml:"enclosure"`
+	Content     *cdata        `xml:"content:encoded"`
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+type rssEnclosure struct {
+	URL    string `xml:"url,attr"`
+	Type   string `xml:"type,attr"`
+	Length int    `xml:"length,attr"`
+}
+
+type cdata struct {
+	Value string `xml:",cdata"`
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Author  atomAuthor  `xml:"author"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomEntry struct {
+	ID         string         `xml:"id"`
+	Title      string         `xml:"title"`
+	Updated    string         `xml:"updated"`
+	Published  string         `xml:"published"`
+	Links      []atomLink     `xml:"link"`
+	Summary    string         `xml:"summary,omitempty"`
+	Content    *atomContent   `xml:"content"`
+	Categories []atomCategory `xml:"category"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type atomContent struct {
+	Type  string `xml:"type,attr"`
+	Value string `xml:",chardata"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+// jsonFeed is feed in JSON Feed 1.1 format, see https://jsonfeed.org/version/1.1
+type jsonFeed struct {
+	Version     string         `json:"version"`
+	Title       string         `json:"title"`
+	HomePageURL string         `json:"home_page_url,omitempty"`
+	Items       []jsonFeedItem `json:"items"`
+}
+
+type jsonFeedItem struct {
+	ID            string               `json:"id"`
+	URL           string               `json:"url,omitempty"`
+	Title         string               `json:"title,omitempty"`
+	Summary       string               `json:"summary,omitempty"`
+	ContentHTML   string               `json:"content_html,omitempty"`
+	ContentText   string               `json:"content_text,omitempty"`
+	Image         string               `json:"image,omitempty"`
+	DatePublished string               `json:"date_published"`
+	Tags          []string             `json:"tags,omitempty"`
+	Attachments   []jsonFeedAttachment `json:"attachments,omitempty"`
+}
+
+type jsonFeedAttachment struct {
+	URL      string `json:"url"`
+	MimeType string `json:"mime_type"`
+}
+
+// mediaType guesses mime type of the url from its extension
+func mediaType(url string, fallback string) string {
+	t := mime.TypeByExtension(path.Ext(url))
+	if t == "" {
+		return fallback
+	}
+	return t
+}
+
+func makeRSSFeed(config Config, articles []types.Article) rssFeed {
+	feed := rssFeed{
+		Version:      "2.0",
+		XMLNSContent: "http://purl.org/rss/1.0/modules/content/",
+		Channel: rssChannel{
+			Title:         config.Feeds.Title,
+			Link:          config.Feeds.Link,
+			Description:   config.Feeds.Title,
+			LastBuildDate: time.Now().UTC().Format(time.RFC1123Z),
+			Items:         make([]rssItem, 0, len(articles)),
+		},
+	}
+	for _, a := range articles {
+		item := rssItem{
+			Title:       a.Title,
+			Link:        a.URL,
+			Description: a.Teaser,
+			GUID:        rssGUID{Value: string(a.Id)},
+			PubDate:     a.Published.UTC().Format(time.RFC1123Z),
+			Categories:  a.Type,
+		}
+		if a.ImageURL != "" {
+			item.Enclosure = &rssEnclosure{URL: a.ImageURL, Type: mediaType(a.ImageURL, "image/jpeg")}
+		}
+		if a.HasDetails && a.Content != "" {
+			item.Content = &cdata{Value: a.Content}
+		}
+		feed.Channel.Items = append(feed.Channel.Items, item)
+	}
+	return feed
+}
+
+// atomEntryID makes tag URI (RFC 4151) of the article from host of the feed link, falling back to host of article url
+func atomEntryID(config Config, a types.Article) string {
+	authority := "localhost"
+	for _, link := range []string{config.Feeds.Link, a.URL} {
+		u, err := url.Parse(link)
+		if err == nil && u.Hostname() != "" {
+			authority = u.Hostname()
+			break
+		}
+	}
+	return "tag:" + authority + "," + a.Published.UTC().Format("2006-01-02") + ":" + string(a.Id)
+}
+
+func makeAtomFeed(config Config, articles []types.Article) atomFeed {
+	author := config.Feeds.Author
+	if author == "" {
+		// feed without author is invalid unless every entry has one
+		author = config.Feeds.Title
+	}
+	feed := atomFeed{
+		ID:      config.Feeds.Link,
+		Title:   config.Feeds.Title,
+		Updated: time.Now().UTC().Format(time.RFC3339),
+		Author:  atomAuthor{Name: author},
+		Links:   []atomLink{{Href: config.Feeds.Link}},
+		Entries: make([]atomEntry, 0, len(articles)),
+	}
+	if len(articles) > 0 {
+		// articles are sorted newest first
+		feed.Updated = articles[0].Published.UTC().Format(time.RFC3339)
+	}
+	for _, a := range articles {
+		entry := atomEntry{
+			ID:        atomEntryID(config, a),
+			Title:     a.Title,
+			Updated:   a.Published.UTC().Format(time.RFC3339),
+			Published: a.Published.UTC().Format(time.RFC3339),
+			Summary:   a.Teaser,
+		}
+		if !a.UpdatedAt.IsZero() {
+			// articles stored before updatedAt was kept do not have it
+			entry.Updated = a.UpdatedAt.UTC().Format(time.RFC3339)
+		}
+		if a.URL != "" {
+			entry.Links = append(entry.Links, atomLink{Href: a.URL, Rel: "alternate"})
+		}
+		if a.ImageURL != "" {
+			entry.Links = append(entry.Links, atomLink{Href: a.ImageURL, Rel: "enclosure", Type: mediaType(a.ImageURL, "image/jpeg")})
+		}
+		if a.HasDetails && a.Content != "" {
+			entry.Content = &atomContent{Type: "html", Value: a.Content}
+		}
+		for _, t := range a.Type {
+			entry.Categories = append(entry.Categories, atomCategory{Term: t})
+		}
+		feed.Entries = append(feed.Entries, entry)
+	}
+	return feed
+}
+
+func makeJSONFeed(config Config, articles []types.Article) jsonFeed {
+	feed := jsonFeed{
+		Version:     "https://jsonfeed.org/version/1.1",
+		Title:       config.Feeds.Title,
+		HomePageURL: config.Feeds.Link,
+		Items:       make([]jsonFeedItem, 0, len(articles)),
+	}
+	for _, a := range articles {
+		item := jsonFeedItem{
+			ID:            string(a.Id),
+			URL:           a.URL,
+			Title:         a.Title,
+			Summary:       a.Teaser,
+			Image:         a.ImageURL,
+			DatePublished: a.Published.UTC().Format(time.RFC3339),
+			Tags:          a.Type,
+		}
+		if a.HasDetails && a.Content != "" {
+			item.ContentHTML = a.Content
+		} else {
+			// one of content fields is required by the spec
+			item.ContentText = a.Teaser
+		}
+		if a.VideoURL != "" {
+			item.Attachments = append(item.Attachments, jsonFeedAttachment{URL: a.VideoURL, MimeType: mediaType(a.VideoURL, "video/mp4")})
+		}
+		feed.Items = append(feed.Items, item)
+	}
+	return feed
+}
+
+/*
+feedHandler serves feed encoded by encode from newest articles.
+
+Query parameters teamId and type (and other filters of GetAllArticlesHandler) narrow the articles.
+*/
+func feedHandler(
+	s storage.ArticleStorage,
+	config Config,
+	logger logr.Logger,
+	contentType string,
+	encode func(w http.ResponseWriter, articles []types.Article) error,
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		filter, err := parseListedArticleFilter(r)
+		if err != nil {
+			http.Error(w, invalidFilterMsg, http.StatusBadRequest)
+			return
+		}
+		limit := config.Feeds.Limit
+		if limit <= 0 {
+			limit = config.List.DefaultLimit
+		}
+		page, err := s.ListPage(r.Context(), storage.PageQuery{
+			Limit:  limit,
+			Sort:   storage.SortByPublishedDesc,
+			Filter: filter,
+		})
+		if err != nil {
+			logger.Error(err, failFromStorageMsg)
+			http.Error(w, internalServerErrorMsg, http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", contentType)
+		err = encode(w, page.Articles)
+		if err != nil {
+			logger.Error(err, failFeedEncodeMsg)
+		}
+	}
+}
+
+// GetRSSFeedHandler serves newest articles as RSS 2.0 feed
+func GetRSSFeedHandler(s storage.ArticleStorage, config Config, logger logr.Logger) http.HandlerFunc {
+	logger = logger.WithValues("handler", "GetRSSFeedHandler")
+	return feedHandler(s, config, logger, "application/rss+xml; charset=utf-8", func(w http.ResponseWriter, articles []types.Article) error {
+		_, err := w.Write([]byte(xml.Header))
+		if err != nil {
+			return err
+		}
+		return xml.NewEncoder(w).Encode(makeRSSFeed(config, articles))
+	})
+}
+
+// GetAtomFeedHandler serves newest articles as Atom feed
+func GetAtomFeedHandler(s storage.ArticleStorage, config Config, logger logr.Logger) http.HandlerFunc {
+	logger = logger.WithValues("handler", "GetAtomFeedHandler")
+	return feedHandler(s, config, logger, "application/atom+xml; charset=utf-8", func(w http.ResponseWriter, articles []types.Article) error {
+		_, err := w.Write([]byte(xml.Header))
+		if err != nil {
+			return err
+		}
+		return xml.NewEncoder(w).Encode(makeAtomFeed(config, articles))
+	})
+}
+
+// GetJSONFeedHandler serves newest articles as JSON Feed
+func GetJSONFeedHandler(s storage.ArticleStorage, config Config, logger logr.Logger) http.HandlerFunc {
+	logger = logger.WithValues("handler", "GetJSONFeedHandler")
+	return feedHandler(s, config, logger, "application/feed+json", func(w http.ResponseWriter, articles []types.Article) error {
+		return json.NewEncoder(w).Encode(makeJSONFeed(config, articles))
+	})
+}
@@ -0,0 +1,71 @@
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"github.com/adamdyszy/sportsnews/internal/poller"
+	"github.com/adamdyszy/sportsnews/internal/storage/memory"
+	"github.com/adamdyszy/sportsnews/types"
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestFeeds(t *testing.T) {
+	s := memory.NewMemStorage()
+	var ids []types.ArticleId
+	for _, a := range []types.Article{
+		{ArticleKey: types.ArticleKey{TeamId: "t94", NewsId: "1", Published: time.Date(2023, 3, 4, 18, 58, 0, 0, time.UTC)},
+			Title: "Detailed", Teaser: "Teaser", Content: "<p>Body</p>", URL: "https://club/1", ImageURL: "https://club/1.png",
+			Type: []string{"Academy"}, HasDetails: true},
+		{ArticleKey: types.ArticleKey{TeamId: "t8", NewsId: "2", Published: time.Date(2023, 3, 3, 18, 58, 0, 0, time.UTC)},
+			Title: "Other team", Teaser: "Other", Type: []string{"Interviews"}},
+	} {
+		assert.NoError(t, a.SetGeneratedId())
+		assert.NoError(t, s.Write(context.Background(), a))
+		ids = append(ids, a.Id)
+	}
+	var config Config
+	config.Feeds.Title = "News"
+	config.Feeds.Link = "https://club/news"
+	config.Feeds.Author = "Club"
+	config.Feeds.Limit = 10
+
+	rec := httptest.NewRecorder()
+	GetRSSFeedHandler(s, config, logr.Discard())(rec, httptest.NewRequest("GET", "/feeds/rss.xml?teamId=t94", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var rss poller.RSSFeed
+	assert.NoError(t, xml.Unmarshal(rec.Body.Bytes(), &rss))
+	assert.Len(t, rss.Channel.Items, 1)
+	item := rss.Channel.Items[0]
+	assert.Equal(t, "<p>Body</p>", item.ContentEncoded)
+	assert.Equal(t, "https://club/1.png", item.Enclosures[0].URL)
+	assert.Equal(t, "image/png", item.Enclosures[0].Type)
+
+	rec = httptest.NewRecorder()
+	GetAtomFeedHandler(s, config, logr.Discard())(rec, httptest.NewRequest("GET", "/feeds/atom.xml?type=Interviews", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var atom poller.RSSFeed
+	assert.NoError(t, xml.Unmarshal(rec.Body.Bytes(), &atom))
+	assert.Len(t, atom.Entries, 1)
+	assert.Equal(t, "Other team", atom.Entries[0].Title)
+	assert.Equal(t, "tag:club,2023-03-03:"+string(ids[1]), atom.Entries[0].ID)
+	assert.Contains(t, rec.Body.String(), "<author><name>Club</name></author>")
+	assert.Empty(t, atom.Entries[0].Content.String())
+
+	rec = httptest.NewRecorder()
+	GetJSONFeedHandler(s, config, logr.Discard())(rec, httptest.NewRequest("GET", "/feeds/feed.json", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var feed jsonFeed
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&feed))
+	assert.Len(t, feed.Items, 2)
+	assert.Equal(t, "Detailed", feed.Items[0].Title, "newest article should be first")
+
+	rec = httptest.NewRecorder()
+	GetJSONFeedHandler(s, config, logr.Discard())(rec, httptest.NewRequest("GET", "/feeds/feed.json?hasDetails=maybe", nil))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
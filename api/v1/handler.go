@@ -7,6 +7,7 @@ import (
 	"github.com/go-logr/logr"
 	"github.com/gorilla/mux"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/adamdyszy/sportsnews/storage"
@@ -14,20 +15,28 @@ import (
 
 const internalServerErrorMsg = "Internal server error"
 const articleIdNotFoundMsg = "ArticleId not found"
+const articleRetractedMsg = "Article was retracted"
 const failFromStorageMsg = "Failure while getting articles from storage"
 const failJsonEncodeMsg = "Failure during json encoding"
+const invalidLimitMsg = "Limit has to be a positive number"
+const invalidPageQueryMsg = "Cursor or sort is invalid"
+const invalidSearchMsg = "Query parameter q with searched text is required"
+const invalidFilterMsg = "Filter is invalid, dates have to be in RFC3339 format, hasDetails and pinned booleans"
 
 type WithMessage interface {
 	GetMessage() string
 }
 
-func MakeSuccessArticleList(data []types.Article) types.ArticleList {
+// MakeSuccessArticleList returns page of articles, totalItems is how many articles there are in all pages
+func MakeSuccessArticleList(page storage.ArticlePage, totalItems int) types.ArticleList {
 	return types.ArticleList{
-		Data: data,
+		Data: page.Articles,
 		Metadata: types.ArticleListMetadata{
 			CreatedAt:  time.Now(),
-			Sort:       "random",
-			TotalItems: len(data),
+			Sort:       string(page.Sort),
+			TotalItems: totalItems,
+			NextCursor: page.NextCursor,
+			HasMore:    page.HasMore,
 		},
 		Status: "success",
 	}
@@ -84,13 +93,59 @@ func jsonEncodeErrorResponse(w http.ResponseWriter, response WithMessage, status
 	}
 }
 
+// parseArticleFilter reads article filter from query parameters of the request
+func parseArticleFilter(r *http.Request) (storage.ArticleFilter, error) {
+	values := r.URL.Query()
+	filter := storage.ArticleFilter{
+		Type:        values.Get("type"),
+		TeamId:      values.Get("teamId"),
+		OptaMatchId: values.Get("optaMatchId"),
+	}
+	var err error
+	if v := values.Get("publishedAfter"); v != "" {
+		filter.PublishedAfter, err = time.Parse(time.RFC3339, v)
+		if err != nil {
+			return filter, err
+		}
+	}
+	if v := values.Get("publishedBefore"); v != "" {
+		filter.PublishedBefore, err = time.Parse(time.RFC3339, v)
+		if err != nil {
+			return filter, err
+		}
+	}
+	if v := values.Get("hasDetails"); v != "" {
+		hasDetails, err := strconv.ParseBool(v)
+		if err != nil {
+			return filter, err
+		}
+		filter.HasDetails = &hasDetails
+	}
+	if v := values.Get("pinned"); v != "" {
+		pinned, err := strconv.ParseBool(v)
+		if err != nil {
+			return filter, err
+		}
+		filter.Pinned = &pinned
+	}
+	return filter, nil
+}
+
+// parseListedArticleFilter reads article filter like parseArticleFilter, but tombstones are never listed
+func parseListedArticleFilter(r *http.Request) (storage.ArticleFilter, error) {
+	filter, err := parseArticleFilter(r)
+	notRetracted := false
+	filter.Retracted = &notRetracted
+	return filter, err
+}
+
 func GetArticleByIdHandler(s storage.ArticleStorage, logger logr.Logger) http.HandlerFunc {
 	logger.WithValues("handler", "GetArticleByIdHandler")
 	return func(w http.ResponseWriter, r *http.Request) {
 		vars := mux.Vars(r)
 		articleId := vars["id"]
 		logger.WithValues("articleId", articleId)
-		article, err := s.Get(types.ArticleId(articleId))
+		article, err := s.Get(r.Context(), types.ArticleId(articleId))
 		if err != nil {
 			if errors.Is(err, storage.ArticleNotFound) {
 				// not logging here since it might happen often, we want to log important errors
@@ -103,22 +158,119 @@ func GetArticleByIdHandler(s storage.ArticleStorage, logger logr.Logger) http.Ha
 			jsonEncodeErrorResponse(w, response, http.StatusInternalServerError, logger)
 			return
 		}
+		if article.RetractedAt != nil {
+			jsonEncodeErrorResponse(w, MakeErrorArticleDetailed(articleRetractedMsg), http.StatusGone, logger)
+			return
+		}
 		response := MakeSuccessArticleDetailed(article)
 		jsonEncodeSuccessResponse(w, response, logger)
 	}
 }
 
-func GetAllArticlesHandler(s storage.ArticleStorage, logger logr.Logger) http.HandlerFunc {
+/*
+GetAllArticlesHandler returns page of articles.
+
+Query parameter limit sets the page size (capped at configured maxLimit),
+sort chooses order (published, -published, title, teamId, any field can be prefixed with "-")
+and cursor taken from nextCursor of previous response selects next page.
+Articles can be filtered with type, teamId, optaMatchId, publishedAfter, publishedBefore, hasDetails and pinned.
+*/
+func GetAllArticlesHandler(s storage.ArticleStorage, config Config, logger logr.Logger) http.HandlerFunc {
 	logger.WithValues("handler", "GetAllArticlesHandler")
 	return func(w http.ResponseWriter, r *http.Request) {
-		articles, err := s.List()
+		query := storage.PageQuery{
+			Limit:  config.List.DefaultLimit,
+			Cursor: r.URL.Query().Get("cursor"),
+			Sort:   storage.SortOrder(r.URL.Query().Get("sort")),
+		}
+		if limit := r.URL.Query().Get("limit"); limit != "" {
+			var err error
+			query.Limit, err = strconv.Atoi(limit)
+			if err != nil || query.Limit <= 0 {
+				response := MakeErrorArticleList(invalidLimitMsg)
+				jsonEncodeErrorResponse(w, response, http.StatusBadRequest, logger)
+				return
+			}
+		}
+		if config.List.MaxLimit > 0 && query.Limit > config.List.MaxLimit {
+			query.Limit = config.List.MaxLimit
+		}
+		filter, err := parseListedArticleFilter(r)
 		if err != nil {
+			response := MakeErrorArticleList(invalidFilterMsg)
+			jsonEncodeErrorResponse(w, response, http.StatusBadRequest, logger)
+			return
+		}
+		query.Filter = filter
+		page, err := s.ListPage(r.Context(), query)
+		if err != nil {
+			if errors.Is(err, storage.InvalidPageQuery) {
+				response := MakeErrorArticleList(invalidPageQueryMsg)
+				jsonEncodeErrorResponse(w, response, http.StatusBadRequest, logger)
+				return
+			}
+			logger.Error(err, failFromStorageMsg)
+			response := MakeErrorArticleList(internalServerErrorMsg)
+			jsonEncodeErrorResponse(w, response, http.StatusInternalServerError, logger)
+			return
+		}
+		total, err := s.Count(r.Context(), filter)
+		if err != nil {
+			logger.Error(err, failFromStorageMsg)
+			response := MakeErrorArticleList(internalServerErrorMsg)
+			jsonEncodeErrorResponse(w, response, http.StatusInternalServerError, logger)
+			return
+		}
+		response := MakeSuccessArticleList(page, total)
+		jsonEncodeSuccessResponse(w, response, logger)
+	}
+}
+
+/*
+SearchArticlesHandler returns articles matching text in query parameter q ordered by relevance.
+
+Title, teaser and content are searched, query parameter limit works like in GetAllArticlesHandler.
+*/
+func SearchArticlesHandler(s storage.ArticleStorage, config Config, logger logr.Logger) http.HandlerFunc {
+	logger.WithValues("handler", "SearchArticlesHandler")
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := storage.SearchQuery{
+			Text:  r.URL.Query().Get("q"),
+			Limit: config.List.DefaultLimit,
+		}
+		if limit := r.URL.Query().Get("limit"); limit != "" {
+			var err error
+			query.Limit, err = strconv.Atoi(limit)
+			if err != nil || query.Limit <= 0 {
+				response := MakeErrorArticleList(invalidLimitMsg)
+				jsonEncodeErrorResponse(w, response, http.StatusBadRequest, logger)
+				return
+			}
+		}
+		if config.List.MaxLimit > 0 && query.Limit > config.List.MaxLimit {
+			query.Limit = config.List.MaxLimit
+		}
+		results, err := s.Search(r.Context(), query)
+		if err != nil {
+			if errors.Is(err, storage.InvalidPageQuery) {
+				response := MakeErrorArticleList(invalidSearchMsg)
+				jsonEncodeErrorResponse(w, response, http.StatusBadRequest, logger)
+				return
+			}
 			logger.Error(err, failFromStorageMsg)
 			response := MakeErrorArticleList(internalServerErrorMsg)
 			jsonEncodeErrorResponse(w, response, http.StatusInternalServerError, logger)
 			return
 		}
-		response := MakeSuccessArticleList(articles)
+		page := storage.ArticlePage{
+			Articles: make([]types.Article, 0, len(results)),
+			Sort:     "relevance",
+		}
+		for _, result := range results {
+			page.Articles = append(page.Articles, result.Article)
+		}
+		// search is not paged, all results up to the limit are returned
+		response := MakeSuccessArticleList(page, len(page.Articles))
 		jsonEncodeSuccessResponse(w, response, logger)
 	}
 }
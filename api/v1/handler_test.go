@@ -0,0 +1,87 @@
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/adamdyszy/sportsnews/internal/storage/memory"
+	"github.com/adamdyszy/sportsnews/types"
+	"github.com/go-logr/logr"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRetractedArticle(t *testing.T) {
+	ctx := context.Background()
+	s := memory.NewMemStorage()
+	var articles []types.Article
+	for n, newsId := range []string{"1", "2"} {
+		a := types.Article{
+			ArticleKey: types.ArticleKey{TeamId: "t94", NewsId: newsId, Published: time.Date(2023, 3, 4, 18, n, 0, 0, time.UTC)},
+			Title:      "Title " + newsId,
+		}
+		require.NoError(t, a.SetGeneratedId())
+		require.NoError(t, s.Write(ctx, a))
+		articles = append(articles, a)
+	}
+	tombstone := articles[0]
+	retractedAt := time.Date(2023, 3, 5, 0, 0, 0, 0, time.UTC)
+	tombstone.RetractedAt = &retractedAt
+	require.NoError(t, s.Replace(ctx, tombstone))
+
+	r := mux.NewRouter()
+	r.HandleFunc("/articles/{id}", GetArticleByIdHandler(s, logr.Discard()))
+	r.HandleFunc("/articles", GetAllArticlesHandler(s, Config{}, logr.Discard()))
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest("GET", "/articles/"+string(tombstone.Id), nil))
+	assert.Equal(t, http.StatusGone, rec.Code)
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest("GET", "/articles/"+string(articles[1].Id), nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest("GET", "/articles?limit=10", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	var list types.ArticleList
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&list))
+	require.Len(t, list.Data, 1)
+	assert.Equal(t, articles[1].Id, list.Data[0].Id)
+	assert.Equal(t, 1, list.Metadata.TotalItems, "tombstone should not be counted")
+}
+
+func TestListTotalItems(t *testing.T) {
+	ctx := context.Background()
+	s := memory.NewMemStorage()
+	for n := 1; n <= 3; n++ {
+		a := types.Article{
+			ArticleKey: types.ArticleKey{TeamId: "t94", NewsId: fmt.Sprint(n), Published: time.Date(2023, 3, 4, 18, n, 0, 0, time.UTC)},
+			Type:       []string{"Academy"},
+		}
+		if n == 3 {
+			a.Type = []string{"Interviews"}
+		}
+		require.NoError(t, a.SetGeneratedId())
+		require.NoError(t, s.Write(ctx, a))
+	}
+	list := func(query string) types.ArticleList {
+		rec := httptest.NewRecorder()
+		GetAllArticlesHandler(s, Config{}, logr.Discard())(rec, httptest.NewRequest("GET", "/articles?"+query, nil))
+		require.Equal(t, http.StatusOK, rec.Code)
+		var list types.ArticleList
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(&list))
+		return list
+	}
+
+	// total is count of all pages, not of the returned one
+	first := list("limit=1")
+	assert.Len(t, first.Data, 1)
+	assert.Equal(t, 3, first.Metadata.TotalItems)
+	assert.Equal(t, 3, list("limit=1&cursor="+first.Metadata.NextCursor).Metadata.TotalItems)
+	assert.Equal(t, 2, list("limit=1&type=Academy").Metadata.TotalItems)
+}
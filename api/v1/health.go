@@ -0,0 +1,52 @@
+package v1
+
+import (
+	"github.com/adamdyszy/sportsnews/internal/poller"
+	"github.com/adamdyszy/sportsnews/storage"
+	"github.com/adamdyszy/sportsnews/types"
+	"github.com/go-logr/logr"
+	"net/http"
+)
+
+const storageUnreachableMsg = "Storage is unreachable"
+
+// HealthzHandler is liveness probe, it responds as long as the server is able to serve requests
+func HealthzHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		_, _ = w.Write([]byte("ok\n"))
+	}
+}
+
+// ReadyzHandler is readiness probe, it responds with service unavailable when storage cannot be reached
+func ReadyzHandler(s storage.ArticleStorage, logger logr.Logger) http.HandlerFunc {
+	logger = logger.WithValues("handler", "ReadyzHandler")
+	return func(w http.ResponseWriter, r *http.Request) {
+		err := s.Ping(r.Context())
+		if err != nil {
+			logger.Error(err, storageUnreachableMsg)
+			http.Error(w, storageUnreachableMsg, http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		_, _ = w.Write([]byte("ok\n"))
+	}
+}
+
+// StatusHandler returns last runs, errors and next runs of poller cron jobs and backfill progress
+func StatusHandler(pl *poller.Poller, logger logr.Logger) http.HandlerFunc {
+	logger = logger.WithValues("handler", "StatusHandler")
+	return func(w http.ResponseWriter, r *http.Request) {
+		backfill, err := pl.BackfillStatus(r.Context())
+		if err != nil {
+			logger.Error(err, "Could not get backfill progress.")
+			jsonEncodeErrorResponse(w, types.StatusResponse{Status: "error", Message: internalServerErrorMsg}, http.StatusInternalServerError, logger)
+			return
+		}
+		response := types.StatusResponse{
+			Status: "success",
+			Data:   &types.Status{PollerJobs: pl.Status(), Backfill: backfill},
+		}
+		jsonEncodeSuccessResponse(w, response, logger)
+	}
+}
@@ -0,0 +1,28 @@
+package v1
+
+import (
+	"github.com/adamdyszy/sportsnews/internal/metrics"
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMetricsMiddleware(t *testing.T) {
+	r := mux.NewRouter()
+	r.Use(MetricsMiddleware)
+	r.HandleFunc("/articles/{id}", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, articleIdNotFoundMsg, http.StatusNotFound)
+	})
+	counter := metrics.HTTPRequests.WithLabelValues("/articles/{id}", "GET", "404")
+	before := testutil.ToFloat64(counter)
+
+	for _, id := range []string{"a", "b"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/articles/"+id, nil))
+	}
+
+	// requests of the same route are counted under its template
+	assert.Equal(t, before+2, testutil.ToFloat64(counter))
+}
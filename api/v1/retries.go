@@ -0,0 +1,88 @@
+package v1
+
+import (
+	"context"
+	"errors"
+	"github.com/adamdyszy/sportsnews/internal/admin"
+	"github.com/adamdyszy/sportsnews/internal/poller"
+	"github.com/adamdyszy/sportsnews/storage"
+	"github.com/adamdyszy/sportsnews/types"
+	"github.com/go-logr/logr"
+	"github.com/gorilla/mux"
+	"net/http"
+	"strconv"
+)
+
+const retryNotFoundMsg = "News id is not waiting for retry"
+const invalidDeadMsg = "Query parameter dead has to be true or false"
+
+// JobKindReplayDetails is kind of admin job polling details of news id from retry queue
+const JobKindReplayDetails = "replayDetails"
+
+func MakeSuccessDetailsRetryList(retries []types.DetailsRetry) types.DetailsRetryList {
+	return types.DetailsRetryList{Status: "success", Data: retries}
+}
+
+func MakeErrorDetailsRetryList(msg string) types.DetailsRetryList {
+	return types.DetailsRetryList{Status: "error", Message: msg}
+}
+
+/*
+ListDetailsRetriesHandler returns news ids whose details could not be polled, ordered by last attempt.
+
+Optional query parameter teamId limits them to single team and dead chooses dead (true) or waiting (false) ones.
+*/
+func ListDetailsRetriesHandler(rs storage.RetryStorage, logger logr.Logger) http.HandlerFunc {
+	logger = logger.WithValues("handler", "ListDetailsRetriesHandler")
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		var dead *bool
+		if v := query.Get("dead"); v != "" {
+			parsed, err := strconv.ParseBool(v)
+			if err != nil {
+				jsonEncodeErrorResponse(w, MakeErrorDetailsRetryList(invalidDeadMsg), http.StatusBadRequest, logger)
+				return
+			}
+			dead = &parsed
+		}
+		retries, err := rs.ListRetries(r.Context(), query.Get("teamId"))
+		if err != nil {
+			logger.Error(err, failFromStorageMsg)
+			jsonEncodeErrorResponse(w, MakeErrorDetailsRetryList(internalServerErrorMsg), http.StatusInternalServerError, logger)
+			return
+		}
+		filtered := make([]types.DetailsRetry, 0, len(retries))
+		for _, retry := range retries {
+			if dead == nil || retry.Dead == *dead {
+				filtered = append(filtered, retry)
+			}
+		}
+		jsonEncodeSuccessResponse(w, MakeSuccessDetailsRetryList(filtered), logger)
+	}
+}
+
+// ReplayDetailsRetryHandler starts polling details of news id from retry queue right away, dead news id gets all attempts again
+func ReplayDetailsRetryHandler(pl *poller.Poller, rs storage.RetryStorage, jobs *admin.Jobs, logger logr.Logger) http.HandlerFunc {
+	logger = logger.WithValues("handler", "ReplayDetailsRetryHandler")
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		teamId, newsId := vars["teamId"], vars["newsId"]
+		_, err := rs.GetRetry(r.Context(), teamId, newsId)
+		if err != nil {
+			if errors.Is(err, storage.RetryNotFound) {
+				jsonEncodeErrorResponse(w, MakeErrorAdminJob(retryNotFoundMsg), http.StatusNotFound, logger)
+				return
+			}
+			logger.Error(err, failFromStorageMsg)
+			jsonEncodeErrorResponse(w, MakeErrorAdminJob(internalServerErrorMsg), http.StatusInternalServerError, logger)
+			return
+		}
+		if !pl.HasFeed(teamId) {
+			jsonEncodeErrorResponse(w, MakeErrorAdminJob(feedNotFoundMsg), http.StatusNotFound, logger)
+			return
+		}
+		startJob(w, jobs, JobKindReplayDetails, func(ctx context.Context) (types.PollSummary, error) {
+			return pl.ReplayDetails(ctx, teamId, newsId)
+		}, logger)
+	}
+}
@@ -0,0 +1,47 @@
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/adamdyszy/sportsnews/internal/storage/memory"
+	"github.com/adamdyszy/sportsnews/types"
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestListDetailsRetries(t *testing.T) {
+	ctx := context.Background()
+	rs := memory.NewMemRetryStorage()
+	lastAttempt := time.Date(2023, 3, 1, 12, 0, 0, 0, time.UTC)
+	next := lastAttempt.Add(time.Minute)
+	require.NoError(t, rs.SaveRetry(ctx, types.DetailsRetry{TeamId: "t94", NewsId: "1", Attempts: 1, LastAttemptAt: lastAttempt, NextAttemptAt: &next}))
+	require.NoError(t, rs.SaveRetry(ctx, types.DetailsRetry{TeamId: "t94", NewsId: "2", Attempts: 5, LastAttemptAt: lastAttempt.Add(time.Hour), Dead: true}))
+	require.NoError(t, rs.SaveRetry(ctx, types.DetailsRetry{TeamId: "t1", NewsId: "3", Attempts: 5, LastAttemptAt: lastAttempt.Add(2 * time.Hour), Dead: true}))
+	handler := ListDetailsRetriesHandler(rs, logr.Discard())
+	newsIds := func(query string) []string {
+		rec := httptest.NewRecorder()
+		handler(rec, httptest.NewRequest("GET", "/admin/retries"+query, nil))
+		require.Equal(t, http.StatusOK, rec.Code)
+		var list types.DetailsRetryList
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(&list))
+		var ids []string
+		for _, retry := range list.Data {
+			ids = append(ids, retry.NewsId)
+		}
+		return ids
+	}
+
+	assert.Equal(t, []string{"1", "2", "3"}, newsIds(""))
+	assert.Equal(t, []string{"2", "3"}, newsIds("?dead=true"))
+	assert.Equal(t, []string{"1"}, newsIds("?dead=false"))
+	assert.Equal(t, []string{"2"}, newsIds("?teamId=t94&dead=true"))
+
+	rec := httptest.NewRecorder()
+	handler(rec, httptest.NewRequest("GET", "/admin/retries?dead=maybe", nil))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
@@ -0,0 +1,153 @@
+package v1
+
+import (
+	"context"
+	"errors"
+	"github.com/adamdyszy/sportsnews/storage"
+	"github.com/adamdyszy/sportsnews/types"
+	"github.com/go-logr/logr"
+	"github.com/gorilla/mux"
+	"net/http"
+	"strconv"
+)
+
+const revisionNotFoundMsg = "Revision not found"
+const invalidRevisionMsg = "Revision numbers have to be positive numbers"
+
+// errArticleRetracted is returned by articleRevisions when the article is a tombstone
+var errArticleRetracted = errors.New("article was retracted")
+
+func MakeSuccessArticleRevisionList(revisions []types.ArticleRevision) types.ArticleRevisionList {
+	return types.ArticleRevisionList{Status: "success", Data: revisions}
+}
+
+func MakeErrorArticleRevisionList(msg string) types.ArticleRevisionList {
+	return types.ArticleRevisionList{Status: "error", Message: msg}
+}
+
+func MakeSuccessArticleRevision(revision types.ArticleRevision) types.ArticleRevisionResponse {
+	return types.ArticleRevisionResponse{Status: "success", Data: &revision}
+}
+
+func MakeErrorArticleRevision(msg string) types.ArticleRevisionResponse {
+	return types.ArticleRevisionResponse{Status: "error", Message: msg}
+}
+
+func MakeSuccessArticleDiff(diff types.ArticleDiff) types.ArticleDiffResponse {
+	return types.ArticleDiffResponse{Status: "success", Data: &diff}
+}
+
+func MakeErrorArticleDiff(msg string) types.ArticleDiffResponse {
+	return types.ArticleDiffResponse{Status: "error", Message: msg}
+}
+
+// articleRevisions returns all revisions of the article oldest first, the last one is the current article,
+// revisions of retracted articles are not served
+func articleRevisions(ctx context.Context, s storage.ArticleStorage, id types.ArticleId) ([]types.ArticleRevision, error) {
+	current, err := s.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if current.RetractedAt != nil {
+		return nil, errArticleRetracted
+	}
+	revisions, err := s.ListRevisions(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return append(revisions, types.ArticleRevision{Number: len(revisions) + 1, Article: current}), nil
+}
+
+// parseRevision reads revision number, it has to be positive
+func parseRevision(s string) (int, bool) {
+	n, err := strconv.Atoi(s)
+	return n, err == nil && n > 0
+}
+
+// writeRevisionsError responds with error of articleRevisions using response made by makeError
+func writeRevisionsError(w http.ResponseWriter, err error, makeError func(msg string) WithMessage, logger logr.Logger) {
+	if errors.Is(err, storage.ArticleNotFound) {
+		jsonEncodeErrorResponse(w, makeError(articleIdNotFoundMsg), http.StatusNotFound, logger)
+		return
+	}
+	if errors.Is(err, errArticleRetracted) {
+		jsonEncodeErrorResponse(w, makeError(articleRetractedMsg), http.StatusGone, logger)
+		return
+	}
+	logger.Error(err, failFromStorageMsg)
+	jsonEncodeErrorResponse(w, makeError(internalServerErrorMsg), http.StatusInternalServerError, logger)
+}
+
+// ListArticleRevisionsHandler returns all revisions of the article oldest first, the last one is the current article
+func ListArticleRevisionsHandler(s storage.ArticleStorage, logger logr.Logger) http.HandlerFunc {
+	logger = logger.WithValues("handler", "ListArticleRevisionsHandler")
+	return func(w http.ResponseWriter, r *http.Request) {
+		revisions, err := articleRevisions(r.Context(), s, types.ArticleId(mux.Vars(r)["id"]))
+		if err != nil {
+			writeRevisionsError(w, err, func(msg string) WithMessage { return MakeErrorArticleRevisionList(msg) }, logger)
+			return
+		}
+		jsonEncodeSuccessResponse(w, MakeSuccessArticleRevisionList(revisions), logger)
+	}
+}
+
+// GetArticleRevisionHandler returns single revision of the article by its number
+func GetArticleRevisionHandler(s storage.ArticleStorage, logger logr.Logger) http.HandlerFunc {
+	logger = logger.WithValues("handler", "GetArticleRevisionHandler")
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		n, ok := parseRevision(vars["n"])
+		if !ok {
+			jsonEncodeErrorResponse(w, MakeErrorArticleRevision(invalidRevisionMsg), http.StatusBadRequest, logger)
+			return
+		}
+		revisions, err := articleRevisions(r.Context(), s, types.ArticleId(vars["id"]))
+		if err != nil {
+			writeRevisionsError(w, err, func(msg string) WithMessage { return MakeErrorArticleRevision(msg) }, logger)
+			return
+		}
+		if n > len(revisions) {
+			jsonEncodeErrorResponse(w, MakeErrorArticleRevision(revisionNotFoundMsg), http.StatusNotFound, logger)
+			return
+		}
+		jsonEncodeSuccessResponse(w, MakeSuccessArticleRevision(revisions[n-1]), logger)
+	}
+}
+
+/*
+GetArticleRevisionDiffHandler returns fields of the article changed between revisions.
+
+Revision n is compared with the one before it, or with revision given by query parameter from.
+*/
+func GetArticleRevisionDiffHandler(s storage.ArticleStorage, logger logr.Logger) http.HandlerFunc {
+	logger = logger.WithValues("handler", "GetArticleRevisionDiffHandler")
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		to, ok := parseRevision(vars["n"])
+		from := to - 1
+		if v := r.URL.Query().Get("from"); ok && v != "" {
+			from, ok = parseRevision(v)
+		}
+		if !ok || from < 1 {
+			jsonEncodeErrorResponse(w, MakeErrorArticleDiff(invalidRevisionMsg), http.StatusBadRequest, logger)
+			return
+		}
+		id := types.ArticleId(vars["id"])
+		revisions, err := articleRevisions(r.Context(), s, id)
+		if err != nil {
+			writeRevisionsError(w, err, func(msg string) WithMessage { return MakeErrorArticleDiff(msg) }, logger)
+			return
+		}
+		if to > len(revisions) || from > len(revisions) {
+			jsonEncodeErrorResponse(w, MakeErrorArticleDiff(revisionNotFoundMsg), http.StatusNotFound, logger)
+			return
+		}
+		changes, err := types.DiffArticles(revisions[from-1].Article, revisions[to-1].Article)
+		if err != nil {
+			logger.Error(err, "Could not diff revisions.")
+			jsonEncodeErrorResponse(w, MakeErrorArticleDiff(internalServerErrorMsg), http.StatusInternalServerError, logger)
+			return
+		}
+		jsonEncodeSuccessResponse(w, MakeSuccessArticleDiff(types.ArticleDiff{Id: id, From: from, To: to, Changes: changes}), logger)
+	}
+}
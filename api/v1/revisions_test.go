@@ -0,0 +1,82 @@
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/adamdyszy/sportsnews/internal/storage/memory"
+	"github.com/adamdyszy/sportsnews/types"
+	"github.com/go-logr/logr"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRevisions(t *testing.T) {
+	ctx := context.Background()
+	s := memory.NewMemStorage()
+	a := types.Article{
+		ArticleKey: types.ArticleKey{TeamId: "t94", NewsId: "1", Published: time.Date(2023, 3, 4, 18, 58, 0, 0, time.UTC)},
+		Title:      "Match report", Content: "<p>We lost</p>", HasDetails: true,
+	}
+	require.NoError(t, a.SetGeneratedId())
+	require.NoError(t, s.Write(ctx, a))
+	corrected := a
+	corrected.Content = "<p>We won</p>"
+	require.NoError(t, s.Replace(ctx, corrected))
+
+	r := mux.NewRouter()
+	r.HandleFunc("/articles/{id}/revisions", ListArticleRevisionsHandler(s, logr.Discard()))
+	r.HandleFunc("/articles/{id}/revisions/{n}", GetArticleRevisionHandler(s, logr.Discard()))
+	r.HandleFunc("/articles/{id}/revisions/{n}/diff", GetArticleRevisionDiffHandler(s, logr.Discard()))
+	get := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+		return rec
+	}
+	base := "/articles/" + string(a.Id) + "/revisions"
+
+	rec := get(base)
+	require.Equal(t, http.StatusOK, rec.Code)
+	var list types.ArticleRevisionList
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&list))
+	require.Len(t, list.Data, 2)
+	assert.Equal(t, "<p>We lost</p>", list.Data[0].Article.Content)
+	assert.NotNil(t, list.Data[0].ReplacedAt)
+	assert.Equal(t, 2, list.Data[1].Number)
+	assert.Nil(t, list.Data[1].ReplacedAt, "current revision is not replaced")
+
+	rec = get(base + "/1")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var revision types.ArticleRevisionResponse
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&revision))
+	assert.Equal(t, "<p>We lost</p>", revision.Data.Article.Content)
+
+	rec = get(base + "/2/diff")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var diff types.ArticleDiffResponse
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&diff))
+	assert.Equal(t, types.ArticleDiff{Id: a.Id, From: 1, To: 2, Changes: []types.ArticleChange{
+		{Field: "content", From: "<p>We lost</p>", To: "<p>We won</p>"},
+	}}, *diff.Data)
+
+	rec = get(base + "/1/diff?from=2")
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	assert.Equal(t, http.StatusNotFound, get(base+"/3").Code)
+	assert.Equal(t, http.StatusNotFound, get("/articles/missing/revisions").Code)
+	assert.Equal(t, http.StatusBadRequest, get(base+"/0").Code)
+	assert.Equal(t, http.StatusBadRequest, get(base+"/1/diff").Code)
+	assert.Equal(t, http.StatusBadRequest, get(base+"/2/diff?from=x").Code)
+
+	retracted := corrected
+	retractedAt := time.Date(2023, 3, 5, 10, 0, 0, 0, time.UTC)
+	retracted.RetractedAt = &retractedAt
+	require.NoError(t, s.Replace(ctx, retracted))
+	assert.Equal(t, http.StatusGone, get(base).Code)
+	assert.Equal(t, http.StatusGone, get(base+"/1").Code)
+	assert.Equal(t, http.StatusGone, get(base+"/2/diff").Code)
+}
@@ -1,19 +1,82 @@
 package v1
 
 import (
+	"fmt"
+	"github.com/adamdyszy/sportsnews/internal/admin"
+	"github.com/adamdyszy/sportsnews/internal/events"
+	"github.com/adamdyszy/sportsnews/internal/poller"
 	"github.com/adamdyszy/sportsnews/storage"
 	"github.com/go-logr/logr"
 	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/spf13/viper"
 	"net/http"
 )
 
-func ListenAndServe(v *viper.Viper, s storage.ArticleStorage, logger logr.Logger) error {
-	address := v.GetString("address")
+/*
+NewServer creates api server with all handlers, it is started with ListenAndServe of returned server.
+Admin api starts polls as jobs, they have to be stopped after Server.Shutdown.
+
+Server.Shutdown ends open article streams, so they do not block it.
+*/
+func NewServer(
+	v *viper.Viper,
+	s storage.ArticleStorage,
+	b *events.Broker,
+	ws storage.WebhookStorage,
+	rs storage.RetryStorage,
+	pl *poller.Poller,
+	jobs *admin.Jobs,
+	logger logr.Logger,
+) (*http.Server, error) {
+	var config Config
+	err := v.Unmarshal(&config)
+	if err != nil {
+		return nil, fmt.Errorf("error unmarshaling api config: %w", err)
+	}
+	server := &http.Server{Addr: config.Address}
+	shutdown := make(chan struct{})
+	server.RegisterOnShutdown(func() {
+		close(shutdown)
+	})
 	r := mux.NewRouter()
-	// Serve api handlers
+	r.Use(TracingMiddleware, MetricsMiddleware)
+	// Serve api handlers, static paths have to be registered before /articles/{id}
+	r.HandleFunc("/articles/search", SearchArticlesHandler(s, config, logger)).Methods("GET")
+	r.HandleFunc("/articles/stream", StreamArticlesHandler(b, config, shutdown, logger)).Methods("GET")
 	r.HandleFunc("/articles/{id}", GetArticleByIdHandler(s, logger)).Methods("GET")
-	r.HandleFunc("/articles", GetAllArticlesHandler(s, logger)).Methods("GET")
+	r.HandleFunc("/articles/{id}/revisions", ListArticleRevisionsHandler(s, logger)).Methods("GET")
+	r.HandleFunc("/articles/{id}/revisions/{n}", GetArticleRevisionHandler(s, logger)).Methods("GET")
+	r.HandleFunc("/articles/{id}/revisions/{n}/diff", GetArticleRevisionDiffHandler(s, logger)).Methods("GET")
+	r.HandleFunc("/articles", GetAllArticlesHandler(s, config, logger)).Methods("GET")
+	r.HandleFunc("/feeds/rss.xml", GetRSSFeedHandler(s, config, logger)).Methods("GET")
+	r.HandleFunc("/feeds/atom.xml", GetAtomFeedHandler(s, config, logger)).Methods("GET")
+	r.HandleFunc("/feeds/feed.json", GetJSONFeedHandler(s, config, logger)).Methods("GET")
+	r.HandleFunc("/healthz", HealthzHandler()).Methods("GET")
+	r.HandleFunc("/readyz", ReadyzHandler(s, logger)).Methods("GET")
+	r.HandleFunc("/status", StatusHandler(pl, logger)).Methods("GET")
+	r.Handle("/metrics", promhttp.Handler()).Methods("GET")
+	if config.Admin.Token != "" {
+		a := r.PathPrefix("/admin").Subrouter()
+		a.Use(AdminAuthMiddleware(config.Admin.Token, logger))
+		a.HandleFunc("/poll/list", PollListHandler(pl, jobs, logger)).Methods("POST")
+		a.HandleFunc("/poll/details", PollDetailsHandler(pl, jobs, logger)).Methods("POST")
+		a.HandleFunc("/articles/{id}/refresh", RefreshArticleHandler(pl, s, jobs, logger)).Methods("POST")
+		a.HandleFunc("/articles/{id}/pin", PinArticleHandler(s, true, logger)).Methods("PUT")
+		a.HandleFunc("/articles/{id}/pin", PinArticleHandler(s, false, logger)).Methods("DELETE")
+		a.HandleFunc("/retries", ListDetailsRetriesHandler(rs, logger)).Methods("GET")
+		a.HandleFunc("/retries/{teamId}/{newsId}/replay", ReplayDetailsRetryHandler(pl, rs, jobs, logger)).Methods("POST")
+		a.HandleFunc("/jobs/{id}", GetAdminJobHandler(jobs, logger)).Methods("GET")
+		// webhooks make the server send requests to any url, so only admins manage them
+		a.HandleFunc("/webhooks", CreateWebhookHandler(ws, logger)).Methods("POST")
+		a.HandleFunc("/webhooks", ListWebhooksHandler(ws, logger)).Methods("GET")
+		a.HandleFunc("/webhooks/{id}", GetWebhookHandler(ws, logger)).Methods("GET")
+		a.HandleFunc("/webhooks/{id}", DeleteWebhookHandler(ws, logger)).Methods("DELETE")
+		a.HandleFunc("/webhooks/{id}/deliveries", ListWebhookDeliveriesHandler(ws, logger)).Methods("GET")
+	} else {
+		logger.Info("Admin api and webhooks are disabled, set api.admin.token to enable them.")
+	}
 
-	return http.ListenAndServe(address, r)
+	server.Handler = r
+	return server, nil
 }
@@ -0,0 +1,111 @@
+package v1
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/adamdyszy/sportsnews/internal/events"
+	"github.com/go-logr/logr"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const streamingUnsupportedMsg = "Streaming is not supported"
+const invalidLastEventIdMsg = "Last-Event-ID has to be a number"
+
+// writeEvent writes event in server-sent events format, data is the article json
+func writeEvent(w http.ResponseWriter, e events.Event) error {
+	data, err := json.Marshal(e.Article)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.Id, e.Kind, data)
+	return err
+}
+
+/*
+StreamArticlesHandler streams saved articles as server-sent events.
+
+Event name is events.Kind (created, upgraded or retracted) and data is the article json,
+retracted articles are streamed too, so subscribers learn they were withdrawn.
+Client resuming the stream sends Last-Event-ID header (or lastEventId query parameter)
+and gets remembered events it missed. Query parameters teamId and type (and other filters
+of GetAllArticlesHandler) narrow the streamed articles.
+Stream ends when shutdown is closed, so it does not hold the server during its shutdown.
+*/
+func StreamArticlesHandler(b *events.Broker, config Config, shutdown <-chan struct{}, logger logr.Logger) http.HandlerFunc {
+	logger = logger.WithValues("handler", "StreamArticlesHandler")
+	return func(w http.ResponseWriter, r *http.Request) {
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			http.Error(w, streamingUnsupportedMsg, http.StatusInternalServerError)
+			return
+		}
+		filter, err := parseArticleFilter(r)
+		if err != nil {
+			http.Error(w, invalidFilterMsg, http.StatusBadRequest)
+			return
+		}
+		lastEventId := r.Header.Get("Last-Event-ID")
+		if lastEventId == "" {
+			lastEventId = r.URL.Query().Get("lastEventId")
+		}
+		var lastId uint64
+		if lastEventId != "" {
+			lastId, err = strconv.ParseUint(lastEventId, 10, 64)
+			if err != nil {
+				http.Error(w, invalidLastEventIdMsg, http.StatusBadRequest)
+				return
+			}
+		}
+
+		missed, ch, unsubscribe := b.Subscribe(lastId)
+		defer unsubscribe()
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+		w.WriteHeader(http.StatusOK)
+		for _, e := range missed {
+			if !filter.Matches(e.Article) {
+				continue
+			}
+			if err := writeEvent(w, e); err != nil {
+				return
+			}
+		}
+		flusher.Flush()
+
+		keepAlive := time.Duration(config.Stream.KeepAliveSeconds) * time.Second
+		if keepAlive <= 0 {
+			keepAlive = 30 * time.Second
+		}
+		ticker := time.NewTicker(keepAlive)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case <-shutdown:
+				return
+			case e, open := <-ch:
+				if !open {
+					// subscriber was too slow, client will reconnect with Last-Event-ID
+					logger.Info("Closing slow stream subscriber.")
+					return
+				}
+				if !filter.Matches(e.Article) {
+					continue
+				}
+				if err := writeEvent(w, e); err != nil {
+					return
+				}
+				flusher.Flush()
+			case <-ticker.C:
+				if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+					return
+				}
+				flusher.Flush()
+			}
+		}
+	}
+}
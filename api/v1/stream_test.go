@@ -0,0 +1,79 @@
+package v1
+
+import (
+	"bufio"
+	"github.com/adamdyszy/sportsnews/internal/events"
+	"github.com/adamdyszy/sportsnews/types"
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStreamResume(t *testing.T) {
+	b := events.NewBroker(10)
+	b.Publish(events.ArticleCreated, types.Article{Id: "first", ArticleKey: types.ArticleKey{TeamId: "t94"}})
+	b.Publish(events.ArticleCreated, types.Article{Id: "other", ArticleKey: types.ArticleKey{TeamId: "t8"}})
+	b.Publish(events.ArticleUpgraded, types.Article{Id: "second", ArticleKey: types.ArticleKey{TeamId: "t94"}})
+	server := httptest.NewServer(StreamArticlesHandler(b, Config{}, nil, logr.Discard()))
+	defer server.Close()
+
+	req, err := http.NewRequest("GET", server.URL+"?teamId=t94", nil)
+	assert.NoError(t, err)
+	req.Header.Set("Last-Event-ID", "1")
+	resp, err := server.Client().Do(req)
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	reader := bufio.NewReader(resp.Body)
+	readEvent := func() []string {
+		var lines []string
+		for {
+			line, err := reader.ReadString('\n')
+			assert.NoError(t, err)
+			line = strings.TrimSuffix(line, "\n")
+			if line == "" {
+				return lines
+			}
+			lines = append(lines, line)
+		}
+	}
+	e := readEvent()
+	assert.Equal(t, "id: 3", e[0])
+	assert.Equal(t, "event: upgraded", e[1])
+	assert.Contains(t, e[2], `"id":"second"`)
+
+	b.Publish(events.ArticleCreated, types.Article{Id: "third", ArticleKey: types.ArticleKey{TeamId: "t94"}})
+	e = readEvent()
+	assert.Equal(t, "id: 4", e[0])
+	assert.Equal(t, "event: created", e[1])
+}
+
+func TestStreamRetracted(t *testing.T) {
+	b := events.NewBroker(10)
+	server := httptest.NewServer(StreamArticlesHandler(b, Config{}, nil, logr.Discard()))
+	defer server.Close()
+
+	resp, err := server.Client().Get(server.URL + "?teamId=t94")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	// headers are sent once the stream is subscribed
+	retractedAt := time.Date(2023, 3, 4, 18, 58, 0, 0, time.UTC)
+	b.Publish(events.ArticleRetracted, types.Article{Id: "withdrawn", ArticleKey: types.ArticleKey{TeamId: "t94"}, RetractedAt: &retractedAt})
+
+	reader := bufio.NewReader(resp.Body)
+	var lines []string
+	for len(lines) < 3 {
+		line, err := reader.ReadString('\n')
+		require.NoError(t, err)
+		lines = append(lines, strings.TrimSuffix(line, "\n"))
+	}
+	assert.Equal(t, "id: 1", lines[0])
+	assert.Equal(t, "event: retracted", lines[1])
+	assert.Contains(t, lines[2], `"id":"withdrawn"`)
+}
@@ -0,0 +1,18 @@
+package v1
+
+import (
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"net/http"
+)
+
+/*
+TracingMiddleware traces every request as span named by method and route template.
+
+Trace context of the caller is taken from incoming headers, so the span continues its trace,
+handlers get the span in request context.
+*/
+func TracingMiddleware(next http.Handler) http.Handler {
+	return otelhttp.NewHandler(next, "api", otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+		return r.Method + " " + routeTemplate(r)
+	}))
+}
@@ -0,0 +1,194 @@
+package v1
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"github.com/adamdyszy/sportsnews/internal/webhook"
+	"github.com/adamdyszy/sportsnews/storage"
+	"github.com/adamdyszy/sportsnews/types"
+	"github.com/go-logr/logr"
+	"github.com/gorilla/mux"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+const subscriptionNotFoundMsg = "Webhook subscription not found"
+const invalidSubscriptionMsg = "Body has to be json with http or https url"
+const failFromWebhookStorageMsg = "Failure while using webhook storage"
+const forbiddenWebhookHostMsg = "Webhook url cannot point to loopback, private or unresolvable host"
+
+// lookupIP resolves host of webhook url, it is replaced in tests
+var lookupIP = net.DefaultResolver.LookupIPAddr
+
+func MakeSuccessWebhook(data types.WebhookSubscription) types.WebhookResponse {
+	return types.WebhookResponse{Status: "success", Data: &data}
+}
+
+func MakeErrorWebhook(msg string) types.WebhookResponse {
+	return types.WebhookResponse{Status: "error", Message: msg}
+}
+
+// randomHex returns n random bytes encoded as hex
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// publicHost tells if every address of host is public, deliveries check the address again when they connect
+func publicHost(ctx context.Context, host string) bool {
+	addrs, err := lookupIP(ctx, host)
+	if err != nil || len(addrs) == 0 {
+		return false
+	}
+	for _, addr := range addrs {
+		if !webhook.PublicIP(addr.IP) {
+			return false
+		}
+	}
+	return true
+}
+
+/*
+CreateWebhookHandler registers webhook subscription.
+
+Body is json with url and optional secret, teamId and type filters.
+Url has to resolve only to public addresses, loopback and private hosts are rejected.
+When secret is empty it is generated, response is the only place where secret is returned.
+*/
+func CreateWebhookHandler(ws storage.WebhookStorage, logger logr.Logger) http.HandlerFunc {
+	logger = logger.WithValues("handler", "CreateWebhookHandler")
+	return func(w http.ResponseWriter, r *http.Request) {
+		var s types.WebhookSubscription
+		err := json.NewDecoder(r.Body).Decode(&s)
+		if err != nil {
+			jsonEncodeErrorResponse(w, MakeErrorWebhook(invalidSubscriptionMsg), http.StatusBadRequest, logger)
+			return
+		}
+		u, err := url.ParseRequestURI(s.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			jsonEncodeErrorResponse(w, MakeErrorWebhook(invalidSubscriptionMsg), http.StatusBadRequest, logger)
+			return
+		}
+		if !publicHost(r.Context(), u.Hostname()) {
+			jsonEncodeErrorResponse(w, MakeErrorWebhook(forbiddenWebhookHostMsg), http.StatusBadRequest, logger)
+			return
+		}
+		s.Id, err = randomHex(16)
+		if err == nil && s.Secret == "" {
+			s.Secret, err = randomHex(32)
+		}
+		if err != nil {
+			logger.Error(err, "Could not generate random values.")
+			jsonEncodeErrorResponse(w, MakeErrorWebhook(internalServerErrorMsg), http.StatusInternalServerError, logger)
+			return
+		}
+		s.CreatedAt = time.Now().UTC()
+		err = ws.CreateSubscription(r.Context(), s)
+		if err != nil {
+			logger.Error(err, failFromWebhookStorageMsg)
+			jsonEncodeErrorResponse(w, MakeErrorWebhook(internalServerErrorMsg), http.StatusInternalServerError, logger)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		err = json.NewEncoder(w).Encode(MakeSuccessWebhook(s))
+		if err != nil {
+			logger.Error(err, failJsonEncodeMsg)
+		}
+	}
+}
+
+// ListWebhooksHandler returns all webhook subscriptions without their secrets
+func ListWebhooksHandler(ws storage.WebhookStorage, logger logr.Logger) http.HandlerFunc {
+	logger = logger.WithValues("handler", "ListWebhooksHandler")
+	return func(w http.ResponseWriter, r *http.Request) {
+		subscriptions, err := ws.ListSubscriptions(r.Context())
+		if err != nil {
+			logger.Error(err, failFromWebhookStorageMsg)
+			response := types.WebhookList{Status: "error", Message: internalServerErrorMsg}
+			jsonEncodeErrorResponse(w, response, http.StatusInternalServerError, logger)
+			return
+		}
+		for n := range subscriptions {
+			subscriptions[n].Secret = ""
+		}
+		jsonEncodeSuccessResponse(w, types.WebhookList{Status: "success", Data: subscriptions}, logger)
+	}
+}
+
+// GetWebhookHandler returns webhook subscription without its secret
+func GetWebhookHandler(ws storage.WebhookStorage, logger logr.Logger) http.HandlerFunc {
+	logger = logger.WithValues("handler", "GetWebhookHandler")
+	return func(w http.ResponseWriter, r *http.Request) {
+		s, err := ws.GetSubscription(r.Context(), mux.Vars(r)["id"])
+		if err != nil {
+			if errors.Is(err, storage.SubscriptionNotFound) {
+				jsonEncodeErrorResponse(w, MakeErrorWebhook(subscriptionNotFoundMsg), http.StatusNotFound, logger)
+				return
+			}
+			logger.Error(err, failFromWebhookStorageMsg)
+			jsonEncodeErrorResponse(w, MakeErrorWebhook(internalServerErrorMsg), http.StatusInternalServerError, logger)
+			return
+		}
+		s.Secret = ""
+		jsonEncodeSuccessResponse(w, MakeSuccessWebhook(s), logger)
+	}
+}
+
+// DeleteWebhookHandler removes webhook subscription with its delivery log
+func DeleteWebhookHandler(ws storage.WebhookStorage, logger logr.Logger) http.HandlerFunc {
+	logger = logger.WithValues("handler", "DeleteWebhookHandler")
+	return func(w http.ResponseWriter, r *http.Request) {
+		err := ws.DeleteSubscription(r.Context(), mux.Vars(r)["id"])
+		if err != nil {
+			if errors.Is(err, storage.SubscriptionNotFound) {
+				jsonEncodeErrorResponse(w, MakeErrorWebhook(subscriptionNotFoundMsg), http.StatusNotFound, logger)
+				return
+			}
+			logger.Error(err, failFromWebhookStorageMsg)
+			jsonEncodeErrorResponse(w, MakeErrorWebhook(internalServerErrorMsg), http.StatusInternalServerError, logger)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// ListWebhookDeliveriesHandler returns newest deliveries of subscription, query parameter limit caps their amount
+func ListWebhookDeliveriesHandler(ws storage.WebhookStorage, logger logr.Logger) http.HandlerFunc {
+	logger = logger.WithValues("handler", "ListWebhookDeliveriesHandler")
+	return func(w http.ResponseWriter, r *http.Request) {
+		limit := 0
+		if v := r.URL.Query().Get("limit"); v != "" {
+			var err error
+			limit, err = strconv.Atoi(v)
+			if err != nil || limit <= 0 {
+				response := types.WebhookDeliveryList{Status: "error", Message: invalidLimitMsg}
+				jsonEncodeErrorResponse(w, response, http.StatusBadRequest, logger)
+				return
+			}
+		}
+		deliveries, err := ws.ListDeliveries(r.Context(), mux.Vars(r)["id"], limit)
+		if err != nil {
+			if errors.Is(err, storage.SubscriptionNotFound) {
+				response := types.WebhookDeliveryList{Status: "error", Message: subscriptionNotFoundMsg}
+				jsonEncodeErrorResponse(w, response, http.StatusNotFound, logger)
+				return
+			}
+			logger.Error(err, failFromWebhookStorageMsg)
+			response := types.WebhookDeliveryList{Status: "error", Message: internalServerErrorMsg}
+			jsonEncodeErrorResponse(w, response, http.StatusInternalServerError, logger)
+			return
+		}
+		jsonEncodeSuccessResponse(w, types.WebhookDeliveryList{Status: "success", Data: deliveries}, logger)
+	}
+}
@@ -0,0 +1,48 @@
+package v1
+
+import (
+	"context"
+	"errors"
+	"github.com/adamdyszy/sportsnews/internal/storage/memory"
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCreateWebhookRejectsPrivateHosts(t *testing.T) {
+	lookupIP = func(_ context.Context, host string) ([]net.IPAddr, error) {
+		hosts := map[string]string{"example.com": "93.184.216.34", "intranet.example.com": "10.1.2.3"}
+		if ip, found := hosts[host]; found {
+			return []net.IPAddr{{IP: net.ParseIP(ip)}}, nil
+		}
+		if ip := net.ParseIP(host); ip != nil {
+			return []net.IPAddr{{IP: ip}}, nil
+		}
+		return nil, errors.New("no such host")
+	}
+	defer func() {
+		lookupIP = net.DefaultResolver.LookupIPAddr
+	}()
+	handler := CreateWebhookHandler(memory.NewMemWebhookStorage(10), logr.Discard())
+	create := func(url string) int {
+		rec := httptest.NewRecorder()
+		handler(rec, httptest.NewRequest("POST", "/admin/webhooks", strings.NewReader(`{"url": "`+url+`"}`)))
+		return rec.Code
+	}
+
+	assert.Equal(t, http.StatusCreated, create("https://example.com/hook"))
+	for _, url := range []string{
+		"http://127.0.0.1:8080/admin/poll/list",
+		"http://[::1]/hook",
+		"http://169.254.169.254/latest/meta-data",
+		"http://192.168.1.1/hook",
+		"http://intranet.example.com/hook",
+		"http://unknown.invalid/hook",
+	} {
+		assert.Equal(t, http.StatusBadRequest, create(url), url)
+	}
+}
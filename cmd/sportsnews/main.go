@@ -6,21 +6,45 @@ import (
 	"flag"
 	"fmt"
 	api "github.com/adamdyszy/sportsnews/api/v1"
+	"github.com/adamdyszy/sportsnews/internal/admin"
+	"github.com/adamdyszy/sportsnews/internal/events"
+	"github.com/adamdyszy/sportsnews/internal/metrics"
 	"github.com/adamdyszy/sportsnews/internal/poller"
+	"github.com/adamdyszy/sportsnews/internal/retention"
 	"github.com/adamdyszy/sportsnews/internal/storage/memory"
 	"github.com/adamdyszy/sportsnews/internal/storage/mongo"
+	"github.com/adamdyszy/sportsnews/internal/tracing"
+	"github.com/adamdyszy/sportsnews/internal/webhook"
 	"github.com/adamdyszy/sportsnews/storage"
+	"github.com/go-logr/logr"
 	"github.com/go-logr/zapr"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/spf13/viper"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 )
 
+// exitError is returned by run when the program should exit with code after logging msg
+type exitError struct {
+	code int
+	msg  string
+	err  error
+}
+
+func (e exitError) Error() string {
+	return fmt.Sprintf("%v %v", e.msg, e.err)
+}
+
 func main() {
 	// handle args
 	var customConfigFile string
 	flag.StringVar(&customConfigFile, "customConfigFile", "config/custom.yaml", "Custom config file that will override config/default.yaml")
+	var migrate bool
+	flag.BoolVar(&migrate, "migrate", false, "Apply pending mongo migrations and exit without starting the server")
 	flag.Parse()
 
 	// Create a new Viper configuration object.
@@ -53,47 +77,185 @@ func main() {
 		fmt.Printf("Failed to build logger, error: %s\n", err)
 		os.Exit(3)
 	}
-	defer func(logger *zap.Logger) {
-		err := logger.Sync()
+	logger := zapr.NewLogger(z)
+
+	// run returns only after its deferred cleanup, so exiting here does not skip it
+	exitCode := 0
+	err = run(v, logger, migrate)
+	if err != nil {
+		exitErr := exitError{code: 1, msg: "Failed.", err: err}
+		errors.As(err, &exitErr)
+		logger.Error(exitErr.err, exitErr.msg)
+		exitCode = exitErr.code
+	}
+	err = z.Sync()
+	if err != nil {
+		fmt.Printf("syncing logger failed %v", err)
+	}
+	if exitCode != 0 {
+		os.Exit(exitCode)
+	}
+}
+
+// run starts all components and stops them in order after a signal or server failure
+func run(v *viper.Viper, logger logr.Logger, migrate bool) error {
+	if migrate {
+		applied, err := mongo.Migrate(context.Background(), v.Sub("mongoStorage"))
+		for _, m := range applied {
+			logger.Info("Applied migration.", "version", m.Version, "description", m.Description)
+		}
 		if err != nil {
-			fmt.Printf("syncing logger failed %v", err)
+			return exitError{code: 7, msg: "Could not apply migrations.", err: err}
 		}
-	}(z)
-	logger := zapr.NewLogger(z)
+		logger.Info("Migrations are up to date.", "applied", len(applied))
+		return nil
+	}
 
 	var s storage.ArticleStorage
+	var ws storage.WebhookStorage
+	var bs storage.BackfillStorage
+	var rs storage.RetryStorage
+	var webhookConfig webhook.Config
+	err := v.Sub("webhooks").Unmarshal(&webhookConfig)
+	if err == nil {
+		err = webhookConfig.Validate()
+	}
+	if err != nil {
+		return exitError{code: 4, msg: "Could not read webhooks config.", err: err}
+	}
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
+	var tracingConfig tracing.Config
+	err = v.Sub("tracing").Unmarshal(&tracingConfig)
+	if err != nil {
+		return exitError{code: 4, msg: "Could not read tracing config.", err: err}
+	}
+	shutdownTracing, err := tracing.Setup(ctx, tracingConfig, logger)
+	if err != nil {
+		return exitError{code: 4, msg: "Could not set up tracing.", err: err}
+	}
+	defer func() {
+		err := shutdownTracing(context.Background())
+		if err != nil {
+			logger.Error(err, "Error during shutdown of tracing.")
+		}
+	}()
 	switch v.GetString("storageKind") {
 	case "mongo":
 		s, err = mongo.NewMongoStorage(v.Sub("mongoStorage"), ctx)
 		if err != nil {
-			logger.Error(err, "Could not initialize storage.")
-			os.Exit(4)
+			return exitError{code: 4, msg: "Could not initialize storage.", err: err}
+		}
+		defer disconnect(s, "storage", logger)
+		ws, err = mongo.NewMongoWebhookStorage(s, v.Sub("mongoStorage"), webhookConfig.DeliveryLogSize)
+		if err != nil {
+			return exitError{code: 4, msg: "Could not initialize webhook storage.", err: err}
+		}
+		bs, err = mongo.NewMongoBackfillStorage(s, v.Sub("mongoStorage"))
+		if err != nil {
+			return exitError{code: 4, msg: "Could not initialize backfill storage.", err: err}
+		}
+		rs, err = mongo.NewMongoRetryStorage(s, v.Sub("mongoStorage"))
+		if err != nil {
+			return exitError{code: 4, msg: "Could not initialize retry storage.", err: err}
 		}
 	case "memory", "":
 		s = memory.NewMemStorage()
+		defer disconnect(s, "storage", logger)
+		ws = memory.NewMemWebhookStorage(webhookConfig.DeliveryLogSize)
+		bs = memory.NewMemBackfillStorage()
+		rs = memory.NewMemRetryStorage()
 	default:
-		err = errors.New("unknown database kind")
+		return exitError{code: 4, msg: "Could not initialize storage.", err: errors.New("unknown database kind")}
 	}
+	defer disconnect(ws, "webhook storage", logger)
+	defer disconnect(bs, "backfill storage", logger)
+	defer disconnect(rs, "retry storage", logger)
+	prometheus.MustRegister(metrics.NewArticlesWithoutDetailsGauge(s, logger))
+	s = tracing.NewTracedStorage(metrics.NewInstrumentedStorage(s))
+	broker := events.NewBroker(v.GetInt("events.historySize"))
+	// dispatcher subscribes before the poller starts, so articles created at boot reach webhooks
+	dispatcher := webhook.NewDispatcher(broker, ws, webhookConfig, logger)
+	dispatcher.Start(ctx)
+	pl, err := poller.StartPollerWithConfigFile(ctx, v.Sub("poller"), logger, s, bs, rs, broker)
 	if err != nil {
-		logger.Error(err, "Could not initialize storage.")
-		os.Exit(4)
+		return exitError{code: 5, msg: "Could not start poller.", err: err}
 	}
-	defer func() {
-		err := s.Disconnect()
-		if err != nil {
-			logger.Error(err, "Error during disconnect in storage.")
-		}
+	var retentionConfig retention.Config
+	err = v.Sub("retention").Unmarshal(&retentionConfig)
+	if err == nil {
+		err = retentionConfig.Validate()
+	}
+	if err != nil {
+		return exitError{code: 5, msg: "Could not read retention config.", err: err}
+	}
+	pruner := retention.NewPruner(retentionConfig, s, pl, logger)
+	err = pruner.Start(ctx)
+	if err != nil {
+		return exitError{code: 5, msg: "Could not start pruning.", err: err}
+	}
+	jobs := admin.NewJobs(ctx, v.GetInt("api.admin.historySize"), logger)
+	server, err := api.NewServer(v.Sub("api"), s, broker, ws, rs, pl, jobs, logger)
+	if err != nil {
+		return exitError{code: 6, msg: "Could not create api server.", err: err}
+	}
+	serverErr := make(chan error, 1)
+	go func() {
+		serverErr <- server.ListenAndServe()
 	}()
-	err = poller.StartPollerWithConfigFile(ctx, v.Sub("poller"), logger, s)
+
+	// Wait for a signal or server failure, then stop in order: api, poller, pruning, admin jobs, webhook deliveries and storage
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	var runErr error
+	select {
+	case sig := <-signals:
+		logger.Info("Shutting down.", "signal", sig.String())
+	case err = <-serverErr:
+		runErr = exitError{code: 6, msg: "Could not serve api.", err: err}
+	}
+	timeout := time.Duration(v.GetInt("shutdownTimeoutSeconds")) * time.Second
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), timeout)
+	defer cancelShutdown()
+	err = server.Shutdown(shutdownCtx)
+	if err != nil {
+		logger.Error(err, "Error during shutdown of api server.")
+	}
+	stopTimedOut := false
+	err = pl.Stop(shutdownCtx)
+	if err != nil {
+		logger.Error(err, "Error during stop of poller.")
+		stopTimedOut = true
+	}
+	err = pruner.Stop(shutdownCtx)
 	if err != nil {
-		logger.Error(err, "Could not start poller.")
-		os.Exit(5)
+		logger.Error(err, "Error during stop of pruning.")
+		stopTimedOut = true
 	}
-	err = api.ListenAndServe(v.Sub("api"), s, logger)
+	// jobs and deliveries that do not finish in time are cancelled, they return before storage is disconnected
+	err = jobs.Stop(shutdownCtx)
+	if err != nil {
+		logger.Error(err, "Error during stop of admin jobs.")
+		stopTimedOut = true
+	}
+	if stopTimedOut {
+		// poller and pruning jobs use ctx, cancel it and wait for them as they must not outlive the storage
+		cancel()
+		_ = pl.Stop(context.Background())
+		_ = pruner.Stop(context.Background())
+		logger.Info("Cancelled jobs returned.")
+	}
+	err = dispatcher.Stop(shutdownCtx)
+	if err != nil {
+		logger.Error(err, "Error during stop of webhook dispatcher.")
+	}
+	return runErr
+}
+
+// disconnect disconnects storage named name, it is deferred so storages are disconnected after everything using them stopped
+func disconnect(s interface{ Disconnect() error }, name string, logger logr.Logger) {
+	err := s.Disconnect()
 	if err != nil {
-		logger.Error(err, "Could not server api.")
-		os.Exit(6)
+		logger.Error(err, fmt.Sprintf("Error during disconnect in %v.", name))
 	}
 }
@@ -0,0 +1,130 @@
+// Package admin runs polls requested through admin api in the background
+package admin
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/adamdyszy/sportsnews/internal/tracing"
+	"github.com/adamdyszy/sportsnews/types"
+	"github.com/go-logr/logr"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"sync"
+	"time"
+)
+
+var JobNotFound = errors.New("admin job not found")
+
+// JobFunc is work done by the job, returned summary is saved even when error is returned
+type JobFunc func(ctx context.Context) (types.PollSummary, error)
+
+/*
+Jobs runs admin jobs in the background and remembers historySize last of them,
+so their result can be checked after they finish.
+*/
+type Jobs struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	// running are jobs that did not finish yet
+	running     sync.WaitGroup
+	mx          sync.RWMutex
+	jobs        map[string]*types.AdminJob
+	order       []string
+	historySize int
+	logger      logr.Logger
+}
+
+// NewJobs creates Jobs, jobs are cancelled when ctx is done
+func NewJobs(ctx context.Context, historySize int, logger logr.Logger) *Jobs {
+	ctx, cancel := context.WithCancel(ctx)
+	return &Jobs{
+		ctx:         ctx,
+		cancel:      cancel,
+		jobs:        make(map[string]*types.AdminJob),
+		historySize: historySize,
+		logger:      logger.WithValues("workerKind", "AdminJobs"),
+	}
+}
+
+// Start runs fn in the background and returns the started job
+func (j *Jobs) Start(kind string, fn JobFunc) (types.AdminJob, error) {
+	b := make([]byte, 16)
+	_, err := rand.Read(b)
+	if err != nil {
+		return types.AdminJob{}, fmt.Errorf("could not generate job id: %w", err)
+	}
+	job := &types.AdminJob{
+		Id:        hex.EncodeToString(b),
+		Kind:      kind,
+		Status:    types.JobRunning,
+		StartedAt: time.Now().UTC(),
+	}
+	j.mx.Lock()
+	j.jobs[job.Id] = job
+	j.order = append(j.order, job.Id)
+	for len(j.order) > j.historySize && j.historySize > 0 {
+		delete(j.jobs, j.order[0])
+		j.order = j.order[1:]
+	}
+	started := *job
+	j.mx.Unlock()
+
+	logger := j.logger.WithValues("jobId", job.Id, "jobKind", kind)
+	logger.Info("Starting admin job.")
+	j.running.Add(1)
+	go func() {
+		defer j.running.Done()
+		ctx, span := tracing.Tracer().Start(j.ctx, "admin."+kind, trace.WithAttributes(attribute.String("job.id", job.Id)))
+		summary, err := fn(ctx)
+		tracing.End(span, err)
+		finishedAt := time.Now().UTC()
+		j.mx.Lock()
+		defer j.mx.Unlock()
+		job.FinishedAt = &finishedAt
+		job.Summary = &summary
+		job.Status = types.JobFinished
+		if err != nil {
+			job.Status = types.JobFailed
+			job.Error = err.Error()
+			logger.Error(err, "Admin job failed.", "summary", summary)
+			return
+		}
+		logger.Info("Finished admin job.", "summary", summary)
+	}()
+	return started, nil
+}
+
+// Get returns copy of the job with given id
+func (j *Jobs) Get(id string) (types.AdminJob, error) {
+	j.mx.RLock()
+	defer j.mx.RUnlock()
+	job, found := j.jobs[id]
+	if !found {
+		return types.AdminJob{}, fmt.Errorf("%w with id: %v", JobNotFound, id)
+	}
+	return *job, nil
+}
+
+/*
+Stop waits for running jobs to finish, so they are not cut off by disconnected storage.
+
+When ctx is done first jobs are cancelled and error is returned after they return.
+*/
+func (j *Jobs) Stop(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		j.running.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		j.cancel()
+		<-done
+		return fmt.Errorf("running admin jobs did not finish: %w", ctx.Err())
+	}
+}
@@ -0,0 +1,90 @@
+package admin
+
+import (
+	"context"
+	"errors"
+	"github.com/adamdyszy/sportsnews/types"
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func waitForJob(t *testing.T, jobs *Jobs, id string) types.AdminJob {
+	var job types.AdminJob
+	require.Eventually(t, func() bool {
+		var err error
+		job, err = jobs.Get(id)
+		require.NoError(t, err)
+		return job.Status != types.JobRunning
+	}, time.Second, time.Millisecond)
+	return job
+}
+
+func TestJobs(t *testing.T) {
+	jobs := NewJobs(context.Background(), 2, logr.Discard())
+
+	release := make(chan struct{})
+	job, err := jobs.Start("pollList", func(ctx context.Context) (types.PollSummary, error) {
+		<-release
+		return types.PollSummary{Saved: 2, Skipped: 1}, nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, types.JobRunning, job.Status)
+	assert.Nil(t, job.Summary)
+	close(release)
+	job = waitForJob(t, jobs, job.Id)
+	assert.Equal(t, types.JobFinished, job.Status)
+	assert.Equal(t, &types.PollSummary{Saved: 2, Skipped: 1}, job.Summary)
+	assert.NotNil(t, job.FinishedAt)
+
+	failed, err := jobs.Start("pollDetails", func(ctx context.Context) (types.PollSummary, error) {
+		return types.PollSummary{Failed: 1}, errors.New("storage is down")
+	})
+	require.NoError(t, err)
+	failed = waitForJob(t, jobs, failed.Id)
+	assert.Equal(t, types.JobFailed, failed.Status)
+	assert.Equal(t, "storage is down", failed.Error)
+	assert.Equal(t, &types.PollSummary{Failed: 1}, failed.Summary)
+
+	// only historySize last jobs are remembered
+	_, err = jobs.Start("pollList", func(ctx context.Context) (types.PollSummary, error) {
+		return types.PollSummary{}, nil
+	})
+	require.NoError(t, err)
+	_, err = jobs.Get(job.Id)
+	assert.ErrorIs(t, err, JobNotFound)
+	_, err = jobs.Get(failed.Id)
+	assert.NoError(t, err)
+}
+
+func TestJobsStop(t *testing.T) {
+	jobs := NewJobs(context.Background(), 2, logr.Discard())
+	release := make(chan struct{})
+	job, err := jobs.Start("pollList", func(ctx context.Context) (types.PollSummary, error) {
+		<-release
+		return types.PollSummary{Saved: 1}, nil
+	})
+	require.NoError(t, err)
+	time.AfterFunc(50*time.Millisecond, func() {
+		close(release)
+	})
+	require.NoError(t, jobs.Stop(context.Background()))
+	job, err = jobs.Get(job.Id)
+	require.NoError(t, err)
+	assert.Equal(t, types.JobFinished, job.Status, "stop should wait for running job")
+
+	// job that does not finish in time is cancelled
+	job, err = jobs.Start("pollDetails", func(ctx context.Context) (types.PollSummary, error) {
+		<-ctx.Done()
+		return types.PollSummary{}, ctx.Err()
+	})
+	require.NoError(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, jobs.Stop(ctx), context.DeadlineExceeded)
+	job, err = jobs.Get(job.Id)
+	require.NoError(t, err)
+	assert.Equal(t, types.JobFailed, job.Status)
+}
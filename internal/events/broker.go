@@ -0,0 +1,117 @@
+// Package events lets the poller tell other parts of the application about saved articles
+package events
+
+import (
+	"github.com/adamdyszy/sportsnews/types"
+	"sync"
+	"time"
+)
+
+// Kind tells what happened with the article
+type Kind string
+
+const (
+	// ArticleCreated is published when article is saved for the first time
+	ArticleCreated Kind = "created"
+	// ArticleUpgraded is published when article without details is replaced with the one having them,
+	// or when article is replaced with version edited upstream or refreshed by admin
+	ArticleUpgraded Kind = "upgraded"
+	// ArticleRetracted is published when article is withdrawn upstream and its tombstone is saved
+	ArticleRetracted Kind = "retracted"
+)
+
+// Event is single change of the article, Id grows with every published event
+type Event struct {
+	Id      uint64
+	Kind    Kind
+	Time    time.Time
+	Article types.Article
+}
+
+// Publisher is used by the poller to announce saved articles
+type Publisher interface {
+	Publish(kind Kind, article types.Article) Event
+}
+
+// subscriberBuffer is how many events can wait for slow subscriber before it is dropped
+const subscriberBuffer = 64
+
+/*
+Broker passes published events to all subscribers.
+
+It remembers last historySize events, so subscriber that reconnects
+can get events it missed since the last one it has seen.
+*/
+type Broker struct {
+	mx          sync.Mutex
+	lastId      uint64
+	history     []Event
+	historySize int
+	subscribers map[chan Event]struct{}
+}
+
+// NewBroker creates Broker remembering historySize last events
+func NewBroker(historySize int) *Broker {
+	return &Broker{
+		historySize: historySize,
+		subscribers: make(map[chan Event]struct{}),
+	}
+}
+
+/*
+Publish sends event to all subscribers.
+
+Subscriber that cannot keep up has its channel closed,
+it can subscribe again and resume from the last event it got.
+*/
+func (b *Broker) Publish(kind Kind, article types.Article) Event {
+	b.mx.Lock()
+	defer b.mx.Unlock()
+	b.lastId++
+	e := Event{Id: b.lastId, Kind: kind, Time: time.Now(), Article: article}
+	if b.historySize > 0 {
+		if len(b.history) == b.historySize {
+			b.history = append(b.history[:0], b.history[1:]...)
+		}
+		b.history = append(b.history, e)
+	}
+	for ch := range b.subscribers {
+		select {
+		case ch <- e:
+		default:
+			delete(b.subscribers, ch)
+			close(ch)
+		}
+	}
+	return e
+}
+
+/*
+Subscribe registers new subscriber.
+
+It returns remembered events published after lastEventId (zero means none are replayed),
+channel with next events and function that has to be called when subscriber is done.
+*/
+func (b *Broker) Subscribe(lastEventId uint64) ([]Event, <-chan Event, func()) {
+	b.mx.Lock()
+	defer b.mx.Unlock()
+	var missed []Event
+	if lastEventId > 0 {
+		for _, e := range b.history {
+			if e.Id > lastEventId {
+				missed = append(missed, e)
+			}
+		}
+	}
+	ch := make(chan Event, subscriberBuffer)
+	b.subscribers[ch] = struct{}{}
+	unsubscribe := func() {
+		b.mx.Lock()
+		defer b.mx.Unlock()
+		if _, found := b.subscribers[ch]; found {
+			delete(b.subscribers, ch)
+			close(ch)
+		}
+	}
+	return missed, ch, unsubscribe
+}
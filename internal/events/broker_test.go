@@ -0,0 +1,48 @@
+package events
+
+import (
+	"github.com/adamdyszy/sportsnews/types"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestBrokerResume(t *testing.T) {
+	b := NewBroker(3)
+	for n := 0; n < 5; n++ {
+		b.Publish(ArticleCreated, types.Article{Id: types.ArticleId(rune('a' + n))})
+	}
+
+	missed, ch, unsubscribe := b.Subscribe(3)
+	assert.Len(t, missed, 2)
+	assert.Equal(t, uint64(4), missed[0].Id)
+	assert.Equal(t, uint64(5), missed[1].Id)
+
+	missed, _, unsubscribeAll := b.Subscribe(0)
+	assert.Empty(t, missed, "zero last event id should not replay history")
+	unsubscribeAll()
+
+	missed, _, unsubscribeOld := b.Subscribe(1)
+	assert.Len(t, missed, 3, "only remembered events can be replayed")
+	unsubscribeOld()
+
+	e := b.Publish(ArticleUpgraded, types.Article{Id: "f"})
+	got := <-ch
+	assert.Equal(t, e, got)
+	unsubscribe()
+	_, open := <-ch
+	assert.False(t, open)
+}
+
+func TestBrokerDropsSlowSubscriber(t *testing.T) {
+	b := NewBroker(0)
+	_, ch, unsubscribe := b.Subscribe(0)
+	defer unsubscribe()
+	for n := 0; n <= subscriberBuffer; n++ {
+		b.Publish(ArticleCreated, types.Article{})
+	}
+	received := 0
+	for range ch {
+		received++
+	}
+	assert.Equal(t, subscriberBuffer, received)
+}
@@ -0,0 +1,96 @@
+// Package metrics defines prometheus metrics of the application, they are served by api at /metrics
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const namespace = "sportsnews"
+
+// Results of polled articles used as result label of PollerArticles
+const (
+	ResultCreated    = "created"
+	ResultUpgraded   = "upgraded"
+	ResultUpdated    = "updated" // edited upstream and queued for details again
+	ResultRetracted  = "retracted"
+	ResultBackfilled = "backfilled" // saved by historical backfill
+	ResultSkipped    = "skipped"
+	ResultFailed     = "failed"
+)
+
+var (
+	// HTTPRequests counts served api requests by route template, method and status code
+	HTTPRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "requests_total",
+		Help:      "Number of served api requests.",
+	}, []string{"route", "method", "code"})
+	// HTTPRequestDuration observes how long api requests took, including whole lifetime of streams
+	HTTPRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "Latency of api requests.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method"})
+
+	// PollerFetchDuration observes requests to news providers by host and path
+	PollerFetchDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "poller",
+		Name:      "fetch_duration_seconds",
+		Help:      "Latency of requests to news providers.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"host", "path"})
+	// PollerUpstreamResponses counts responses of news providers by status code
+	PollerUpstreamResponses = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "poller",
+		Name:      "upstream_responses_total",
+		Help:      "Number of news provider responses by status code.",
+	}, []string{"host", "path", "code"})
+	// PollerDecodeFailures counts news provider responses that were not valid XML
+	PollerDecodeFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "poller",
+		Name:      "decode_failures_total",
+		Help:      "Number of news provider responses that could not be decoded.",
+	}, []string{"host", "path"})
+	// PollerArticles counts polled articles by team and result, skipped articles were already stored
+	PollerArticles = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "poller",
+		Name:      "articles_total",
+		Help:      "Number of polled articles by result.",
+	}, []string{"teamId", "result"})
+
+	// StorageOperationDuration observes calls of storage methods
+	StorageOperationDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "storage",
+		Name:      "operation_duration_seconds",
+		Help:      "Latency of storage operations.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"operation"})
+	// StorageOperationErrors counts failed storage calls, not found and already existing articles are not failures
+	StorageOperationErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "storage",
+		Name:      "operation_errors_total",
+		Help:      "Number of failed storage operations.",
+	}, []string{"operation"})
+)
+
+func init() {
+	prometheus.MustRegister(
+		HTTPRequests,
+		HTTPRequestDuration,
+		PollerFetchDuration,
+		PollerUpstreamResponses,
+		PollerDecodeFailures,
+		PollerArticles,
+		StorageOperationDuration,
+		StorageOperationErrors,
+	)
+}
@@ -0,0 +1,153 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"github.com/adamdyszy/sportsnews/storage"
+	"github.com/adamdyszy/sportsnews/types"
+	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus"
+	"math"
+	"time"
+)
+
+// instrumentedStorage records latency and errors of every call to wrapped storage
+type instrumentedStorage struct {
+	s storage.ArticleStorage
+}
+
+// NewInstrumentedStorage wraps s, so its calls are observed by StorageOperationDuration and StorageOperationErrors
+func NewInstrumentedStorage(s storage.ArticleStorage) storage.ArticleStorage {
+	return instrumentedStorage{s: s}
+}
+
+// observe records call of operation that started at start and ended with err
+func observe(operation string, start time.Time, err error) {
+	StorageOperationDuration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+	if err != nil && !errors.Is(err, storage.ArticleNotFound) && !errors.Is(err, storage.ArticleAlreadyExists) {
+		StorageOperationErrors.WithLabelValues(operation).Inc()
+	}
+}
+
+func (i instrumentedStorage) Get(ctx context.Context, id types.ArticleId) (types.Article, error) {
+	start := time.Now()
+	a, err := i.s.Get(ctx, id)
+	observe("get", start, err)
+	return a, err
+}
+
+func (i instrumentedStorage) GetNewsWithoutDetailsIDs(ctx context.Context, teamId string) ([]string, error) {
+	start := time.Now()
+	ids, err := i.s.GetNewsWithoutDetailsIDs(ctx, teamId)
+	observe("getNewsWithoutDetailsIDs", start, err)
+	return ids, err
+}
+
+func (i instrumentedStorage) List(ctx context.Context) ([]types.Article, error) {
+	start := time.Now()
+	articles, err := i.s.List(ctx)
+	observe("list", start, err)
+	return articles, err
+}
+
+func (i instrumentedStorage) Count(ctx context.Context, f storage.ArticleFilter) (int, error) {
+	start := time.Now()
+	count, err := i.s.Count(ctx, f)
+	observe("count", start, err)
+	return count, err
+}
+
+func (i instrumentedStorage) ListPage(ctx context.Context, q storage.PageQuery) (storage.ArticlePage, error) {
+	start := time.Now()
+	page, err := i.s.ListPage(ctx, q)
+	observe("listPage", start, err)
+	return page, err
+}
+
+func (i instrumentedStorage) Search(ctx context.Context, q storage.SearchQuery) ([]storage.SearchResult, error) {
+	start := time.Now()
+	results, err := i.s.Search(ctx, q)
+	observe("search", start, err)
+	return results, err
+}
+
+func (i instrumentedStorage) Write(ctx context.Context, a types.Article) error {
+	start := time.Now()
+	err := i.s.Write(ctx, a)
+	observe("write", start, err)
+	return err
+}
+
+func (i instrumentedStorage) ListRevisions(ctx context.Context, id types.ArticleId) ([]types.ArticleRevision, error) {
+	start := time.Now()
+	revisions, err := i.s.ListRevisions(ctx, id)
+	observe("listRevisions", start, err)
+	return revisions, err
+}
+
+func (i instrumentedStorage) Replace(ctx context.Context, a types.Article) error {
+	start := time.Now()
+	err := i.s.Replace(ctx, a)
+	observe("replace", start, err)
+	return err
+}
+
+func (i instrumentedStorage) Delete(ctx context.Context, id types.ArticleId) error {
+	start := time.Now()
+	err := i.s.Delete(ctx, id)
+	observe("delete", start, err)
+	return err
+}
+
+func (i instrumentedStorage) DeleteUnpinned(ctx context.Context, id types.ArticleId) error {
+	start := time.Now()
+	err := i.s.DeleteUnpinned(ctx, id)
+	observe("deleteUnpinned", start, err)
+	return err
+}
+
+func (i instrumentedStorage) SetPinned(ctx context.Context, id types.ArticleId, pinned bool) error {
+	start := time.Now()
+	err := i.s.SetPinned(ctx, id, pinned)
+	observe("setPinned", start, err)
+	return err
+}
+
+func (i instrumentedStorage) SetUpdatedAt(ctx context.Context, id types.ArticleId, updatedAt time.Time) error {
+	start := time.Now()
+	err := i.s.SetUpdatedAt(ctx, id, updatedAt)
+	observe("setUpdatedAt", start, err)
+	return err
+}
+
+func (i instrumentedStorage) Ping(ctx context.Context) error {
+	start := time.Now()
+	err := i.s.Ping(ctx)
+	observe("ping", start, err)
+	return err
+}
+
+func (i instrumentedStorage) Disconnect() error {
+	return i.s.Disconnect()
+}
+
+/*
+NewArticlesWithoutDetailsGauge creates gauge of news still waiting for details.
+
+Its value is got from GetNewsWithoutDetailsIDs of s whenever metrics are scraped.
+*/
+func NewArticlesWithoutDetailsGauge(s storage.ArticleStorage, logger logr.Logger) prometheus.GaugeFunc {
+	return prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "storage",
+		Name:      "articles_without_details",
+		Help:      "Number of stored articles that are still missing details.",
+	}, func() float64 {
+		ids, err := s.GetNewsWithoutDetailsIDs(context.Background(), "")
+		if err != nil {
+			logger.Error(err, "Could not get IDs of news without details for metrics.")
+			return math.NaN()
+		}
+		return float64(len(ids))
+	})
+}
@@ -0,0 +1,31 @@
+package metrics
+
+import (
+	"context"
+	"github.com/adamdyszy/sportsnews/internal/storage/memory"
+	"github.com/adamdyszy/sportsnews/storage"
+	"github.com/adamdyszy/sportsnews/types"
+	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestInstrumentedStorage(t *testing.T) {
+	mem := memory.NewMemStorage()
+	s := NewInstrumentedStorage(mem)
+	gauge := NewArticlesWithoutDetailsGauge(s, logr.Discard())
+	writeErrors := testutil.ToFloat64(StorageOperationErrors.WithLabelValues("write"))
+
+	a := types.Article{Id: "a1", ArticleKey: types.ArticleKey{NewsId: "1", TeamId: "t94", Published: time.Now()}}
+	require.NoError(t, s.Write(context.Background(), a))
+	assert.ErrorIs(t, s.Write(context.Background(), a), storage.ArticleAlreadyExists)
+	_, err := s.Get(context.Background(), a.Id)
+	assert.NoError(t, err)
+
+	// already existing article is expected result, not failure
+	assert.Equal(t, writeErrors, testutil.ToFloat64(StorageOperationErrors.WithLabelValues("write")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(gauge))
+}
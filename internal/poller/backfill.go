@@ -0,0 +1,194 @@
+package poller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/adamdyszy/sportsnews/internal/metrics"
+	"github.com/adamdyszy/sportsnews/internal/tracing"
+	"github.com/adamdyszy/sportsnews/storage"
+	"github.com/adamdyszy/sportsnews/types"
+	"github.com/go-logr/logr"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"strconv"
+	"time"
+)
+
+// Reasons why backfill stopped, saved in types.BackfillProgress.StopReason
+const (
+	backfillReachedFirstId  = "reached the first news id"
+	backfillReachedOldest   = "reached news older than oldestPublished"
+	backfillTooManyMisses   = "too many news ids in a row had no news"
+	backfillNoStoredNewsIds = "there are no stored news ids to start from"
+)
+
+// backfillRetryWait is how long poller waits before it runs backfill again after it failed
+const backfillRetryWait = time.Minute
+
+/*
+BackfillIntoStorage saves news older than the ones got by list polling, which cannot list more than 100 latest news.
+
+It walks news ids downward from the lowest stored NewsId of the team and fetches their details,
+at most config.RequestsPerSecond times per second. Progress is saved after every news id,
+so backfill resumes where it stopped after restart. It stops when it reaches the first news id,
+news published before config.OldestPublished or config.MaxMisses news ids in a row without news.
+Other failures end the run with error, but they do not end backfill, next run resumes from the same news id.
+Backfilled articles are not published as events, since they are not new.
+
+Neither of the providers can list news by date, so walking news ids is the only way back in time.
+*/
+func BackfillIntoStorage(
+	ctx context.Context,
+	teamId string,
+	provider NewsProvider,
+	config BackfillConfig,
+	logger logr.Logger,
+	s storage.ArticleStorage,
+	bs storage.BackfillStorage,
+) (progress types.BackfillProgress, err error) {
+	ctx, span := tracing.Tracer().Start(ctx, "poller.backfill", trace.WithAttributes(attribute.String("team.id", teamId)))
+	defer func() {
+		span.SetAttributes(attribute.Int("backfill.saved", progress.Saved), attribute.Int("backfill.nextNewsId", progress.NextNewsId))
+		tracing.End(span, err)
+	}()
+	logger = logger.WithValues("workerJob", "Backfill")
+	oldest, err := config.oldestPublished()
+	if err != nil {
+		return progress, err
+	}
+	progress, err = startingProgress(ctx, teamId, s, bs)
+	if err != nil {
+		logger.Error(err, "Could not get backfill progress.")
+		return progress, err
+	}
+	if progress.Done {
+		logger.Info("Backfill is already done.", "progress", progress)
+		return progress, nil
+	}
+	logger.Info("Starting backfill.", "nextNewsId", progress.NextNewsId)
+	ticker := time.NewTicker(time.Duration(float64(time.Second) / config.RequestsPerSecond))
+	defer ticker.Stop()
+	for !progress.Done {
+		switch {
+		case progress.NextNewsId < 1:
+			progress.Done, progress.StopReason = true, backfillReachedFirstId
+		case progress.Misses >= config.MaxMisses:
+			progress.Done, progress.StopReason = true, backfillTooManyMisses
+		default:
+			select {
+			case <-ctx.Done():
+				logger.Info("Stopping backfill, it was cancelled.", "progress", progress)
+				return progress, ctx.Err()
+			case <-ticker.C:
+			}
+			err = backfillNewsId(ctx, provider, oldest, logger, s, &progress)
+			if err != nil {
+				logger.Error(err, "Could not backfill news.", "newsId", progress.NextNewsId)
+				return progress, err
+			}
+		}
+		progress.UpdatedAt = time.Now().UTC()
+		err = bs.SaveBackfillProgress(ctx, progress)
+		if err != nil {
+			logger.Error(err, "Could not save backfill progress.")
+			return progress, err
+		}
+	}
+	logger.Info("Backfill is done.", "progress", progress)
+	return progress, nil
+}
+
+// startingProgress returns saved progress of the team, or new one starting below the lowest stored news id
+func startingProgress(ctx context.Context, teamId string, s storage.ArticleStorage, bs storage.BackfillStorage) (types.BackfillProgress, error) {
+	progress, err := bs.GetBackfillProgress(ctx, teamId)
+	if err == nil || !errors.Is(err, storage.BackfillProgressNotFound) {
+		return progress, err
+	}
+	lowest, err := lowestNewsId(ctx, teamId, s)
+	if err != nil {
+		return progress, err
+	}
+	progress = types.BackfillProgress{TeamId: teamId, NextNewsId: lowest - 1}
+	if lowest == 0 {
+		// nothing to start from yet, this progress is not saved so the next start tries again
+		progress.Done, progress.StopReason = true, backfillNoStoredNewsIds
+	}
+	return progress, nil
+}
+
+// lowestNewsId returns the lowest numeric news id of stored team articles, 0 when there is none
+func lowestNewsId(ctx context.Context, teamId string, s storage.ArticleStorage) (int, error) {
+	lowest := 0
+	q := storage.PageQuery{Limit: 100, Filter: storage.ArticleFilter{TeamId: teamId}}
+	for {
+		page, err := s.ListPage(ctx, q)
+		if err != nil {
+			return 0, err
+		}
+		for _, a := range page.Articles {
+			newsId, err := strconv.Atoi(a.NewsId)
+			if err != nil || newsId < 1 {
+				continue
+			}
+			if lowest == 0 || newsId < lowest {
+				lowest = newsId
+			}
+		}
+		if !page.HasMore {
+			return lowest, nil
+		}
+		q.Cursor = page.NextCursor
+	}
+}
+
+/*
+backfillNewsId fetches and saves news with progress.NextNewsId and moves progress to the next one.
+
+Only NewsNotFound counts as miss. Other errors, like failed requests, 5xx responses or failed writes,
+are returned and progress stays at the same news id, so the next run tries it again.
+*/
+func backfillNewsId(ctx context.Context, provider NewsProvider, oldest time.Time, logger logr.Logger, s storage.ArticleStorage, progress *types.BackfillProgress) error {
+	newsId := strconv.Itoa(progress.NextNewsId)
+	logger = logger.WithValues("newsId", newsId)
+	article, err := provider.FetchDetails(ctx, newsId)
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if !errors.Is(err, NewsNotFound) {
+			return fmt.Errorf("could not fetch news with newsId %v: %w", newsId, err)
+		}
+		logger.V(1).Info("There is no news with this id.", "reason", err.Error())
+		progress.Misses++
+		progress.NextNewsId--
+		return nil
+	}
+	progress.Misses = 0
+	if article.Published.Before(oldest) {
+		progress.Done, progress.StopReason = true, backfillReachedOldest
+		return nil
+	}
+	if article.RetractedAt != nil {
+		// withdrawn news was never seen by list polling, so there is nothing to keep
+		progress.Skipped++
+		metrics.PollerArticles.WithLabelValues(article.TeamId, metrics.ResultSkipped).Inc()
+		progress.NextNewsId--
+		return nil
+	}
+	err = s.Write(ctx, article)
+	switch {
+	case err == nil:
+		progress.Saved++
+		metrics.PollerArticles.WithLabelValues(article.TeamId, metrics.ResultBackfilled).Inc()
+		logger.Info("Saved backfilled article.", "articleID", article.Id, "published", article.Published)
+	case errors.Is(err, storage.ArticleAlreadyExists):
+		progress.Skipped++
+		metrics.PollerArticles.WithLabelValues(article.TeamId, metrics.ResultSkipped).Inc()
+	default:
+		metrics.PollerArticles.WithLabelValues(article.TeamId, metrics.ResultFailed).Inc()
+		return fmt.Errorf("could not write article with newsId %v: %w", newsId, err)
+	}
+	progress.NextNewsId--
+	return nil
+}
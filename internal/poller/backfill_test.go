@@ -0,0 +1,155 @@
+package poller
+
+import (
+	"context"
+	"github.com/adamdyszy/sportsnews/internal/storage/memory"
+	"github.com/adamdyszy/sportsnews/storage"
+	"github.com/adamdyszy/sportsnews/storage/storagetest"
+	"github.com/adamdyszy/sportsnews/types"
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"testing"
+	"time"
+)
+
+var testBackfillConfig = BackfillConfig{Enabled: true, RequestsPerSecond: 1000, MaxMisses: 3}
+
+// detailsOf returns details of fakeProvider with article of every given news id
+func detailsOf(t *testing.T, newsIds ...int) map[string]types.Article {
+	details := make(map[string]types.Article, len(newsIds))
+	for _, newsId := range newsIds {
+		a := storagetest.NewArticle(t, "t94", newsId, true)
+		details[a.NewsId] = a
+	}
+	return details
+}
+
+func TestBackfill(t *testing.T) {
+	ctx := context.Background()
+	s := memory.NewMemStorage()
+	bs := memory.NewMemBackfillStorage()
+	require.NoError(t, s.Write(ctx, storagetest.NewArticle(t, "t94", 20, false)))
+	provider := &fakeProvider{details: detailsOf(t, 19, 18, 16, 15)}
+	retracted := provider.details["15"]
+	retracted.RetractedAt = &retracted.Published
+	provider.details["15"] = retracted
+
+	progress, err := BackfillIntoStorage(ctx, "t94", provider, testBackfillConfig, logr.Discard(), s, bs)
+	require.NoError(t, err)
+	// 15 is retracted, the walk stops after 3 news ids without news
+	assert.Equal(t, []string{"19", "18", "17", "16", "15", "14", "13", "12"}, provider.fetched)
+	assert.Equal(t, 11, progress.NextNewsId)
+	assert.Equal(t, 3, progress.Saved)
+	assert.Equal(t, 1, progress.Skipped)
+	assert.True(t, progress.Done)
+	assert.Equal(t, backfillTooManyMisses, progress.StopReason)
+	saved, err := bs.GetBackfillProgress(ctx, "t94")
+	require.NoError(t, err)
+	assert.Equal(t, progress, saved)
+	for _, newsId := range []string{"19", "18", "16"} {
+		a, err := s.Get(ctx, provider.details[newsId].Id)
+		require.NoError(t, err)
+		assert.True(t, a.HasDetails)
+	}
+	_, err = s.Get(ctx, retracted.Id)
+	assert.ErrorIs(t, err, storage.ArticleNotFound)
+
+	// done backfill does not fetch again
+	provider.fetched = nil
+	_, err = BackfillIntoStorage(ctx, "t94", provider, testBackfillConfig, logr.Discard(), s, bs)
+	require.NoError(t, err)
+	assert.Empty(t, provider.fetched)
+}
+
+func TestBackfillResumes(t *testing.T) {
+	ctx := context.Background()
+	s := memory.NewMemStorage()
+	bs := memory.NewMemBackfillStorage()
+	require.NoError(t, bs.SaveBackfillProgress(ctx, types.BackfillProgress{TeamId: "t94", NextNewsId: 3, Saved: 5}))
+	provider := &fakeProvider{details: detailsOf(t, 3, 2, 1)}
+	// saved before progress of it, when backfill was stopped
+	require.NoError(t, s.Write(ctx, provider.details["3"]))
+
+	progress, err := BackfillIntoStorage(ctx, "t94", provider, testBackfillConfig, logr.Discard(), s, bs)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"3", "2", "1"}, provider.fetched)
+	assert.Equal(t, 7, progress.Saved)
+	assert.Equal(t, 1, progress.Skipped)
+	assert.Equal(t, backfillReachedFirstId, progress.StopReason)
+}
+
+func TestBackfillOldestPublished(t *testing.T) {
+	ctx := context.Background()
+	s := memory.NewMemStorage()
+	bs := memory.NewMemBackfillStorage()
+	require.NoError(t, s.Write(ctx, storagetest.NewArticle(t, "t94", 10, false)))
+	// news 9 is published 9 minutes after 2023-02-17
+	provider := &fakeProvider{details: detailsOf(t, 9, 8)}
+	config := testBackfillConfig
+	config.OldestPublished = "2023-02-18"
+
+	progress, err := BackfillIntoStorage(ctx, "t94", provider, config, logr.Discard(), s, bs)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"9"}, provider.fetched)
+	assert.Equal(t, 0, progress.Saved)
+	assert.Equal(t, 9, progress.NextNewsId)
+	assert.Equal(t, backfillReachedOldest, progress.StopReason)
+}
+
+func TestBackfillNoStoredNews(t *testing.T) {
+	ctx := context.Background()
+	bs := memory.NewMemBackfillStorage()
+	provider := &fakeProvider{}
+
+	progress, err := BackfillIntoStorage(ctx, "t94", provider, testBackfillConfig, logr.Discard(), memory.NewMemStorage(), bs)
+	require.NoError(t, err)
+	assert.Equal(t, backfillNoStoredNewsIds, progress.StopReason)
+	assert.Empty(t, provider.fetched)
+	// progress is not saved, so backfill starts once list polling stores some news
+	_, err = bs.GetBackfillProgress(ctx, "t94")
+	assert.ErrorIs(t, err, storage.BackfillProgressNotFound)
+}
+
+func TestBackfillCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	s := memory.NewMemStorage()
+	bs := memory.NewMemBackfillStorage()
+	require.NoError(t, s.Write(ctx, storagetest.NewArticle(t, "t94", 10, false)))
+	cancel()
+
+	config := testBackfillConfig
+	config.RequestsPerSecond = 1.0 / 3600
+	start := time.Now()
+	progress, err := BackfillIntoStorage(ctx, "t94", &fakeProvider{}, config, logr.Discard(), s, bs)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Less(t, time.Since(start), time.Minute, "cancelled backfill does not wait for rate limit")
+	assert.Equal(t, 9, progress.NextNewsId)
+	assert.False(t, progress.Done)
+}
+
+func TestBackfillUpstreamFailure(t *testing.T) {
+	ctx := context.Background()
+	s := memory.NewMemStorage()
+	bs := memory.NewMemBackfillStorage()
+	require.NoError(t, s.Write(ctx, storagetest.NewArticle(t, "t94", 10, false)))
+	require.NoError(t, bs.SaveBackfillProgress(ctx, types.BackfillProgress{TeamId: "t94", NextNewsId: 9, Misses: 2}))
+	provider := &fakeProvider{details: detailsOf(t, 9), err: StatusError{Code: http.StatusServiceUnavailable}}
+
+	// failed requests are not misses, progress stays at the same news id
+	progress, err := BackfillIntoStorage(ctx, "t94", provider, testBackfillConfig, logr.Discard(), s, bs)
+	assert.ErrorAs(t, err, &StatusError{})
+	assert.False(t, progress.Done)
+	saved, err := bs.GetBackfillProgress(ctx, "t94")
+	require.NoError(t, err)
+	assert.Equal(t, types.BackfillProgress{TeamId: "t94", NextNewsId: 9, Misses: 2}, saved)
+
+	// next run resumes from the same news id once upstream recovers
+	provider.err = nil
+	provider.fetched = nil
+	progress, err = BackfillIntoStorage(ctx, "t94", provider, testBackfillConfig, logr.Discard(), s, bs)
+	require.NoError(t, err)
+	assert.Equal(t, "9", provider.fetched[0])
+	assert.Equal(t, 1, progress.Saved)
+}
@@ -1,5 +1,11 @@
 package poller
 
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
 type ListConfig interface {
 	GetListURL() string
 	GetListCount() int
@@ -13,10 +19,12 @@ type DetailsConfig interface {
 	GetTeamId() string
 }
 
-type Config struct {
-	TeamId        string `mapstructure:"teamId"`
-	RunOnceAtBoot bool   `mapstructure:"runOnceAtBoot"`
-	List          struct {
+// FeedConfig is configuration of single polled news feed
+type FeedConfig struct {
+	// Kind chooses NewsProvider, see NewNewsProvider
+	Kind   string `mapstructure:"kind"`
+	TeamId string `mapstructure:"teamId"`
+	List   struct {
 		URL      string `mapstructure:"url"`
 		Count    int    `mapstructure:"count"`
 		Schedule string `mapstructure:"schedule"`
@@ -27,26 +35,120 @@ type Config struct {
 	} `mapstructure:"details"`
 }
 
-func (c Config) GetListURL() string {
+/*
+Config is the poller section of the config file.
+
+Feeds are polled when set, otherwise the single feed configured
+directly in the poller section is used.
+*/
+type Config struct {
+	RunOnceAtBoot bool `mapstructure:"runOnceAtBoot"`
+	FeedConfig    `mapstructure:",squash"`
+	Feeds         []FeedConfig   `mapstructure:"feeds"`
+	Backfill      BackfillConfig `mapstructure:"backfill"`
+	Retry         RetryConfig    `mapstructure:"retry"`
+}
+
+// RetryConfig is the retry section of poller config, see RetryQueue
+type RetryConfig struct {
+	// MaxAttempts is how many times details of news id are attempted before it is dead
+	MaxAttempts int `mapstructure:"maxAttempts"`
+	// InitialBackoffSeconds is wait before the second attempt, every next wait is doubled
+	InitialBackoffSeconds int `mapstructure:"initialBackoffSeconds"`
+	// MaxBackoffSeconds caps the wait, 0 means no limit
+	MaxBackoffSeconds int `mapstructure:"maxBackoffSeconds"`
+}
+
+// Validate checks that retry config values can be used
+func (c RetryConfig) Validate() error {
+	if c.MaxAttempts <= 0 {
+		return fmt.Errorf("maxAttempts has to be positive, got %v", c.MaxAttempts)
+	}
+	if c.InitialBackoffSeconds < 0 || c.MaxBackoffSeconds < 0 {
+		return errors.New("backoff seconds cannot be negative")
+	}
+	return nil
+}
+
+// BackfillConfig is the backfill section of poller config, see BackfillIntoStorage
+type BackfillConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	// RequestsPerSecond limits requests to details url of every feed
+	RequestsPerSecond float64 `mapstructure:"requestsPerSecond"`
+	// MaxMisses is how many news ids in a row can have no news before backfill stops
+	MaxMisses int `mapstructure:"maxMisses"`
+	// OldestPublished stops backfill at news published before this date formatted as 2006-01-02, empty means no limit
+	OldestPublished string `mapstructure:"oldestPublished"`
+}
+
+// Validate checks that backfill config values can be used
+func (c BackfillConfig) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	if c.RequestsPerSecond <= 0 {
+		return fmt.Errorf("requestsPerSecond has to be positive, got %v", c.RequestsPerSecond)
+	}
+	if c.MaxMisses <= 0 {
+		return fmt.Errorf("maxMisses has to be positive, got %v", c.MaxMisses)
+	}
+	if _, err := c.oldestPublished(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// oldestPublished parses OldestPublished, zero time means no limit
+func (c BackfillConfig) oldestPublished() (time.Time, error) {
+	if c.OldestPublished == "" {
+		return time.Time{}, nil
+	}
+	oldest, err := time.Parse("2006-01-02", c.OldestPublished)
+	if err != nil {
+		return time.Time{}, errors.New("oldestPublished has to be formatted as 2006-01-02")
+	}
+	return oldest, nil
+}
+
+// GetFeeds returns all feeds that should be polled, teamId has to be unique for each of them
+func (c Config) GetFeeds() ([]FeedConfig, error) {
+	feeds := c.Feeds
+	if len(feeds) == 0 {
+		feeds = []FeedConfig{c.FeedConfig}
+	}
+	teamIds := make(map[string]struct{}, len(feeds))
+	for _, feed := range feeds {
+		if feed.TeamId == "" {
+			return nil, fmt.Errorf("feed with list url %q has no teamId", feed.List.URL)
+		}
+		if _, found := teamIds[feed.TeamId]; found {
+			return nil, fmt.Errorf("teamId %q is used by more than one feed", feed.TeamId)
+		}
+		teamIds[feed.TeamId] = struct{}{}
+	}
+	return feeds, nil
+}
+
+func (c FeedConfig) GetListURL() string {
 	return c.List.URL
 }
 
-func (c Config) GetListCount() int {
+func (c FeedConfig) GetListCount() int {
 	return c.List.Count
 }
 
-func (c Config) GetListSchedule() string {
+func (c FeedConfig) GetListSchedule() string {
 	return c.List.Schedule
 }
 
-func (c Config) GetDetailsURL() string {
+func (c FeedConfig) GetDetailsURL() string {
 	return c.Details.URL
 }
 
-func (c Config) GetDetailsSchedule() string {
+func (c FeedConfig) GetDetailsSchedule() string {
 	return c.Details.Schedule
 }
 
-func (c Config) GetTeamId() string {
+func (c FeedConfig) GetTeamId() string {
 	return c.TeamId
 }
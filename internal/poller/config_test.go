@@ -0,0 +1,69 @@
+package poller
+
+import (
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func readConfig(t *testing.T, yaml string) Config {
+	v := viper.New()
+	v.SetConfigType("yaml")
+	assert.NoError(t, v.ReadConfig(strings.NewReader(yaml)))
+	var config Config
+	assert.NoError(t, v.Unmarshal(&config))
+	return config
+}
+
+func TestGetFeedsSingle(t *testing.T) {
+	config := readConfig(t, `
+teamId: t94
+list:
+  url: "http://list"
+  count: 100
+`)
+	feeds, err := config.GetFeeds()
+	assert.NoError(t, err)
+	assert.Len(t, feeds, 1)
+	assert.Equal(t, "t94", feeds[0].GetTeamId())
+	assert.Equal(t, "http://list", feeds[0].GetListURL())
+	assert.Equal(t, 100, feeds[0].GetListCount())
+}
+
+func TestGetFeedsMany(t *testing.T) {
+	config := readConfig(t, `
+teamId: ignored
+feeds:
+  - teamId: t94
+    list:
+      url: "http://hull/list"
+  - teamId: t8
+    kind: incrowd
+    list:
+      url: "http://chelsea/list"
+`)
+	feeds, err := config.GetFeeds()
+	assert.NoError(t, err)
+	assert.Len(t, feeds, 2)
+	assert.Equal(t, "t94", feeds[0].GetTeamId())
+	assert.Equal(t, "http://chelsea/list", feeds[1].GetListURL())
+
+	config.Feeds[1].TeamId = "t94"
+	_, err = config.GetFeeds()
+	assert.Error(t, err)
+}
+
+func TestBackfillConfigValidate(t *testing.T) {
+	assert.NoError(t, BackfillConfig{}.Validate())
+	assert.NoError(t, BackfillConfig{Enabled: true, RequestsPerSecond: 0.5, MaxMisses: 10, OldestPublished: "2020-01-31"}.Validate())
+	assert.Error(t, BackfillConfig{Enabled: true, MaxMisses: 10}.Validate())
+	assert.Error(t, BackfillConfig{Enabled: true, RequestsPerSecond: 1}.Validate())
+	assert.Error(t, BackfillConfig{Enabled: true, RequestsPerSecond: 1, MaxMisses: 10, OldestPublished: "31.01.2020"}.Validate())
+}
+
+func TestRetryConfigValidate(t *testing.T) {
+	assert.NoError(t, RetryConfig{MaxAttempts: 5, InitialBackoffSeconds: 60}.Validate())
+	assert.Error(t, RetryConfig{}.Validate())
+	assert.Error(t, RetryConfig{MaxAttempts: 5, InitialBackoffSeconds: -1}.Validate())
+}
@@ -0,0 +1,83 @@
+package poller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/adamdyszy/sportsnews/types"
+	"github.com/go-logr/logr"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// InCrowdConfig is configuration needed by InCrowd XML provider
+type InCrowdConfig interface {
+	ListConfig
+	DetailsConfig
+}
+
+/*
+InCrowdProvider is NewsProvider for InCrowd XML API used by club websites.
+
+List is got from NewsList XML and details from NewsDetailed XML.
+*/
+type InCrowdProvider struct {
+	config InCrowdConfig
+	client *http.Client
+	logger logr.Logger
+}
+
+// NewInCrowdProvider creates InCrowdProvider, logger is used for news that cannot be turned into articles
+func NewInCrowdProvider(config InCrowdConfig, client *http.Client, logger logr.Logger) *InCrowdProvider {
+	return &InCrowdProvider{
+		config: config,
+		client: client,
+		logger: logger.WithValues("provider", ProviderKindInCrowd),
+	}
+}
+
+func (p *InCrowdProvider) ListLatest(ctx context.Context) ([]types.Article, error) {
+	var news NewsList
+	query := url.Values{"Count": {strconv.Itoa(p.config.GetListCount())}}
+	err := getXML(ctx, p.client, p.config.GetListURL(), query, &news, p.logger)
+	if err != nil {
+		return nil, err
+	}
+	articles := make([]types.Article, 0, len(news.NewsletterNewsItems.NewsletterNewsItem))
+	var unparsed []string
+	for _, v := range news.NewsletterNewsItems.NewsletterNewsItem {
+		article, err := GetArticleFromNewsElement(v, p.config.GetTeamId(), false, p.logger)
+		if err != nil {
+			p.logger.Error(err, fmt.Sprintf("Could not parse article from news %v", v))
+			unparsed = append(unparsed, v.NewsArticleID)
+			continue
+		}
+		articles = append(articles, article)
+	}
+	if len(unparsed) > 0 {
+		return articles, fmt.Errorf("%w with newsIds: %v", ListIncomplete, unparsed)
+	}
+	return articles, nil
+}
+
+// FetchDetails returns NewsNotFound when news id is answered with 404 or with empty article
+func (p *InCrowdProvider) FetchDetails(ctx context.Context, newsId string) (types.Article, error) {
+	var news NewsDetailed
+	err := getXML(ctx, p.client, p.config.GetDetailsURL(), url.Values{"id": {newsId}}, &news, p.logger)
+	var statusErr StatusError
+	if errors.As(err, &statusErr) && statusErr.Code == http.StatusNotFound {
+		return types.Article{}, fmt.Errorf("%w with id %v: %v", NewsNotFound, newsId, err)
+	}
+	if err != nil {
+		return types.Article{}, err
+	}
+	if news.NewsArticle.NewsArticleID == "" {
+		return types.Article{}, fmt.Errorf("%w with id %v", NewsNotFound, newsId)
+	}
+	article, err := GetArticleFromNewsElement(news.NewsArticle, p.config.GetTeamId(), true, p.logger)
+	if err != nil {
+		return types.Article{}, fmt.Errorf("could not parse article from news %v: %w", news.NewsArticle, err)
+	}
+	return article, nil
+}
@@ -0,0 +1,60 @@
+package poller
+
+import (
+	"context"
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func newExamplesServer() *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/list", func(w http.ResponseWriter, r *http.Request) {
+		http.ServeFile(w, r, "../../examples/hullcityList.xml")
+	})
+	mux.HandleFunc("/details", func(w http.ResponseWriter, r *http.Request) {
+		http.ServeFile(w, r, "../../examples/hullcityDetailed.xml")
+	})
+	mux.HandleFunc("/failing", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "upstream is down", http.StatusServiceUnavailable)
+	})
+	return httptest.NewServer(mux)
+}
+
+func TestInCrowdProvider(t *testing.T) {
+	server := newExamplesServer()
+	defer server.Close()
+	var config Config
+	config.TeamId = "t1"
+	config.List.URL = server.URL + "/list"
+	config.List.Count = 10
+	config.Details.URL = server.URL + "/details"
+	p := NewInCrowdProvider(config, server.Client(), logr.Discard())
+
+	articles, err := p.ListLatest(context.Background())
+	assert.NoError(t, err)
+	assert.NotEmpty(t, articles)
+	for _, a := range articles {
+		assert.Equal(t, "t1", a.TeamId)
+		assert.False(t, a.HasDetails)
+		assert.NotEmpty(t, a.Id)
+	}
+
+	article, err := p.FetchDetails(context.Background(), articles[0].NewsId)
+	assert.NoError(t, err)
+	assert.True(t, article.HasDetails)
+	assert.NotEmpty(t, article.Content)
+
+	config.Details.URL = server.URL + "/missing"
+	p = NewInCrowdProvider(config, server.Client(), logr.Discard())
+	_, err = p.FetchDetails(context.Background(), articles[0].NewsId)
+	assert.ErrorIs(t, err, NewsNotFound)
+
+	config.Details.URL = server.URL + "/failing"
+	p = NewInCrowdProvider(config, server.Client(), logr.Discard())
+	_, err = p.FetchDetails(context.Background(), articles[0].NewsId)
+	assert.ErrorAs(t, err, &StatusError{})
+	assert.NotErrorIs(t, err, NewsNotFound)
+}
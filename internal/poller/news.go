@@ -3,6 +3,7 @@ package poller
 import (
 	"encoding/xml"
 	"github.com/adamdyszy/sportsnews/types"
+	"github.com/go-logr/logr"
 	"strings"
 	"time"
 )
@@ -75,13 +76,26 @@ const NewsPublishedDateLayout = "2006-01-02 15:04:05"
 
 /*
 GetArticleFromNewsElement creates Article
-from NewsElement taken as a value so that it can create pointers to its fields
+from NewsElement taken as a value so that it can create pointers to its fields.
+
+News that is not published is retracted.
+Unparseable last update date is logged and published date is used instead, so the news is not lost.
 */
-func GetArticleFromNewsElement(n NewsElement, teamId string, hasDetails bool) (types.Article, error) {
+func GetArticleFromNewsElement(n NewsElement, teamId string, hasDetails bool, logger logr.Logger) (types.Article, error) {
 	publishedDate, err := time.Parse(NewsPublishedDateLayout, n.PublishDate)
 	if err != nil {
 		return types.Article{}, err
 	}
+	updatedDate := publishedDate
+	if n.LastUpdateDate != "" {
+		lastUpdate, err := time.Parse(NewsPublishedDateLayout, n.LastUpdateDate)
+		if err != nil {
+			logger.Info("News has invalid last update date, using published date instead.",
+				"newsId", n.NewsArticleID, "lastUpdateDate", n.LastUpdateDate, "reason", err.Error())
+		} else {
+			updatedDate = lastUpdate
+		}
+	}
 	article := types.Article{
 		ArticleKey: types.ArticleKey{
 			Published: publishedDate,
@@ -98,6 +112,11 @@ func GetArticleFromNewsElement(n NewsElement, teamId string, hasDetails bool) (t
 		URL:         n.ArticleURL,
 		VideoURL:    n.VideoURL,
 		HasDetails:  hasDetails,
+		UpdatedAt:   updatedDate,
+	}
+	if !strings.EqualFold(strings.TrimSpace(n.IsPublished), "True") {
+		// news was withdrawn, its last update is when it happened
+		article.RetractedAt = &updatedDate
 	}
 	err = article.SetGeneratedId()
 	if err != nil {
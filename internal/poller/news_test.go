@@ -1,6 +1,7 @@
 package poller
 
 import (
+	"github.com/go-logr/logr"
 	"github.com/stretchr/testify/assert"
 	"testing"
 	"time"
@@ -10,7 +11,7 @@ func TestPublishedDate(t *testing.T) {
 	n := NewsElement{
 		PublishDate: "2023-02-17 14:20:33",
 	}
-	a, err := GetArticleFromNewsElement(n, "t94", false)
+	a, err := GetArticleFromNewsElement(n, "t94", false, logr.Discard())
 	if err != nil {
 		assert.Fail(t, err.Error())
 	}
@@ -24,7 +25,7 @@ func TestPublishedDate(t *testing.T) {
 	n = NewsElement{
 		PublishDate: "2024-11-22 19:44:51",
 	}
-	a, err = GetArticleFromNewsElement(n, "t94", false)
+	a, err = GetArticleFromNewsElement(n, "t94", false, logr.Discard())
 	if err != nil {
 		assert.Fail(t, err.Error())
 	}
@@ -42,7 +43,7 @@ func TestTaxonomies(t *testing.T) {
 		PublishDate: "2023-02-17 14:20:33",
 		Taxonomies:  "Club News",
 	}
-	a, err := GetArticleFromNewsElement(n, "t94", false)
+	a, err := GetArticleFromNewsElement(n, "t94", false, logr.Discard())
 	if err != nil {
 		assert.Fail(t, err.Error())
 	}
@@ -52,9 +53,49 @@ func TestTaxonomies(t *testing.T) {
 		PublishDate: "2023-02-17 14:20:33",
 		Taxonomies:  "Club News,Something",
 	}
-	a, err = GetArticleFromNewsElement(n, "t94", false)
+	a, err = GetArticleFromNewsElement(n, "t94", false, logr.Discard())
 	if err != nil {
 		assert.Fail(t, err.Error())
 	}
 	assert.Equal(t, []string{"Club News", "Something"}, a.Type)
 }
+
+func TestUpdatedDate(t *testing.T) {
+	n := NewsElement{
+		PublishDate: "2023-03-04 18:58:00",
+	}
+	a, err := GetArticleFromNewsElement(n, "t94", false, logr.Discard())
+	assert.NoError(t, err)
+	assert.Equal(t, a.Published, a.UpdatedAt, "never edited news is updated when published")
+
+	n.LastUpdateDate = "2023-03-05 02:00:11"
+	a, err = GetArticleFromNewsElement(n, "t94", false, logr.Discard())
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2023, 3, 5, 2, 0, 11, 0, time.UTC), a.UpdatedAt)
+
+	// invalid last update does not lose the news
+	n.LastUpdateDate = "yesterday"
+	a, err = GetArticleFromNewsElement(n, "t94", false, logr.Discard())
+	assert.NoError(t, err)
+	assert.Equal(t, a.Published, a.UpdatedAt)
+}
+
+func TestIsPublished(t *testing.T) {
+	n := NewsElement{
+		PublishDate:    "2023-03-04 18:58:00",
+		LastUpdateDate: "2023-03-05 02:00:11",
+		IsPublished:    "True",
+	}
+	a, err := GetArticleFromNewsElement(n, "t94", false, logr.Discard())
+	assert.NoError(t, err)
+	assert.Nil(t, a.RetractedAt)
+
+	for _, isPublished := range []string{"False", ""} {
+		n.IsPublished = isPublished
+		a, err = GetArticleFromNewsElement(n, "t94", false, logr.Discard())
+		assert.NoError(t, err)
+		if assert.NotNil(t, a.RetractedAt, isPublished) {
+			assert.Equal(t, a.UpdatedAt, *a.RetractedAt)
+		}
+	}
+}
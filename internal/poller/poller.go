@@ -2,191 +2,760 @@ package poller
 
 import (
 	"context"
-	"encoding/xml"
 	"errors"
 	"fmt"
+	"github.com/adamdyszy/sportsnews/internal/events"
+	"github.com/adamdyszy/sportsnews/internal/metrics"
+	"github.com/adamdyszy/sportsnews/internal/tracing"
 	"github.com/adamdyszy/sportsnews/storage"
+	"github.com/adamdyszy/sportsnews/types"
 	"github.com/go-logr/logr"
 	"github.com/robfig/cron/v3"
 	"github.com/spf13/viper"
-	"io"
-	"net/http"
-	"strconv"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"strings"
+	"sync"
+	"time"
 )
 
+// FeedNotFound is returned when there is no polled feed for requested teamId
+var FeedNotFound = errors.New("feed not found")
+
+// Feed is polled feed with provider created from its config
+type Feed struct {
+	Config   FeedConfig
+	Provider NewsProvider
+}
+
+// Poller is handle of started poller, it allows running polls on demand
+type Poller struct {
+	feeds  []Feed
+	cron   *cron.Cron
+	s      storage.ArticleStorage
+	p      events.Publisher
+	q      *RetryQueue
+	logger logr.Logger
+	// mx guards jobs state and list windows
+	mx   sync.Mutex
+	jobs []*jobState
+	// listWindows are published dates of the oldest news in the last list of every team
+	listWindows map[string]time.Time
+	// bootJobs are jobs run at boot outside of cron
+	bootJobs sync.WaitGroup
+	// bs keeps progress of backfill, which runs in backfillJobs until stopBackfill is called
+	bs           storage.BackfillStorage
+	backfill     BackfillConfig
+	backfillJobs sync.WaitGroup
+	stopBackfill context.CancelFunc
+}
+
 /*
 StartPollerWithConfigFile starts cron jobs based on config file.
-The jobs then queries news provider for list of new news and their details
+The jobs then queries news provider for list of new news and their details.
+When backfill is enabled it is started for every feed after the jobs run at boot.
 */
 func StartPollerWithConfigFile(
 	ctx context.Context,
 	v *viper.Viper,
 	logger logr.Logger,
 	s storage.ArticleStorage,
-) error {
+	bs storage.BackfillStorage,
+	rs storage.RetryStorage,
+	p events.Publisher,
+) (*Poller, error) {
 	// Unmarshal the poller config
 	var pollerConfig Config
 	err := v.Unmarshal(&pollerConfig)
 	if err != nil {
-		return fmt.Errorf("error unmarshaling poller config: %w", err)
+		return nil, fmt.Errorf("error unmarshaling poller config: %w", err)
 	}
 	logger = logger.WithValues("workerKind", "NewsPoller")
 	logger.Info("Starting poller with this config.", "config", pollerConfig)
-	c := cron.New()
-	_, err = c.AddFunc(pollerConfig.List.Schedule, func() {
-		PollNewsListIntoStorage(ctx, pollerConfig, logger, s)
-	})
+	feeds, err := pollerConfig.GetFeeds()
 	if err != nil {
-		return fmt.Errorf("error adding PollNewsListIntoStorage to cron: %w", err)
+		return nil, fmt.Errorf("error in poller feeds config: %w", err)
 	}
-	_, err = c.AddFunc(pollerConfig.Details.Schedule, func() {
-		PollNewsDetailsIntoStorage(ctx, pollerConfig, logger, s)
-	})
+	err = pollerConfig.Backfill.Validate()
 	if err != nil {
-		return fmt.Errorf("error adding PollNewsDetailsIntoStorage to cron: %w", err)
+		return nil, fmt.Errorf("error in poller backfill config: %w", err)
+	}
+	err = pollerConfig.Retry.Validate()
+	if err != nil {
+		return nil, fmt.Errorf("error in poller retry config: %w", err)
+	}
+	pl := &Poller{
+		cron:        cron.New(),
+		s:           s,
+		p:           p,
+		q:           NewRetryQueue(pollerConfig.Retry, rs),
+		logger:      logger,
+		listWindows: make(map[string]time.Time),
+		bs:          bs,
+		backfill:    pollerConfig.Backfill,
+	}
+	for _, feed := range feeds {
+		err = pl.addFeed(ctx, feed)
+		if err != nil {
+			return nil, err
+		}
 	}
 	if pollerConfig.RunOnceAtBoot {
 		logger.Info("Running jobs for the first time.")
-		for _, e := range c.Entries() {
-			go e.Job.Run()
+		for _, e := range pl.cron.Entries() {
+			pl.bootJobs.Add(1)
+			go func(job cron.Job) {
+				defer pl.bootJobs.Done()
+				job.Run()
+			}(e.Job)
 		}
 	}
 	logger.Info("Starting the scheduler.")
-	c.Start()
+	pl.cron.Start()
+	pl.startBackfill(ctx)
+	return pl, nil
+}
+
+// startBackfill runs backfill of every feed with numeric news ids in background when it is enabled
+func (pl *Poller) startBackfill(ctx context.Context) {
+	ctx, pl.stopBackfill = context.WithCancel(ctx)
+	if !pl.backfill.Enabled {
+		return
+	}
+	for _, feed := range pl.feeds {
+		logger := pl.feedLogger(feed.Config)
+		if feed.Config.Kind != ProviderKindInCrowd && feed.Config.Kind != "" {
+			logger.Info("Backfill is not supported by provider kind, its news ids are not numeric.")
+			continue
+		}
+		pl.backfillJobs.Add(1)
+		go func(feed Feed) {
+			defer pl.backfillJobs.Done()
+			// boot jobs store the latest news, so backfill knows where to start
+			pl.bootJobs.Wait()
+			for {
+				_, err := BackfillIntoStorage(ctx, feed.Config.TeamId, feed.Provider, pl.backfill, logger, pl.s, pl.bs)
+				if err == nil {
+					return
+				}
+				// failed requests or storage calls do not end backfill, it resumes after a while
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(backfillRetryWait):
+				}
+			}
+		}(feed)
+	}
+}
+
+/*
+Stop stops scheduling of jobs and waits for running jobs to finish.
+Backfill is cancelled right away, its progress is saved so it resumes after restart.
+
+It returns error when ctx is done before the jobs finish, they are cancelled with ctx passed to StartPollerWithConfigFile,
+Stop can be called again after cancelling it to wait for them to return.
+*/
+func (pl *Poller) Stop(ctx context.Context) error {
+	pl.logger.Info("Stopping the scheduler.")
+	if pl.stopBackfill != nil {
+		pl.stopBackfill()
+	}
+	cronDone := pl.cron.Stop()
+	bootDone := make(chan struct{})
+	go func() {
+		pl.bootJobs.Wait()
+		pl.backfillJobs.Wait()
+		close(bootDone)
+	}()
+	for _, done := range []<-chan struct{}{cronDone.Done(), bootDone} {
+		select {
+		case <-done:
+		case <-ctx.Done():
+			return fmt.Errorf("running poller jobs did not finish: %w", ctx.Err())
+		}
+	}
+	pl.logger.Info("All running jobs finished.")
 	return nil
 }
 
-func PollNewsDetailsIntoStorage(ctx context.Context, config DetailsConfig, logger logr.Logger, s storage.ArticleStorage) {
-	logger = logger.WithValues("workerJob", "DetailsPolling")
-	logger = logger.WithValues("url", config.GetDetailsURL())
-	logger.Info("Getting news IDs that don't have details filled in.")
-	ids, err := s.GetNewsWithoutDetailsIDs()
+// addFeed creates provider of the feed and adds its list and details polling jobs to cron
+func (pl *Poller) addFeed(ctx context.Context, config FeedConfig) error {
+	logger := pl.feedLogger(config)
+	provider, err := NewNewsProvider(config, logger)
 	if err != nil {
-		logger.Error(err, "Could not get IDs of news that needs to get details from storage.")
-		return
+		return fmt.Errorf("error creating news provider for teamId %v: %w", config.TeamId, err)
 	}
-	if ids == nil {
-		logger.Info("There are no news IDs to get details of.")
-		return
+	provider = windowRecorder{NewsProvider: provider, record: func(oldest time.Time) {
+		pl.recordListWindow(config.TeamId, oldest)
+	}}
+	err = pl.addJob(ctx, config, types.PollerJobList, config.List.Schedule, func(ctx context.Context) (types.PollSummary, error) {
+		return PollNewsListIntoStorage(ctx, provider, logger, pl.s, pl.p)
+	})
+	if err != nil {
+		return fmt.Errorf("error adding PollNewsListIntoStorage of teamId %v to cron: %w", config.TeamId, err)
 	}
-	for _, id := range ids {
-		err := PollNewsDetailsIntoStorageOfGivenID(ctx, config, logger, s, id)
-		if err != nil {
-			logger.Error(err, fmt.Sprintf("Fail when polling details of newsId %v", id))
-			return
+	err = pl.addJob(ctx, config, types.PollerJobDetails, config.Details.Schedule, func(ctx context.Context) (types.PollSummary, error) {
+		return PollNewsDetailsIntoStorage(ctx, config.TeamId, provider, logger, pl.s, pl.p, pl.q)
+	})
+	if err != nil {
+		return fmt.Errorf("error adding PollNewsDetailsIntoStorage of teamId %v to cron: %w", config.TeamId, err)
+	}
+	pl.feeds = append(pl.feeds, Feed{Config: config, Provider: provider})
+	return nil
+}
+
+func (pl *Poller) feedLogger(config FeedConfig) logr.Logger {
+	return pl.logger.WithValues("teamId", config.TeamId, "providerKind", config.Kind)
+}
+
+// feedsOf returns feed of given team or all feeds when teamId is empty
+func (pl *Poller) feedsOf(teamId string) ([]Feed, error) {
+	if teamId == "" {
+		return pl.feeds, nil
+	}
+	for _, feed := range pl.feeds {
+		if feed.Config.TeamId == teamId {
+			return []Feed{feed}, nil
 		}
 	}
-	logger.Info("Finished polling and saving details of all newses.")
+	return nil, fmt.Errorf("%w with teamId: %v", FeedNotFound, teamId)
 }
 
-func PollNewsDetailsIntoStorageOfGivenID(ctx context.Context, config DetailsConfig, logger logr.Logger, s storage.ArticleStorage, newsId string) error {
-	logger = logger.WithValues("newsId", newsId)
-	logger.Info("Starting to poll detailed news.")
-	req, err := http.NewRequestWithContext(ctx, "GET", config.GetDetailsURL(), nil)
+// feedOf returns feed of given team, unlike feedsOf it needs teamId
+func (pl *Poller) feedOf(teamId string) (Feed, error) {
+	if teamId == "" {
+		return Feed{}, fmt.Errorf("%w, teamId is required", FeedNotFound)
+	}
+	feeds, err := pl.feedsOf(teamId)
 	if err != nil {
-		logger.Error(err, "Failed to create new GET request.")
-		return nil
+		return Feed{}, err
 	}
-	q := req.URL.Query()
-	q.Add("id", newsId)
-	req.URL.RawQuery = q.Encode()
-	resp, err := http.DefaultClient.Do(req)
+	return feeds[0], nil
+}
+
+// HasFeed tells if feed of the team is polled
+func (pl *Poller) HasFeed(teamId string) bool {
+	_, err := pl.feedsOf(teamId)
+	return err == nil
+}
+
+/*
+InListWindow tells if article is still covered by the latest news listed by its feed,
+so list polling would save it again if it was removed.
+
+Until the list of the feed is polled it is not known what it covers, so every article of the team is in it.
+*/
+func (pl *Poller) InListWindow(a types.Article) bool {
+	if !pl.HasFeed(a.TeamId) {
+		return false
+	}
+	pl.mx.Lock()
+	oldest, listed := pl.listWindows[a.TeamId]
+	pl.mx.Unlock()
+	return !listed || !a.Published.Before(oldest)
+}
+
+// recordListWindow remembers published date of the oldest news in the last list of the team
+func (pl *Poller) recordListWindow(teamId string, oldest time.Time) {
+	pl.mx.Lock()
+	defer pl.mx.Unlock()
+	pl.listWindows[teamId] = oldest
+}
+
+// windowRecorder passes published date of the oldest news of every non-empty list to record
+type windowRecorder struct {
+	NewsProvider
+	record func(oldest time.Time)
+}
+
+func (w windowRecorder) ListLatest(ctx context.Context) ([]types.Article, error) {
+	articles, err := w.NewsProvider.ListLatest(ctx)
+	if (err != nil && !errors.Is(err, ListIncomplete)) || len(articles) == 0 {
+		return articles, err
+	}
+	oldest := articles[0].Published
+	for _, a := range articles {
+		if a.Published.Before(oldest) {
+			oldest = a.Published
+		}
+	}
+	w.record(oldest)
+	return articles, err
+}
+
+// PollList polls news list of the team feed now, empty teamId polls all feeds, failing feed does not stop the others
+func (pl *Poller) PollList(ctx context.Context, teamId string) (types.PollSummary, error) {
+	return pl.pollFeeds(teamId, func(feed Feed) (types.PollSummary, error) {
+		return PollNewsListIntoStorage(ctx, feed.Provider, pl.feedLogger(feed.Config), pl.s, pl.p)
+	})
+}
+
+// PollDetails polls details of news without them for the team feed now, empty teamId polls all feeds, failing feed does not stop the others
+func (pl *Poller) PollDetails(ctx context.Context, teamId string) (types.PollSummary, error) {
+	return pl.pollFeeds(teamId, func(feed Feed) (types.PollSummary, error) {
+		return PollNewsDetailsIntoStorage(ctx, feed.Config.TeamId, feed.Provider, pl.feedLogger(feed.Config), pl.s, pl.p, pl.q)
+	})
+}
+
+// pollFeeds runs poll of every feed of the team and returns their summaries added together and their errors joined
+func (pl *Poller) pollFeeds(teamId string, poll func(feed Feed) (types.PollSummary, error)) (types.PollSummary, error) {
+	feeds, err := pl.feedsOf(teamId)
 	if err != nil {
-		logger.Error(err, "Failed to do http request.")
-		return nil
+		return types.PollSummary{}, err
 	}
-	defer func(Body io.ReadCloser) {
-		err := Body.Close()
+	var summary types.PollSummary
+	var errs []error
+	for _, feed := range feeds {
+		feedSummary, err := poll(feed)
+		summary = summary.Add(feedSummary)
 		if err != nil {
-			logger.Error(err, "Error during close of response.")
+			errs = append(errs, fmt.Errorf("feed of teamId %v: %w", feed.Config.TeamId, err))
 		}
-	}(resp.Body)
-	if resp.StatusCode != http.StatusOK {
-		logger.Error(fmt.Errorf("%v", resp.StatusCode), "Status error")
+	}
+	return summary, joinErrors(errs)
+}
+
+// joinErrors returns errors as one error wrapping the first of them, nil when there are none
+func joinErrors(errs []error) error {
+	switch len(errs) {
+	case 0:
 		return nil
+	case 1:
+		return errs[0]
 	}
-	dec := xml.NewDecoder(resp.Body)
-	dec.Strict = false
-	var news NewsDetailed
-	err = dec.Decode(&news)
+	rest := make([]string, 0, len(errs)-1)
+	for _, err := range errs[1:] {
+		rest = append(rest, err.Error())
+	}
+	return fmt.Errorf("%w; %v", errs[0], strings.Join(rest, "; "))
+}
+
+/*
+ReplayDetails polls details of news id from retry queue right away, dead news id is given all attempts again.
+News id is polled from the feed of teamId, FeedNotFound is returned when there is none or teamId is empty.
+
+When it fails again the news id waits in the queue as after its first failure.
+*/
+func (pl *Poller) ReplayDetails(ctx context.Context, teamId string, newsId string) (types.PollSummary, error) {
+	feed, err := pl.feedOf(teamId)
 	if err != nil {
-		logger.Error(err, "Could not decode response.")
-		return nil
+		return types.PollSummary{}, err
 	}
-	article, err := GetArticleFromNewsElement(news.NewsArticle, config.GetTeamId(), true)
+	logger := pl.feedLogger(feed.Config).WithValues("workerJob", "ReplayDetails")
+	err = pl.q.forget(ctx, teamId, newsId)
 	if err != nil {
-		logger.Error(err, fmt.Sprintf("Could not parse article from news %v", news.NewsArticle))
-		return nil
+		return types.PollSummary{}, err
 	}
-	err = s.Write(article)
+	summary, err := PollNewsDetailsIntoStorageOfGivenID(ctx, feed.Provider, logger, pl.s, pl.p, newsId)
 	if err != nil {
-		if errors.Is(err, storage.ArticleAlreadyExists) || errors.Is(err, storage.ArticleWriteFailed) {
-			logger.Error(err, fmt.Sprintf("Could not write article with newsId %v", article.Id))
-			return nil
+		retry, queueErr := pl.q.failed(ctx, teamId, newsId, err)
+		if queueErr != nil {
+			return summary, queueErr
+		}
+		if retry.Dead {
+			return summary, fmt.Errorf("news id is dead again: %w", err)
 		}
-		return err
+		return summary, fmt.Errorf("news id will be retried at %v: %w", retry.NextAttemptAt.Format(time.RFC3339), err)
 	}
-	logger.Info("Saved article from detailed news.", "articleID", article.Id, "newsId", newsId)
-	return nil
+	return summary, nil
 }
 
-func PollNewsListIntoStorage(ctx context.Context, config ListConfig, logger logr.Logger, s storage.ArticleStorage) {
-	logger = logger.WithValues("workerJob", "ListPolling")
-	logger = logger.WithValues("pollerNewsListCount", config.GetListCount())
-	logger = logger.WithValues("url", config.GetListURL())
-	logger.Info("Starting to poll news.")
-	req, err := http.NewRequestWithContext(ctx, "GET", config.GetListURL(), nil)
+/*
+RefreshArticle fetches details of stored article again and replaces it, keeping stored one as revision.
+
+Unlike details polling it also replaces article that already has details.
+*/
+func (pl *Poller) RefreshArticle(ctx context.Context, id types.ArticleId) (types.PollSummary, error) {
+	stored, err := pl.s.Get(ctx, id)
 	if err != nil {
-		logger.Error(err, "Failed to create new GET request.")
-		return
+		return types.PollSummary{}, err
 	}
-	q := req.URL.Query()
-	q.Add("Count", strconv.Itoa(config.GetListCount()))
-	req.URL.RawQuery = q.Encode()
-	resp, err := http.DefaultClient.Do(req)
+	feed, err := pl.feedOf(stored.TeamId)
 	if err != nil {
-		logger.Error(err, "Failed to do http request.")
-		return
+		return types.PollSummary{}, err
+	}
+	logger := pl.feedLogger(feed.Config).WithValues("workerJob", "RefreshArticle", "articleID", id)
+	article, err := feed.Provider.FetchDetails(ctx, stored.NewsId)
+	if err != nil {
+		logger.Error(err, "Could not fetch detailed news.")
+		return types.PollSummary{Failed: 1}, fmt.Errorf("could not fetch details of news with newsId %v: %w", stored.NewsId, err)
+	}
+	if article.Id != stored.Id {
+		// published date of news changed upstream, so it would get different id
+		article.Id = stored.Id
+	}
+	if article.RetractedAt != nil {
+		return retractArticle(ctx, pl.s, pl.p, logger, article, *article.RetractedAt), nil
+	}
+	err = pl.s.Replace(ctx, article)
+	if err != nil {
+		return types.PollSummary{Failed: 1}, err
+	}
+	logger.Info("Refreshed article.")
+	metrics.PollerArticles.WithLabelValues(article.TeamId, metrics.ResultUpgraded).Inc()
+	pl.p.Publish(events.ArticleUpgraded, article)
+	return types.PollSummary{Saved: 1}, nil
+}
+
+/*
+PollNewsDetailsIntoStorage gets details of all news of given team that are stored without them.
+
+News id that fails is put into retry queue q and polling goes on with the next one,
+news ids waiting in q are skipped until their next attempt is due and dead ones are not polled at all.
+Error is returned only when polling cannot go on, failed news ids are counted in summary.
+*/
+func PollNewsDetailsIntoStorage(ctx context.Context, teamId string, provider NewsProvider, logger logr.Logger, s storage.ArticleStorage, p events.Publisher, q *RetryQueue) (types.PollSummary, error) {
+	logger = logger.WithValues("workerJob", "DetailsPolling")
+	logger.Info("Getting news IDs that don't have details filled in.")
+	var summary types.PollSummary
+	ids, err := s.GetNewsWithoutDetailsIDs(ctx, teamId)
+	if err != nil {
+		logger.Error(err, "Could not get IDs of news that needs to get details from storage.")
+		return summary, err
+	}
+	retries, err := q.pending(ctx, teamId)
+	if err != nil {
+		logger.Error(err, "Could not get news IDs waiting for retry.")
+		return summary, err
 	}
-	defer func(Body io.ReadCloser) {
-		err := Body.Close()
+	if len(ids) == 0 && len(retries) == 0 {
+		logger.Info("There are no news IDs to get details of.")
+		return summary, nil
+	}
+	waiting, dead := 0, 0
+	for _, id := range ids {
+		if ctx.Err() != nil {
+			logger.Info("Stopping details polling, it was cancelled.", "summary", summary)
+			return summary, ctx.Err()
+		}
+		retry, retried := retries[id]
+		delete(retries, id)
+		if retried && !q.due(retry) {
+			if retry.Dead {
+				dead++
+			} else {
+				waiting++
+			}
+			continue
+		}
+		idSummary, err := PollNewsDetailsIntoStorageOfGivenID(ctx, provider, logger, s, p, id)
+		summary = summary.Add(idSummary)
 		if err != nil {
-			logger.Error(err, "Error during close of response.")
+			if ctx.Err() != nil {
+				return summary, ctx.Err()
+			}
+			retry, err = q.failed(ctx, teamId, id, err)
+			if err != nil {
+				logger.Error(err, fmt.Sprintf("Could not put newsId %v into retry queue", id))
+				return summary, err
+			}
+			logger.Info("News ID will be retried.", "newsId", id, "attempts", retry.Attempts, "nextAttemptAt", retry.NextAttemptAt, "dead", retry.Dead)
+			continue
+		}
+		if retried {
+			err = q.forget(ctx, teamId, id)
+			if err != nil {
+				logger.Error(err, fmt.Sprintf("Could not remove newsId %v from retry queue", id))
+				return summary, err
+			}
+		}
+	}
+	// news ids left in retries have details already, or their articles were removed, so there is nothing to retry
+	for id := range retries {
+		err = q.forget(ctx, teamId, id)
+		if err != nil {
+			logger.Error(err, fmt.Sprintf("Could not remove newsId %v from retry queue", id))
+			return summary, err
 		}
-	}(resp.Body)
-	if resp.StatusCode != http.StatusOK {
-		logger.Error(fmt.Errorf("%v", resp.StatusCode), "Status error")
-		return
 	}
-	dec := xml.NewDecoder(resp.Body)
-	dec.Strict = false
-	var news NewsList
-	err = dec.Decode(&news)
+	logger.Info("Finished polling and saving details of all newses.", "summary", summary, "waitingForRetry", waiting, "dead", dead)
+	return summary, nil
+}
+
+/*
+PollNewsDetailsIntoStorageOfGivenID gets details of single news and saves them.
+Saved article is published as events.ArticleUpgraded.
+
+Error is returned when details could not be fetched or saved, so the news id should be polled again.
+*/
+func PollNewsDetailsIntoStorageOfGivenID(ctx context.Context, provider NewsProvider, logger logr.Logger, s storage.ArticleStorage, p events.Publisher, newsId string) (types.PollSummary, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "poller.newsDetails", trace.WithAttributes(attribute.String("news.id", newsId)))
+	defer span.End()
+	logger = logger.WithValues("newsId", newsId)
+	logger.Info("Starting to poll detailed news.")
+	article, err := provider.FetchDetails(ctx, newsId)
 	if err != nil {
-		logger.Error(err, "Could not decode response.")
-		return
+		span.RecordError(err)
+		logger.Error(err, "Could not fetch detailed news.")
+		return types.PollSummary{Failed: 1}, fmt.Errorf("could not fetch detailed news: %w", err)
 	}
-	logger.Info("Polled news.", "newsAmount", len(news.NewsletterNewsItems.NewsletterNewsItem))
-	for _, v := range news.NewsletterNewsItems.NewsletterNewsItem {
-		article, err := GetArticleFromNewsElement(v, "t94", false)
-		if err != nil {
-			logger.Error(err, fmt.Sprintf("Could not parse article from news %v", v))
+	if article.RetractedAt != nil {
+		summary := retractArticle(ctx, s, p, logger, article, *article.RetractedAt)
+		if summary.Failed > 0 {
+			return summary, errors.New("could not retract article")
+		}
+		return summary, nil
+	}
+	err = s.Write(ctx, article)
+	if err != nil {
+		if errors.Is(err, storage.ArticleAlreadyExists) {
+			logger.Error(err, fmt.Sprintf("Could not write article with newsId %v", article.Id))
+			metrics.PollerArticles.WithLabelValues(article.TeamId, metrics.ResultSkipped).Inc()
+			return types.PollSummary{Skipped: 1}, nil
+		}
+		span.RecordError(err)
+		metrics.PollerArticles.WithLabelValues(article.TeamId, metrics.ResultFailed).Inc()
+		logger.Error(err, fmt.Sprintf("Could not write article with newsId %v", article.Id))
+		return types.PollSummary{Failed: 1}, err
+	}
+	logger.Info("Saved article from detailed news.", "articleID", article.Id, "newsId", newsId)
+	metrics.PollerArticles.WithLabelValues(article.TeamId, metrics.ResultUpgraded).Inc()
+	p.Publish(events.ArticleUpgraded, article)
+	return types.PollSummary{Saved: 1}, nil
+}
+
+/*
+PollNewsListIntoStorage gets newest news and saves the ones that are not yet stored.
+Every saved article is published as events.ArticleCreated.
+
+Stored articles that were edited upstream since they were saved are replaced
+and published as events.ArticleUpgraded, see replaceEdited. Stored articles that are listed as not published,
+or that vanished from the list, are retracted. When some listed news could not be parsed
+the rest is saved, but nothing is treated as vanished, since unparsed news would look so.
+*/
+func PollNewsListIntoStorage(ctx context.Context, provider NewsProvider, logger logr.Logger, s storage.ArticleStorage, p events.Publisher) (types.PollSummary, error) {
+	logger = logger.WithValues("workerJob", "ListPolling")
+	logger.Info("Starting to poll news.")
+	var summary types.PollSummary
+	articles, err := provider.ListLatest(ctx)
+	incomplete := errors.Is(err, ListIncomplete)
+	if err != nil && !incomplete {
+		logger.Error(err, "Could not list latest news.")
+		return summary, err
+	}
+	logger.Info("Polled news.", "newsAmount", len(articles), "incomplete", incomplete)
+	stored, err := storedArticles(ctx, s, articles)
+	if err != nil {
+		logger.Error(err, "Could not get stored articles of listed news.")
+		return summary, err
+	}
+	for _, article := range articles {
+		if ctx.Err() != nil {
+			logger.Info("Stopping list polling, it was cancelled.", "summary", summary)
+			return summary, ctx.Err()
+		}
+		if article.RetractedAt != nil {
+			summary = summary.Add(retractArticle(ctx, s, p, logger, article, *article.RetractedAt))
 			continue
 		}
-		err = s.Write(article)
+		err = s.Write(ctx, article)
 		if err != nil {
 			if errors.Is(err, storage.ArticleAlreadyExists) {
+				var replaced bool
+				article, replaced, err = replaceEdited(ctx, provider, s, stored[article.Id], article)
+				if err != nil {
+					summary.Failed++
+					metrics.PollerArticles.WithLabelValues(article.TeamId, metrics.ResultFailed).Inc()
+					logger.Error(err, fmt.Sprintf("Could not replace edited article with id %v", article.Id))
+					continue
+				}
+				if replaced {
+					summary.Saved++
+					metrics.PollerArticles.WithLabelValues(article.TeamId, metrics.ResultUpdated).Inc()
+					logger.Info("Replaced article edited upstream.",
+						"articleID", article.Id, "newsId", article.NewsId, "updatedAt", article.UpdatedAt)
+					p.Publish(events.ArticleUpgraded, article)
+					continue
+				}
+				summary.Skipped++
+				metrics.PollerArticles.WithLabelValues(article.TeamId, metrics.ResultSkipped).Inc()
 				continue
 			}
+			summary.Failed++
+			metrics.PollerArticles.WithLabelValues(article.TeamId, metrics.ResultFailed).Inc()
 			if errors.Is(err, storage.ArticleWriteFailed) {
 				logger.Error(err, fmt.Sprintf("Could not write article with id %v", article.Id))
 				continue
 			}
-			logger.Error(err, fmt.Sprintf("Fail when processing article %v", v))
-			return
+			logger.Error(err, fmt.Sprintf("Fail when processing article %v", article))
+			return summary, err
 		} else {
-			logger.Info("Saved article from listed news.", "articleID", article.Id, "newsId", v.NewsArticleID)
+			summary.Saved++
+			metrics.PollerArticles.WithLabelValues(article.TeamId, metrics.ResultCreated).Inc()
+			logger.Info("Saved article from listed news.", "articleID", article.Id, "newsId", article.NewsId)
+			p.Publish(events.ArticleCreated, article)
 		}
 	}
-	logger.Info("Finished polling and saving news.")
+	if incomplete {
+		logger.Info("Not looking for vanished articles, some listed news could not be parsed.", "summary", summary)
+		return summary, nil
+	}
+	vanishedSummary, err := retractVanished(ctx, s, p, logger, articles)
+	summary = summary.Add(vanishedSummary)
+	if err != nil {
+		logger.Error(err, "Could not look for articles that vanished from the list.")
+		return summary, err
+	}
+	logger.Info("Finished polling and saving news.", "summary", summary)
+	return summary, nil
+}
+
+// storedArticles returns stored articles with ids of listed ones, so they are checked for edits with single query
+func storedArticles(ctx context.Context, s storage.ArticleStorage, articles []types.Article) (map[types.ArticleId]types.Article, error) {
+	stored := make(map[types.ArticleId]types.Article, len(articles))
+	if len(articles) == 0 {
+		return stored, nil
+	}
+	ids := make([]types.ArticleId, 0, len(articles))
+	for _, a := range articles {
+		ids = append(ids, a.Id)
+	}
+	q := storage.PageQuery{Limit: len(ids), Filter: storage.ArticleFilter{Ids: ids}}
+	for {
+		page, err := s.ListPage(ctx, q)
+		if err != nil {
+			return nil, err
+		}
+		for _, a := range page.Articles {
+			stored[a.Id] = a
+		}
+		if !page.HasMore {
+			return stored, nil
+		}
+		q.Cursor = page.NextCursor
+	}
+}
+
+/*
+replaceEdited replaces stored article with listed one when it has newer UpdatedAt, stored one is kept as revision.
+It returns saved article, which is listed one or the one with its fresh details.
+
+Listed article usually has no details. When stored article has them, fresh details are fetched right away
+and the article is replaced with them, so its content is never lost. When they cannot be fetched,
+or they do not have the edit yet, stored article is kept as it is and the next list poll tries again.
+Stored article without details is replaced with listed one, which is queued for details polling.
+
+Article stored before UpdatedAt was kept has zero UpdatedAt, it is not known whether it was edited,
+so only UpdatedAt of listed one is set to it and later edits are compared with that.
+Zero stored article means it was not stored when the list poll began, it is left to the next poll.
+*/
+func replaceEdited(
+	ctx context.Context,
+	provider NewsProvider,
+	s storage.ArticleStorage,
+	stored types.Article,
+	article types.Article,
+) (types.Article, bool, error) {
+	if stored.Id == "" {
+		return article, false, nil
+	}
+	// tombstone is replaced also when the same version is published again
+	republished := stored.RetractedAt != nil && article.RetractedAt == nil
+	if stored.UpdatedAt.IsZero() && !republished {
+		err := s.SetUpdatedAt(ctx, stored.Id, article.UpdatedAt)
+		if err != nil && !errors.Is(err, storage.ArticleNotFound) {
+			return article, false, err
+		}
+		return article, false, nil
+	}
+	if !article.UpdatedAt.After(stored.UpdatedAt) && !republished {
+		return article, false, nil
+	}
+	if stored.HasDetails && !article.HasDetails {
+		detailed, err := provider.FetchDetails(ctx, article.NewsId)
+		if err != nil {
+			return article, false, fmt.Errorf("could not fetch details of edited news with newsId %v: %w", article.NewsId, err)
+		}
+		if detailed.UpdatedAt.Before(article.UpdatedAt) {
+			// details do not have the edit yet, next list poll tries again
+			return article, false, nil
+		}
+		// published date of news could change in details, it keeps id of the listed one
+		detailed.Id = article.Id
+		article = detailed
+	}
+	err := s.Replace(ctx, article)
+	if errors.Is(err, storage.ArticleNotFound) {
+		// deleted in the meantime, next poll saves it again
+		return article, false, nil
+	}
+	if err != nil {
+		return article, false, err
+	}
+	return article, true, nil
+}
+
+/*
+retractArticle saves tombstone of stored article withdrawn upstream, keeping the article as revision.
+Tombstone is published as events.ArticleRetracted.
+*/
+func retractArticle(ctx context.Context, s storage.ArticleStorage, p events.Publisher, logger logr.Logger, article types.Article, retractedAt time.Time) types.PollSummary {
+	logger = logger.WithValues("articleID", article.Id, "newsId", article.NewsId)
+	stored, err := s.Get(ctx, article.Id)
+	if err == nil && stored.RetractedAt == nil {
+		// tombstone keeps what was stored, since withdrawn version may not have the whole article
+		stored.RetractedAt = &retractedAt
+		err = s.Replace(ctx, stored)
+		if err == nil {
+			logger.Info("Retracted article withdrawn upstream.", "retractedAt", retractedAt)
+			metrics.PollerArticles.WithLabelValues(article.TeamId, metrics.ResultRetracted).Inc()
+			p.Publish(events.ArticleRetracted, stored)
+			return types.PollSummary{Saved: 1}
+		}
+	}
+	if err == nil || errors.Is(err, storage.ArticleNotFound) {
+		// already retracted or never stored, so there is nothing to withdraw
+		metrics.PollerArticles.WithLabelValues(article.TeamId, metrics.ResultSkipped).Inc()
+		return types.PollSummary{Skipped: 1}
+	}
+	logger.Error(err, "Could not retract article.")
+	metrics.PollerArticles.WithLabelValues(article.TeamId, metrics.ResultFailed).Inc()
+	return types.PollSummary{Failed: 1}
+}
+
+/*
+retractVanished retracts stored articles of the team that are missing from the list,
+although they were published within the time the list covers, after its oldest article.
+*/
+func retractVanished(ctx context.Context, s storage.ArticleStorage, p events.Publisher, logger logr.Logger, articles []types.Article) (types.PollSummary, error) {
+	var summary types.PollSummary
+	if len(articles) == 0 {
+		// empty list covers no time
+		return summary, nil
+	}
+	listed := make(map[types.ArticleId]bool, len(articles))
+	oldest := articles[0].Published
+	for _, a := range articles {
+		listed[a.Id] = true
+		if a.Published.Before(oldest) {
+			oldest = a.Published
+		}
+	}
+	notRetracted := false
+	q := storage.PageQuery{
+		Limit:  len(articles),
+		Filter: storage.ArticleFilter{TeamId: articles[0].TeamId, PublishedAfter: oldest, Retracted: &notRetracted},
+	}
+	var vanished []types.Article
+	for {
+		page, err := s.ListPage(ctx, q)
+		if err != nil {
+			return summary, err
+		}
+		for _, a := range page.Articles {
+			if !listed[a.Id] {
+				vanished = append(vanished, a)
+			}
+		}
+		if !page.HasMore {
+			break
+		}
+		q.Cursor = page.NextCursor
+	}
+	retractedAt := time.Now().UTC()
+	for _, a := range vanished {
+		summary = summary.Add(retractArticle(ctx, s, p, logger.WithValues("reason", "vanished"), a, retractedAt))
+	}
+	return summary, nil
 }
@@ -0,0 +1,295 @@
+package poller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/adamdyszy/sportsnews/internal/events"
+	"github.com/adamdyszy/sportsnews/internal/storage/memory"
+	"github.com/adamdyszy/sportsnews/storage"
+	"github.com/adamdyszy/sportsnews/storage/storagetest"
+	"github.com/adamdyszy/sportsnews/types"
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// fakeProvider lists and details articles it was given, details are found by news id
+type fakeProvider struct {
+	list    []types.Article
+	details map[string]types.Article
+	// fetched are news ids in order their details were fetched
+	fetched []string
+	// err fails every fetch of details when set
+	err error
+	// listErr fails every list when set
+	listErr error
+}
+
+func (f *fakeProvider) ListLatest(_ context.Context) ([]types.Article, error) {
+	if f.listErr != nil {
+		return nil, f.listErr
+	}
+	return f.list, nil
+}
+
+func (f *fakeProvider) FetchDetails(_ context.Context, newsId string) (types.Article, error) {
+	f.fetched = append(f.fetched, newsId)
+	if f.err != nil {
+		return types.Article{}, f.err
+	}
+	article, found := f.details[newsId]
+	if !found {
+		return types.Article{}, fmt.Errorf("%w with id %v", NewsNotFound, newsId)
+	}
+	return article, nil
+}
+
+func TestPollEditedArticle(t *testing.T) {
+	ctx := context.Background()
+	s := memory.NewMemStorage()
+	b := events.NewBroker(10)
+	listed := storagetest.NewArticle(t, "t94", 1, false)
+	detailed := storagetest.NewArticle(t, "t94", 1, true)
+	provider := &fakeProvider{list: []types.Article{listed}, details: map[string]types.Article{listed.NewsId: detailed}}
+
+	summary, err := PollNewsListIntoStorage(ctx, provider, logr.Discard(), s, b)
+	require.NoError(t, err)
+	assert.Equal(t, types.PollSummary{Saved: 1}, summary)
+	summary, err = PollNewsDetailsIntoStorage(ctx, "t94", provider, logr.Discard(), s, b, newTestRetryQueue())
+	require.NoError(t, err)
+	assert.Equal(t, types.PollSummary{Saved: 1}, summary)
+	require.NoError(t, s.SetPinned(ctx, listed.Id, true))
+
+	// not edited article is skipped
+	summary, err = PollNewsListIntoStorage(ctx, provider, logr.Discard(), s, b)
+	require.NoError(t, err)
+	assert.Equal(t, types.PollSummary{Skipped: 1}, summary)
+
+	// edited article keeps stored content while its details do not have the edit or cannot be fetched
+	editedAt := listed.Published.Add(time.Hour)
+	provider.list[0].UpdatedAt = editedAt
+	provider.list[0].Title = "Corrected title"
+	summary, err = PollNewsListIntoStorage(ctx, provider, logr.Discard(), s, b)
+	require.NoError(t, err)
+	assert.Equal(t, types.PollSummary{Skipped: 1}, summary)
+	provider.err = errors.New("unavailable")
+	summary, err = PollNewsListIntoStorage(ctx, provider, logr.Discard(), s, b)
+	require.NoError(t, err)
+	assert.Equal(t, types.PollSummary{Failed: 1}, summary)
+	provider.err = nil
+	got, err := s.Get(ctx, listed.Id)
+	require.NoError(t, err)
+	assert.Equal(t, detailed.Content, got.Content)
+	assert.True(t, got.HasDetails)
+
+	// edited article is replaced once its details have the edit
+	corrected := detailed
+	corrected.Title = "Corrected title"
+	corrected.Content = "Corrected content"
+	corrected.UpdatedAt = editedAt
+	provider.details[listed.NewsId] = corrected
+	_, ch, unsubscribe := b.Subscribe(0)
+	defer unsubscribe()
+	summary, err = PollNewsListIntoStorage(ctx, provider, logr.Discard(), s, b)
+	require.NoError(t, err)
+	assert.Equal(t, types.PollSummary{Saved: 1}, summary)
+	select {
+	case e := <-ch:
+		assert.Equal(t, events.ArticleUpgraded, e.Kind)
+		assert.Equal(t, "Corrected content", e.Article.Content)
+	default:
+		assert.Fail(t, "replaced article should be published")
+	}
+	ids, err := s.GetNewsWithoutDetailsIDs(ctx, "t94")
+	require.NoError(t, err)
+	assert.Nil(t, ids)
+	got, err = s.Get(ctx, listed.Id)
+	require.NoError(t, err)
+	assert.Equal(t, "Corrected content", got.Content)
+	assert.True(t, got.UpdatedAt.Equal(editedAt))
+	assert.True(t, got.Pinned, "pinning should be kept")
+	revisions, err := s.ListRevisions(ctx, listed.Id)
+	require.NoError(t, err)
+	require.Len(t, revisions, 1)
+	assert.Equal(t, detailed.Content, revisions[0].Article.Content)
+
+	summary, err = PollNewsListIntoStorage(ctx, provider, logr.Discard(), s, b)
+	require.NoError(t, err)
+	assert.Equal(t, types.PollSummary{Skipped: 1}, summary)
+}
+
+func TestPollArticleWithoutUpdatedAt(t *testing.T) {
+	ctx := context.Background()
+	s := memory.NewMemStorage()
+	b := events.NewBroker(10)
+	// article stored before last update was kept
+	stored := storagetest.NewArticle(t, "t94", 1, true)
+	stored.UpdatedAt = time.Time{}
+	require.NoError(t, s.Write(ctx, stored))
+	listed := storagetest.NewArticle(t, "t94", 1, false)
+	listed.UpdatedAt = listed.Published.Add(time.Hour)
+	provider := &fakeProvider{list: []types.Article{listed}}
+
+	summary, err := PollNewsListIntoStorage(ctx, provider, logr.Discard(), s, b)
+	require.NoError(t, err)
+	assert.Equal(t, types.PollSummary{Skipped: 1}, summary)
+	assert.Empty(t, provider.fetched, "details should not be fetched")
+	got, err := s.Get(ctx, stored.Id)
+	require.NoError(t, err)
+	assert.True(t, got.UpdatedAt.Equal(listed.UpdatedAt))
+	assert.Equal(t, stored.Content, got.Content)
+	revisions, err := s.ListRevisions(ctx, stored.Id)
+	require.NoError(t, err)
+	assert.Empty(t, revisions)
+
+	summary, err = PollNewsListIntoStorage(ctx, provider, logr.Discard(), s, b)
+	require.NoError(t, err)
+	assert.Equal(t, types.PollSummary{Skipped: 1}, summary)
+	assert.Empty(t, provider.fetched)
+}
+
+func TestPollRetractedArticles(t *testing.T) {
+	ctx := context.Background()
+	s := memory.NewMemStorage()
+	b := events.NewBroker(10)
+	var articles []types.Article
+	for n := 1; n <= 4; n++ {
+		articles = append(articles, storagetest.NewArticle(t, "t94", n, false))
+	}
+	provider := &fakeProvider{list: articles}
+	summary, err := PollNewsListIntoStorage(ctx, provider, logr.Discard(), s, b)
+	require.NoError(t, err)
+	assert.Equal(t, types.PollSummary{Saved: 4}, summary)
+
+	// 4 is withdrawn and 3 vanished, although it is newer than the oldest listed article
+	withdrawn := articles[3]
+	retractedAt := withdrawn.Published.Add(time.Hour)
+	withdrawn.RetractedAt = &retractedAt
+	provider.list = []types.Article{withdrawn, articles[1], articles[0]}
+	summary, err = PollNewsListIntoStorage(ctx, provider, logr.Discard(), s, b)
+	require.NoError(t, err)
+	assert.Equal(t, types.PollSummary{Saved: 2, Skipped: 2}, summary)
+	for _, a := range []types.Article{articles[2], articles[3]} {
+		got, err := s.Get(ctx, a.Id)
+		require.NoError(t, err)
+		assert.NotNil(t, got.RetractedAt, a.NewsId)
+	}
+	got, err := s.Get(ctx, articles[3].Id)
+	require.NoError(t, err)
+	assert.True(t, got.RetractedAt.Equal(retractedAt))
+	ids, err := s.GetNewsWithoutDetailsIDs(ctx, "t94")
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{articles[0].NewsId, articles[1].NewsId}, ids)
+
+	// article that is listed again is brought back, withdrawn one stays retracted
+	provider.list = []types.Article{withdrawn, articles[2], articles[1], articles[0]}
+	summary, err = PollNewsListIntoStorage(ctx, provider, logr.Discard(), s, b)
+	require.NoError(t, err)
+	assert.Equal(t, types.PollSummary{Saved: 1, Skipped: 3}, summary)
+	got, err = s.Get(ctx, articles[2].Id)
+	require.NoError(t, err)
+	assert.Nil(t, got.RetractedAt)
+}
+
+func TestInListWindow(t *testing.T) {
+	older := storagetest.NewArticle(t, "t94", 1, false)
+	listed := storagetest.NewArticle(t, "t94", 2, false)
+	newer := storagetest.NewArticle(t, "t94", 3, false)
+	pl := &Poller{listWindows: make(map[string]time.Time)}
+	provider := windowRecorder{
+		NewsProvider: &fakeProvider{list: []types.Article{newer, listed}},
+		record: func(oldest time.Time) {
+			pl.recordListWindow("t94", oldest)
+		},
+	}
+	pl.feeds = []Feed{{Config: FeedConfig{TeamId: "t94"}, Provider: provider}}
+
+	// nothing is known before the list is polled
+	assert.True(t, pl.InListWindow(older))
+	assert.False(t, pl.InListWindow(storagetest.NewArticle(t, "t1", 1, false)), "team without feed is never listed")
+
+	_, err := provider.ListLatest(context.Background())
+	require.NoError(t, err)
+	assert.False(t, pl.InListWindow(older))
+	assert.True(t, pl.InListWindow(listed))
+	assert.True(t, pl.InListWindow(newer))
+}
+
+func TestPollFeedsContinuesAfterFailure(t *testing.T) {
+	s := memory.NewMemStorage()
+	pl := &Poller{
+		s:           s,
+		p:           events.NewBroker(10),
+		logger:      logr.Discard(),
+		listWindows: make(map[string]time.Time),
+		feeds: []Feed{
+			{Config: FeedConfig{TeamId: "t1"}, Provider: &fakeProvider{listErr: errors.New("t1 is down")}},
+			{Config: FeedConfig{TeamId: "t94"}, Provider: &fakeProvider{list: []types.Article{storagetest.NewArticle(t, "t94", 1, false)}}},
+			{Config: FeedConfig{TeamId: "t8"}, Provider: &fakeProvider{listErr: errors.New("t8 is down")}},
+		},
+	}
+	summary, err := pl.PollList(context.Background(), "")
+	assert.Equal(t, types.PollSummary{Saved: 1}, summary, "feed after failing one should be polled")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "t1 is down")
+	assert.Contains(t, err.Error(), "t8 is down")
+}
+
+func TestPollListWithUnparsableNews(t *testing.T) {
+	ctx := context.Background()
+	item := func(id string, published string) string {
+		return "<NewsletterNewsItem><NewsArticleID>" + id + "</NewsArticleID><PublishDate>" + published +
+			"</PublishDate><Title>News " + id + "</Title><IsPublished>True</IsPublished></NewsletterNewsItem>"
+	}
+	list := item("3", "2023-03-04 18:58:00") + item("2", "2023-03-04 16:58:00") + item("1", "2023-03-04 10:23:34")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("<NewListInformation><NewsletterNewsItems>" + list + "</NewsletterNewsItems></NewListInformation>"))
+	}))
+	defer server.Close()
+	var config Config
+	config.TeamId = "t94"
+	config.List.URL = server.URL
+	provider := NewInCrowdProvider(config, server.Client(), logr.Discard())
+	s := memory.NewMemStorage()
+	b := events.NewBroker(10)
+	summary, err := PollNewsListIntoStorage(ctx, provider, logr.Discard(), s, b)
+	require.NoError(t, err)
+	assert.Equal(t, types.PollSummary{Saved: 3}, summary)
+
+	// stored news 2 cannot be parsed anymore, it has to stay as it is and not be taken for vanished
+	list = item("3", "2023-03-04 18:58:00") + item("2", "yesterday") + item("1", "2023-03-04 10:23:34")
+	articles, err := provider.ListLatest(ctx)
+	assert.ErrorIs(t, err, ListIncomplete)
+	assert.Len(t, articles, 2)
+	summary, err = PollNewsListIntoStorage(ctx, provider, logr.Discard(), s, b)
+	require.NoError(t, err)
+	assert.Equal(t, types.PollSummary{Skipped: 2}, summary)
+	page, err := s.ListPage(ctx, storage.PageQuery{Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, page.Articles, 3)
+	for _, a := range page.Articles {
+		assert.Nil(t, a.RetractedAt, a.NewsId)
+	}
+}
+
+func TestRefreshArticleFetchFails(t *testing.T) {
+	ctx := context.Background()
+	s := memory.NewMemStorage()
+	a := storagetest.NewArticle(t, "t94", 1, true)
+	require.NoError(t, s.Write(ctx, a))
+	pl := &Poller{
+		s:      s,
+		p:      events.NewBroker(10),
+		logger: logr.Discard(),
+		feeds:  []Feed{{Config: FeedConfig{TeamId: "t94"}, Provider: &fakeProvider{err: errors.New("upstream is down")}}},
+	}
+	summary, err := pl.RefreshArticle(ctx, a.Id)
+	assert.Equal(t, types.PollSummary{Failed: 1}, summary)
+	require.Error(t, err, "failed fetch should fail the job")
+	assert.Contains(t, err.Error(), "upstream is down")
+}
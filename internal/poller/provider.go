@@ -0,0 +1,108 @@
+package poller
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"github.com/adamdyszy/sportsnews/internal/metrics"
+	"github.com/adamdyszy/sportsnews/internal/tracing"
+	"github.com/adamdyszy/sportsnews/types"
+	"github.com/go-logr/logr"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+/*
+NewsProvider is source of news for the poller.
+
+Every implementation turns its feed into articles,
+so the poller does not depend on shape of the feed.
+*/
+type NewsProvider interface {
+	// ListLatest returns newest articles from the feed, they do not need to have details,
+	// when some news cannot be parsed the others are returned with error wrapping ListIncomplete
+	ListLatest(ctx context.Context) ([]types.Article, error)
+	// FetchDetails returns article with details of news with given id, NewsNotFound is returned when there is no such news
+	FetchDetails(ctx context.Context, newsId string) (types.Article, error)
+}
+
+// ListIncomplete is returned by NewsProvider.ListLatest together with articles when some listed news could not be parsed
+var ListIncomplete = errors.New("some listed news could not be parsed")
+
+// NewsNotFound is returned by NewsProvider.FetchDetails when the feed has no news with given id
+var NewsNotFound = errors.New("news not found")
+
+// StatusError is returned by requests to the provider answered with other status than 200 OK
+type StatusError struct {
+	Code int
+}
+
+func (e StatusError) Error() string {
+	return fmt.Sprintf("status error: %v", e.Code)
+}
+
+// Kinds of news providers that can be set in poller config under kind key
+const (
+	ProviderKindInCrowd = "incrowd"
+	ProviderKindRSS     = "rss"
+)
+
+// NewNewsProvider creates provider chosen by config Kind, empty kind means ProviderKindInCrowd
+func NewNewsProvider(config FeedConfig, logger logr.Logger) (NewsProvider, error) {
+	// every request to the provider is traced as child span of the poll
+	client := &http.Client{Transport: otelhttp.NewTransport(http.DefaultTransport)}
+	switch config.Kind {
+	case ProviderKindInCrowd, "":
+		return NewInCrowdProvider(config, client, logger), nil
+	case ProviderKindRSS:
+		return NewRSSProvider(config, client, logger), nil
+	}
+	return nil, fmt.Errorf("unknown news provider kind %q", config.Kind)
+}
+
+// getXML does GET request to rawURL with added query parameters and decodes XML response into v
+func getXML(ctx context.Context, client *http.Client, rawURL string, query url.Values, v interface{}, logger logr.Logger) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", rawURL, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create new GET request: %w", err)
+	}
+	q := req.URL.Query()
+	for key, values := range query {
+		for _, value := range values {
+			q.Add(key, value)
+		}
+	}
+	req.URL.RawQuery = q.Encode()
+	host, path := req.URL.Host, req.URL.Path
+	start := time.Now()
+	resp, err := client.Do(req)
+	metrics.PollerFetchDuration.WithLabelValues(host, path).Observe(time.Since(start).Seconds())
+	if err != nil {
+		return fmt.Errorf("failed to do http request: %w", err)
+	}
+	metrics.PollerUpstreamResponses.WithLabelValues(host, path, strconv.Itoa(resp.StatusCode)).Inc()
+	defer func(Body io.ReadCloser) {
+		err := Body.Close()
+		if err != nil {
+			logger.Error(err, "Error during close of response.")
+		}
+	}(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return StatusError{Code: resp.StatusCode}
+	}
+	_, span := tracing.Tracer().Start(ctx, "poller.decode")
+	dec := xml.NewDecoder(resp.Body)
+	dec.Strict = false
+	err = dec.Decode(v)
+	tracing.End(span, err)
+	if err != nil {
+		metrics.PollerDecodeFailures.WithLabelValues(host, path).Inc()
+		return fmt.Errorf("could not decode response: %w", err)
+	}
+	return nil
+}
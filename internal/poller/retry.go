@@ -0,0 +1,90 @@
+package poller
+
+import (
+	"context"
+	"errors"
+	"github.com/adamdyszy/sportsnews/storage"
+	"github.com/adamdyszy/sportsnews/types"
+	"time"
+)
+
+/*
+RetryQueue keeps news ids whose details could not be polled.
+
+Failed news id waits with exponential backoff before details polling attempts it again,
+after RetryConfig.MaxAttempts failures it is dead and it is not attempted until it is replayed.
+*/
+type RetryQueue struct {
+	config RetryConfig
+	rs     storage.RetryStorage
+	// now is replaced in tests
+	now func() time.Time
+}
+
+// NewRetryQueue creates RetryQueue, config has to be valid
+func NewRetryQueue(config RetryConfig, rs storage.RetryStorage) *RetryQueue {
+	return &RetryQueue{config: config, rs: rs, now: time.Now}
+}
+
+// backoff returns wait after given number of failed attempts
+func (q *RetryQueue) backoff(attempts int) time.Duration {
+	wait := time.Duration(q.config.InitialBackoffSeconds) * time.Second
+	maxWait := time.Duration(q.config.MaxBackoffSeconds) * time.Second
+	for n := 1; n < attempts && (maxWait == 0 || wait < maxWait); n++ {
+		wait *= 2
+	}
+	if maxWait > 0 && wait > maxWait {
+		return maxWait
+	}
+	return wait
+}
+
+// pending returns retries of the team by news id
+func (q *RetryQueue) pending(ctx context.Context, teamId string) (map[string]types.DetailsRetry, error) {
+	retries, err := q.rs.ListRetries(ctx, teamId)
+	if err != nil {
+		return nil, err
+	}
+	byNewsId := make(map[string]types.DetailsRetry, len(retries))
+	for _, retry := range retries {
+		byNewsId[retry.NewsId] = retry
+	}
+	return byNewsId, nil
+}
+
+// due tells if news id with the retry can be attempted now
+func (q *RetryQueue) due(retry types.DetailsRetry) bool {
+	return !retry.Dead && (retry.NextAttemptAt == nil || !q.now().Before(*retry.NextAttemptAt))
+}
+
+// failed records failed attempt of the news id and returns its retry, it is dead after too many attempts
+func (q *RetryQueue) failed(ctx context.Context, teamId string, newsId string, cause error) (types.DetailsRetry, error) {
+	retry, err := q.rs.GetRetry(ctx, teamId, newsId)
+	if err != nil {
+		if !errors.Is(err, storage.RetryNotFound) {
+			return retry, err
+		}
+		retry = types.DetailsRetry{TeamId: teamId, NewsId: newsId}
+	}
+	now := q.now().UTC()
+	retry.Attempts++
+	retry.LastError = cause.Error()
+	retry.LastAttemptAt = now
+	retry.NextAttemptAt = nil
+	if retry.Attempts >= q.config.MaxAttempts {
+		retry.Dead = true
+	} else {
+		next := now.Add(q.backoff(retry.Attempts))
+		retry.NextAttemptAt = &next
+	}
+	return retry, q.rs.SaveRetry(ctx, retry)
+}
+
+// forget removes retry of the news id, it is fine when there is none
+func (q *RetryQueue) forget(ctx context.Context, teamId string, newsId string) error {
+	err := q.rs.DeleteRetry(ctx, teamId, newsId)
+	if err != nil && !errors.Is(err, storage.RetryNotFound) {
+		return err
+	}
+	return nil
+}
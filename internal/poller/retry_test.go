@@ -0,0 +1,141 @@
+package poller
+
+import (
+	"context"
+	"github.com/adamdyszy/sportsnews/internal/events"
+	"github.com/adamdyszy/sportsnews/internal/storage/memory"
+	"github.com/adamdyszy/sportsnews/storage"
+	"github.com/adamdyszy/sportsnews/storage/storagetest"
+	"github.com/adamdyszy/sportsnews/types"
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+// newTestRetryQueue creates queue that kills news id after 3 attempts waiting 1, 2 and at most 3 minutes
+func newTestRetryQueue() *RetryQueue {
+	return NewRetryQueue(RetryConfig{MaxAttempts: 3, InitialBackoffSeconds: 60, MaxBackoffSeconds: 180}, memory.NewMemRetryStorage())
+}
+
+func TestBackoff(t *testing.T) {
+	q := newTestRetryQueue()
+	assert.Equal(t, time.Minute, q.backoff(1))
+	assert.Equal(t, 2*time.Minute, q.backoff(2))
+	assert.Equal(t, 3*time.Minute, q.backoff(3))
+	assert.Equal(t, 3*time.Minute, q.backoff(100))
+	q.config.MaxBackoffSeconds = 0
+	assert.Equal(t, 8*time.Minute, q.backoff(4))
+}
+
+func TestPollDetailsRetries(t *testing.T) {
+	ctx := context.Background()
+	s := memory.NewMemStorage()
+	b := events.NewBroker(10)
+	q := newTestRetryQueue()
+	now := time.Date(2023, 3, 1, 12, 0, 0, 0, time.UTC)
+	q.now = func() time.Time { return now }
+	var listed []types.Article
+	for n := 1; n <= 3; n++ {
+		a := storagetest.NewArticle(t, "t94", n, false)
+		require.NoError(t, s.Write(ctx, a))
+		listed = append(listed, a)
+	}
+	// details of news 2 cannot be fetched
+	provider := &fakeProvider{details: detailsOf(t, 1, 3)}
+
+	summary, err := PollNewsDetailsIntoStorage(ctx, "t94", provider, logr.Discard(), s, b, q)
+	require.NoError(t, err)
+	assert.Equal(t, types.PollSummary{Saved: 2, Failed: 1}, summary)
+	retry, err := q.rs.GetRetry(ctx, "t94", "2")
+	require.NoError(t, err)
+	assert.Equal(t, 1, retry.Attempts)
+	assert.Contains(t, retry.LastError, "news not found with id 2")
+	assert.Equal(t, now.Add(time.Minute), *retry.NextAttemptAt)
+
+	// news id is not polled before its next attempt
+	provider.fetched = nil
+	summary, err = PollNewsDetailsIntoStorage(ctx, "t94", provider, logr.Discard(), s, b, q)
+	require.NoError(t, err)
+	assert.Equal(t, types.PollSummary{}, summary)
+	assert.Empty(t, provider.fetched)
+
+	for attempt := 2; attempt <= 3; attempt++ {
+		now = now.Add(3 * time.Minute)
+		_, err = PollNewsDetailsIntoStorage(ctx, "t94", provider, logr.Discard(), s, b, q)
+		require.NoError(t, err)
+	}
+	retry, err = q.rs.GetRetry(ctx, "t94", "2")
+	require.NoError(t, err)
+	assert.Equal(t, 3, retry.Attempts)
+	assert.True(t, retry.Dead)
+	assert.Nil(t, retry.NextAttemptAt)
+
+	// dead news id is not polled anymore
+	provider.fetched = nil
+	now = now.Add(time.Hour)
+	_, err = PollNewsDetailsIntoStorage(ctx, "t94", provider, logr.Discard(), s, b, q)
+	require.NoError(t, err)
+	assert.Empty(t, provider.fetched)
+
+	// once details can be fetched replayed news id is saved and removed from the queue
+	provider.details["2"] = storagetest.NewArticle(t, "t94", 2, true)
+	pl := &Poller{
+		feeds:  []Feed{{Config: FeedConfig{TeamId: "t94"}, Provider: provider}},
+		s:      s,
+		p:      b,
+		q:      q,
+		logger: logr.Discard(),
+	}
+	summary, err = pl.ReplayDetails(ctx, "t94", "2")
+	require.NoError(t, err)
+	assert.Equal(t, types.PollSummary{Saved: 1}, summary)
+	_, err = q.rs.GetRetry(ctx, "t94", "2")
+	assert.ErrorIs(t, err, storage.RetryNotFound)
+	got, err := s.Get(ctx, listed[1].Id)
+	require.NoError(t, err)
+	assert.True(t, got.HasDetails)
+}
+
+func TestReplayDetailsFailsAgain(t *testing.T) {
+	ctx := context.Background()
+	q := newTestRetryQueue()
+	require.NoError(t, q.rs.SaveRetry(ctx, types.DetailsRetry{TeamId: "t94", NewsId: "7", Attempts: 3, Dead: true}))
+	pl := &Poller{
+		feeds:  []Feed{{Config: FeedConfig{TeamId: "t94"}, Provider: &fakeProvider{}}},
+		s:      memory.NewMemStorage(),
+		p:      events.NewBroker(10),
+		q:      q,
+		logger: logr.Discard(),
+	}
+
+	// news id is never replayed against feed of other team
+	for _, teamId := range []string{"", "t8"} {
+		_, err := pl.ReplayDetails(ctx, teamId, "7")
+		assert.ErrorIs(t, err, FeedNotFound, teamId)
+	}
+	retry, err := q.rs.GetRetry(ctx, "t94", "7")
+	require.NoError(t, err)
+	assert.True(t, retry.Dead)
+
+	summary, err := pl.ReplayDetails(ctx, "t94", "7")
+	assert.Error(t, err)
+	assert.Equal(t, types.PollSummary{Failed: 1}, summary)
+	retry, err = q.rs.GetRetry(ctx, "t94", "7")
+	require.NoError(t, err)
+	assert.Equal(t, 1, retry.Attempts, "replay gives all attempts again")
+	assert.False(t, retry.Dead)
+}
+
+func TestPollDetailsForgetsStaleRetries(t *testing.T) {
+	ctx := context.Background()
+	q := newTestRetryQueue()
+	require.NoError(t, q.rs.SaveRetry(ctx, types.DetailsRetry{TeamId: "t94", NewsId: "7", Attempts: 3, Dead: true}))
+
+	// article of news 7 is not stored anymore
+	_, err := PollNewsDetailsIntoStorage(ctx, "t94", &fakeProvider{}, logr.Discard(), memory.NewMemStorage(), events.NewBroker(10), q)
+	require.NoError(t, err)
+	_, err = q.rs.GetRetry(ctx, "t94", "7")
+	assert.ErrorIs(t, err, storage.RetryNotFound)
+}
@@ -0,0 +1,311 @@
+package poller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/adamdyszy/sportsnews/types"
+	"github.com/go-logr/logr"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+RSSFeed is struct for getting news from RSS 2.0 or Atom feed.
+
+RSS feed fills Channel and Atom feed fills Entries, the root element is not checked.
+*/
+type RSSFeed struct {
+	Channel struct {
+		Items []RSSItem `xml:"item"`
+	} `xml:"channel"`
+	Entries []AtomEntry `xml:"entry"`
+}
+
+// RSSItem is single item of RSS 2.0 feed
+type RSSItem struct {
+	Title       string   `xml:"title"`
+	Link        string   `xml:"link"`
+	Description string   `xml:"description"`
+	GUID        string   `xml:"guid"`
+	PubDate     string   `xml:"pubDate"`
+	Categories  []string `xml:"category"`
+	Enclosures  []Media  `xml:"enclosure"`
+	Media       []Media  `xml:"http://search.yahoo.com/mrss/ content"`
+	// ContentEncoded is full content of the item when feed has it
+	ContentEncoded string `xml:"http://purl.org/rss/1.0/modules/content/ encoded"`
+}
+
+// AtomEntry is single entry of Atom feed
+type AtomEntry struct {
+	ID        string      `xml:"http://www.w3.org/2005/Atom id"`
+	Title     string      `xml:"http://www.w3.org/2005/Atom title"`
+	Summary   string      `xml:"http://www.w3.org/2005/Atom summary"`
+	Content   AtomContent `xml:"http://www.w3.org/2005/Atom content"`
+	Published string      `xml:"http://www.w3.org/2005/Atom published"`
+	Updated   string      `xml:"http://www.w3.org/2005/Atom updated"`
+	Links     []struct {
+		Href string `xml:"href,attr"`
+		Rel  string `xml:"rel,attr"`
+		Type string `xml:"type,attr"`
+	} `xml:"http://www.w3.org/2005/Atom link"`
+	Categories []struct {
+		Term string `xml:"term,attr"`
+	} `xml:"http://www.w3.org/2005/Atom category"`
+	Media []Media `xml:"http://search.yahoo.com/mrss/ content"`
+}
+
+// AtomContent is atom content element, xhtml content comes as child elements instead of text
+type AtomContent struct {
+	Type  string `xml:"type,attr"`
+	Text  string `xml:",chardata"`
+	Inner string `xml:",innerxml"`
+}
+
+// String returns markup of xhtml content and text of other content types
+func (c AtomContent) String() string {
+	if c.Type == "xhtml" {
+		return strings.TrimSpace(c.Inner)
+	}
+	return c.Text
+}
+
+// Media is RSS enclosure or media:content element
+type Media struct {
+	URL    string `xml:"url,attr"`
+	Type   string `xml:"type,attr"`
+	Medium string `xml:"medium,attr"`
+}
+
+// rssDateLayouts are layouts of dates used by feeds, RSS uses RFC 822 dates with few variants, Atom uses RFC 3339
+var rssDateLayouts = []string{
+	time.RFC1123Z,
+	time.RFC1123,
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+	"Mon, 2 Jan 2006 15:04:05 MST",
+	"2 Jan 2006 15:04:05 -0700",
+	time.RFC822Z,
+	time.RFC822,
+	time.RFC3339,
+}
+
+func parseRSSDate(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	for _, layout := range rssDateLayouts {
+		t, err := time.Parse(layout, s)
+		if err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unknown date format %q", s)
+}
+
+// mediaURLs returns first image and first video url from media elements
+func mediaURLs(media []Media) (imageURL string, videoURL string) {
+	for _, m := range media {
+		if m.URL == "" {
+			continue
+		}
+		switch {
+		case imageURL == "" && (m.Medium == "image" || strings.HasPrefix(m.Type, "image/")):
+			imageURL = m.URL
+		case videoURL == "" && (m.Medium == "video" || strings.HasPrefix(m.Type, "video/")):
+			videoURL = m.URL
+		}
+	}
+	return imageURL, videoURL
+}
+
+/*
+GetArticleFromRSSItem creates Article from RSS item.
+
+Item with full content in content:encoded is marked as having details.
+*/
+func GetArticleFromRSSItem(item RSSItem, teamId string) (types.Article, error) {
+	published, err := parseRSSDate(item.PubDate)
+	if err != nil {
+		return types.Article{}, err
+	}
+	newsId := strings.TrimSpace(item.GUID)
+	if newsId == "" {
+		newsId = strings.TrimSpace(item.Link)
+	}
+	imageURL, videoURL := mediaURLs(append(item.Enclosures, item.Media...))
+	article := types.Article{
+		ArticleKey: types.ArticleKey{
+			Published: published,
+			TeamId:    teamId,
+			NewsId:    newsId,
+		},
+		Content:    item.ContentEncoded,
+		ImageURL:   imageURL,
+		Teaser:     strings.TrimSpace(item.Description),
+		Title:      strings.TrimSpace(item.Title),
+		Type:       item.Categories,
+		URL:        strings.TrimSpace(item.Link),
+		VideoURL:   videoURL,
+		HasDetails: strings.TrimSpace(item.ContentEncoded) != "",
+		// RSS items have no date of the last edit
+		UpdatedAt: published,
+	}
+	err = article.SetGeneratedId()
+	if err != nil {
+		return types.Article{}, err
+	}
+	return article, nil
+}
+
+/*
+GetArticleFromAtomEntry creates Article from Atom entry.
+
+Entry with content element is marked as having details.
+*/
+func GetArticleFromAtomEntry(entry AtomEntry, teamId string) (types.Article, error) {
+	date := entry.Published
+	if date == "" {
+		date = entry.Updated
+	}
+	published, err := parseRSSDate(date)
+	if err != nil {
+		return types.Article{}, err
+	}
+	updated := published
+	if entry.Updated != "" {
+		updated, err = parseRSSDate(entry.Updated)
+		if err != nil {
+			return types.Article{}, err
+		}
+	}
+	var link string
+	var media []Media
+	for _, l := range entry.Links {
+		switch l.Rel {
+		case "alternate", "":
+			if link == "" {
+				link = l.Href
+			}
+		case "enclosure":
+			media = append(media, Media{URL: l.Href, Type: l.Type})
+		}
+	}
+	content := entry.Content.String()
+	var categories []string
+	for _, c := range entry.Categories {
+		categories = append(categories, c.Term)
+	}
+	imageURL, videoURL := mediaURLs(append(media, entry.Media...))
+	article := types.Article{
+		ArticleKey: types.ArticleKey{
+			Published: published,
+			TeamId:    teamId,
+			NewsId:    strings.TrimSpace(entry.ID),
+		},
+		Content:    content,
+		ImageURL:   imageURL,
+		Teaser:     strings.TrimSpace(entry.Summary),
+		Title:      strings.TrimSpace(entry.Title),
+		Type:       categories,
+		URL:        link,
+		VideoURL:   videoURL,
+		HasDetails: strings.TrimSpace(content) != "",
+		UpdatedAt:  updated,
+	}
+	err = article.SetGeneratedId()
+	if err != nil {
+		return types.Article{}, err
+	}
+	return article, nil
+}
+
+/*
+RSSProvider is NewsProvider for RSS 2.0 and Atom feeds.
+
+Only list url is used. Feeds have no endpoint with details of single item,
+so FetchDetails returns the item from the last read of the feed. It has details only when the feed
+has its full content, items with only summary stay without them until the content shows up in the feed.
+*/
+type RSSProvider struct {
+	config ListConfig
+	client *http.Client
+	logger logr.Logger
+	// mx guards items, which are articles from the last read of the feed by news id
+	mx    sync.Mutex
+	items map[string]types.Article
+}
+
+// NewRSSProvider creates RSSProvider, logger is used for items that cannot be turned into articles
+func NewRSSProvider(config ListConfig, client *http.Client, logger logr.Logger) *RSSProvider {
+	return &RSSProvider{
+		config: config,
+		client: client,
+		logger: logger.WithValues("provider", ProviderKindRSS),
+	}
+}
+
+func (p *RSSProvider) ListLatest(ctx context.Context) ([]types.Article, error) {
+	var feed RSSFeed
+	err := getXML(ctx, p.client, p.config.GetListURL(), nil, &feed, p.logger)
+	if err != nil {
+		return nil, err
+	}
+	articles := make([]types.Article, 0, len(feed.Channel.Items)+len(feed.Entries))
+	var unparsed []string
+	for _, item := range feed.Channel.Items {
+		article, err := GetArticleFromRSSItem(item, p.config.GetTeamId())
+		if err != nil {
+			p.logger.Error(err, fmt.Sprintf("Could not parse article from rss item %v", item.GUID))
+			unparsed = append(unparsed, item.GUID)
+			continue
+		}
+		articles = append(articles, article)
+	}
+	for _, entry := range feed.Entries {
+		article, err := GetArticleFromAtomEntry(entry, p.config.GetTeamId())
+		if err != nil {
+			p.logger.Error(err, fmt.Sprintf("Could not parse article from atom entry %v", entry.ID))
+			unparsed = append(unparsed, entry.ID)
+			continue
+		}
+		articles = append(articles, article)
+	}
+	if count := p.config.GetListCount(); count > 0 && len(articles) > count {
+		articles = articles[:count]
+	}
+	items := make(map[string]types.Article, len(articles))
+	for _, article := range articles {
+		items[article.NewsId] = article
+	}
+	p.mx.Lock()
+	p.items = items
+	p.mx.Unlock()
+	if len(unparsed) > 0 {
+		return articles, fmt.Errorf("%w with ids: %v", ListIncomplete, unparsed)
+	}
+	return articles, nil
+}
+
+// FetchDetails reads the feed only when the item is not in the last read of it, so polling details of many items reads it once
+func (p *RSSProvider) FetchDetails(ctx context.Context, newsId string) (types.Article, error) {
+	article, found := p.item(newsId)
+	if !found {
+		_, err := p.ListLatest(ctx)
+		if err != nil && !errors.Is(err, ListIncomplete) {
+			return types.Article{}, err
+		}
+		article, found = p.item(newsId)
+		if !found {
+			return types.Article{}, fmt.Errorf("%w with id %v, it is no longer in the feed", NewsNotFound, newsId)
+		}
+	}
+	return article, nil
+}
+
+// item returns article with news id from the last read of the feed
+func (p *RSSProvider) item(newsId string) (types.Article, bool) {
+	p.mx.Lock()
+	defer p.mx.Unlock()
+	article, found := p.items[newsId]
+	return article, found
+}
@@ -0,0 +1,133 @@
+package poller
+
+import (
+	"context"
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+const rssExample = `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0" xmlns:content="http://purl.org/rss/1.0/modules/content/" xmlns:media="http://search.yahoo.com/mrss/">
+<channel>
+  <title>Club news</title>
+  <item>
+    <title>Match report</title>
+    <link>https://club.example/news/1</link>
+    <description>We won</description>
+    <guid isPermaLink="false">news-1</guid>
+    <pubDate>Sat, 04 Mar 2023 18:58:00 +0000</pubDate>
+    <category>First Team</category>
+    <category>Match Report</category>
+    <enclosure url="https://club.example/1.jpg" type="image/jpeg" length="1"/>
+    <media:content url="https://club.example/1.mp4" medium="video"/>
+    <content:encoded><![CDATA[<p>Full report</p>]]></content:encoded>
+  </item>
+  <item>
+    <title>Teaser only</title>
+    <link>https://club.example/news/2</link>
+    <description>Short</description>
+    <pubDate>Fri, 3 Mar 2023 10:00:00 GMT</pubDate>
+  </item>
+</channel>
+</rss>`
+
+const atomExample = `<?xml version="1.0" encoding="utf-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom">
+  <title>Club news</title>
+  <entry>
+    <id>urn:news:7</id>
+    <title>Academy update</title>
+    <link href="https://club.example/news/7"/>
+    <link rel="enclosure" href="https://club.example/7.png" type="image/png"/>
+    <summary>Academy won</summary>
+    <content type="html">&lt;p&gt;All details&lt;/p&gt;</content>
+    <published>2023-03-05T02:00:11Z</published>
+    <category term="Academy"/>
+  </entry>
+  <entry>
+    <id>urn:news:8</id>
+    <title>Stadium works</title>
+    <link href="https://club.example/news/8"/>
+    <content type="xhtml"><div xmlns="http://www.w3.org/1999/xhtml"><p>Works <b>started</b></p></div></content>
+    <published>2023-03-06T02:00:11Z</published>
+  </entry>
+</feed>`
+
+func TestRSSProvider(t *testing.T) {
+	reads := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reads++
+		_, _ = w.Write([]byte(rssExample))
+	}))
+	defer server.Close()
+	var config FeedConfig
+	config.TeamId = "t1"
+	config.List.URL = server.URL
+	p := NewRSSProvider(config, server.Client(), logr.Discard())
+
+	articles, err := p.ListLatest(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, articles, 2)
+
+	full := articles[0]
+	assert.Equal(t, "news-1", full.NewsId)
+	assert.Equal(t, "t1", full.TeamId)
+	assert.Equal(t, time.Date(2023, 3, 4, 18, 58, 0, 0, time.UTC), full.Published.UTC())
+	assert.Equal(t, []string{"First Team", "Match Report"}, full.Type)
+	assert.Equal(t, "https://club.example/1.jpg", full.ImageURL)
+	assert.Equal(t, "https://club.example/1.mp4", full.VideoURL)
+	assert.Equal(t, "<p>Full report</p>", full.Content)
+	assert.True(t, full.HasDetails)
+
+	teaser := articles[1]
+	assert.Equal(t, "https://club.example/news/2", teaser.NewsId, "link is used when there is no guid")
+	assert.False(t, teaser.HasDetails)
+
+	detailed, err := p.FetchDetails(context.Background(), teaser.NewsId)
+	assert.NoError(t, err)
+	assert.False(t, detailed.HasDetails, "item with only description has no details")
+	assert.Equal(t, teaser.Id, detailed.Id)
+	detailed, err = p.FetchDetails(context.Background(), full.NewsId)
+	assert.NoError(t, err)
+	assert.True(t, detailed.HasDetails)
+	assert.Equal(t, 1, reads, "details of listed items should not read the feed again")
+	_, err = p.FetchDetails(context.Background(), "missing")
+	assert.ErrorIs(t, err, NewsNotFound)
+	assert.Equal(t, 2, reads, "feed should be read again for item missing from the last read")
+
+	// feed is read when nothing was listed yet
+	p = NewRSSProvider(config, server.Client(), logr.Discard())
+	detailed, err = p.FetchDetails(context.Background(), teaser.NewsId)
+	assert.NoError(t, err)
+	assert.Equal(t, teaser.Id, detailed.Id)
+}
+
+func TestAtomProvider(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(atomExample))
+	}))
+	defer server.Close()
+	var config FeedConfig
+	config.TeamId = "t1"
+	config.List.URL = server.URL
+	p := NewRSSProvider(config, server.Client(), logr.Discard())
+
+	articles, err := p.ListLatest(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, articles, 2)
+	a := articles[0]
+	assert.Equal(t, "urn:news:7", a.NewsId)
+	assert.Equal(t, "https://club.example/news/7", a.URL)
+	assert.Equal(t, "https://club.example/7.png", a.ImageURL)
+	assert.Equal(t, []string{"Academy"}, a.Type)
+	assert.Equal(t, "<p>All details</p>", a.Content)
+	assert.True(t, a.HasDetails)
+
+	xhtml := articles[1]
+	assert.Contains(t, xhtml.Content, "<p>Works <b>started</b></p>")
+	assert.True(t, xhtml.HasDetails)
+}
@@ -0,0 +1,109 @@
+package poller
+
+import (
+	"context"
+	"errors"
+	"github.com/adamdyszy/sportsnews/internal/tracing"
+	"github.com/adamdyszy/sportsnews/storage"
+	"github.com/adamdyszy/sportsnews/types"
+	"github.com/robfig/cron/v3"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"time"
+)
+
+// pollFunc is single run of poller job
+type pollFunc func(ctx context.Context) (types.PollSummary, error)
+
+// jobState is recorded state of poller cron job
+type jobState struct {
+	entryId cron.EntryID
+	status  types.PollerJobStatus
+}
+
+// addJob adds fn to cron and records state of its every run
+func (pl *Poller) addJob(ctx context.Context, config FeedConfig, job string, schedule string, fn pollFunc) error {
+	state := &jobState{status: types.PollerJobStatus{TeamId: config.TeamId, Job: job, Schedule: schedule}}
+	entryId, err := pl.cron.AddFunc(schedule, func() {
+		pl.record(ctx, state, fn)
+	})
+	if err != nil {
+		return err
+	}
+	state.entryId = entryId
+	pl.mx.Lock()
+	defer pl.mx.Unlock()
+	pl.jobs = append(pl.jobs, state)
+	return nil
+}
+
+// record runs fn in its own span and saves its result into state
+func (pl *Poller) record(ctx context.Context, state *jobState, fn pollFunc) {
+	ctx, span := tracing.Tracer().Start(ctx, "poller."+state.status.Job,
+		trace.WithAttributes(attribute.String("team.id", state.status.TeamId)),
+		trace.WithNewRoot(),
+	)
+	started := time.Now().UTC()
+	pl.mx.Lock()
+	state.status.Running = true
+	state.status.LastRun = &started
+	pl.mx.Unlock()
+
+	summary, err := fn(ctx)
+	span.SetAttributes(
+		attribute.Int("poll.saved", summary.Saved),
+		attribute.Int("poll.skipped", summary.Skipped),
+		attribute.Int("poll.failed", summary.Failed),
+	)
+	tracing.End(span, err)
+
+	finished := time.Now().UTC()
+	pl.mx.Lock()
+	defer pl.mx.Unlock()
+	state.status.Running = false
+	state.status.LastSummary = summary
+	state.status.ItemsProcessed += summary.Saved + summary.Skipped + summary.Failed
+	if err != nil {
+		state.status.LastError = err.Error()
+		state.status.LastErrorAt = &finished
+		return
+	}
+	state.status.LastSuccess = &finished
+}
+
+// Status returns state of all poller cron jobs
+func (pl *Poller) Status() []types.PollerJobStatus {
+	pl.mx.Lock()
+	defer pl.mx.Unlock()
+	statuses := make([]types.PollerJobStatus, 0, len(pl.jobs))
+	for _, state := range pl.jobs {
+		status := state.status
+		next := pl.cron.Entry(state.entryId).Next
+		if !next.IsZero() {
+			next = next.UTC()
+			status.NextRun = &next
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+// BackfillStatus returns saved backfill progress of every feed, it is empty when backfill is disabled
+func (pl *Poller) BackfillStatus(ctx context.Context) ([]types.BackfillProgress, error) {
+	if !pl.backfill.Enabled {
+		return nil, nil
+	}
+	var statuses []types.BackfillProgress
+	for _, feed := range pl.feeds {
+		progress, err := pl.bs.GetBackfillProgress(ctx, feed.Config.TeamId)
+		if err != nil {
+			if errors.Is(err, storage.BackfillProgressNotFound) {
+				// not started yet
+				continue
+			}
+			return nil, err
+		}
+		statuses = append(statuses, progress)
+	}
+	return statuses, nil
+}
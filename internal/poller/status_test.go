@@ -0,0 +1,72 @@
+package poller
+
+import (
+	"context"
+	"errors"
+	"github.com/adamdyszy/sportsnews/types"
+	"github.com/go-logr/logr"
+	"github.com/robfig/cron/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestStatus(t *testing.T) {
+	pl := &Poller{cron: cron.New(), logger: logr.Discard()}
+	config := FeedConfig{TeamId: "t94"}
+	var err error
+	err = pl.addJob(context.Background(), config, types.PollerJobList, "@every 1h", func(ctx context.Context) (types.PollSummary, error) {
+		return types.PollSummary{Saved: 2, Skipped: 3}, err
+	})
+	require.NoError(t, err)
+	pl.cron.Start()
+	defer pl.cron.Stop()
+
+	statuses := pl.Status()
+	require.Len(t, statuses, 1)
+	assert.Equal(t, "t94", statuses[0].TeamId)
+	assert.Equal(t, types.PollerJobList, statuses[0].Job)
+	assert.Nil(t, statuses[0].LastRun)
+	assert.NotNil(t, statuses[0].NextRun)
+
+	run := pl.cron.Entries()[0].Job.Run
+	run()
+	status := pl.Status()[0]
+	assert.NotNil(t, status.LastRun)
+	assert.NotNil(t, status.LastSuccess)
+	assert.Empty(t, status.LastError)
+	assert.Equal(t, types.PollSummary{Saved: 2, Skipped: 3}, status.LastSummary)
+	assert.Equal(t, 5, status.ItemsProcessed)
+
+	err = errors.New("provider is down")
+	run()
+	status = pl.Status()[0]
+	assert.Equal(t, "provider is down", status.LastError)
+	assert.NotNil(t, status.LastErrorAt)
+	assert.Equal(t, 10, status.ItemsProcessed)
+	assert.False(t, status.Running)
+}
+
+func TestStop(t *testing.T) {
+	pl := &Poller{cron: cron.New(), logger: logr.Discard()}
+	release := make(chan struct{})
+	err := pl.addJob(context.Background(), FeedConfig{TeamId: "t94"}, types.PollerJobDetails, "@every 1h", func(ctx context.Context) (types.PollSummary, error) {
+		<-release
+		return types.PollSummary{}, nil
+	})
+	require.NoError(t, err)
+	pl.cron.Start()
+	pl.bootJobs.Add(1)
+	go func() {
+		defer pl.bootJobs.Done()
+		pl.cron.Entries()[0].Job.Run()
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, pl.Stop(ctx), context.DeadlineExceeded)
+
+	close(release)
+	assert.NoError(t, pl.Stop(context.Background()))
+}
@@ -0,0 +1,244 @@
+/*
+Package retention removes old articles from the storage.
+
+Articles are removed when they are older than max age of a matching rule
+or when there are more than max count of them, the oldest are removed first.
+Pinned articles are never removed and do not count towards max count.
+Articles still covered by the latest news list of their feed are kept too,
+since list polling would save them again as new.
+*/
+package retention
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/adamdyszy/sportsnews/internal/tracing"
+	"github.com/adamdyszy/sportsnews/storage"
+	"github.com/adamdyszy/sportsnews/types"
+	"github.com/go-logr/logr"
+	"github.com/robfig/cron/v3"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"sort"
+	"time"
+)
+
+// pageLimit is how many articles are read from the storage at once when looking for the ones to remove
+const pageLimit = 100
+
+// Rule sets max age of articles of the team and taxonomy, empty TeamId or Type matches any
+type Rule struct {
+	TeamId     string `mapstructure:"teamId"`
+	Type       string `mapstructure:"type"`
+	MaxAgeDays int    `mapstructure:"maxAgeDays"`
+}
+
+// Config is the retention section of the config file
+type Config struct {
+	Enabled  bool   `mapstructure:"enabled"`
+	Schedule string `mapstructure:"schedule"`
+	// DryRun only logs articles that would be removed
+	DryRun bool `mapstructure:"dryRun"`
+	// MaxCount is how many articles are kept at most, 0 means no limit
+	MaxCount int    `mapstructure:"maxCount"`
+	Rules    []Rule `mapstructure:"rules"`
+}
+
+// Validate checks that config values can be used
+func (c Config) Validate() error {
+	if c.Enabled && c.Schedule == "" {
+		return errors.New("schedule is required when retention is enabled")
+	}
+	if c.MaxCount < 0 {
+		return fmt.Errorf("maxCount cannot be negative, got %v", c.MaxCount)
+	}
+	for n, rule := range c.Rules {
+		if rule.MaxAgeDays <= 0 {
+			return fmt.Errorf("maxAgeDays of rule %v has to be positive, got %v", n, rule.MaxAgeDays)
+		}
+	}
+	return nil
+}
+
+// Summary tells how many articles were matched by retention and how many of them were deleted or kept
+type Summary struct {
+	Matched int `json:"matched"`
+	Deleted int `json:"deleted"`
+	// Skipped were kept, because they are still listed by their feed or they were pinned meanwhile
+	Skipped int `json:"skipped"`
+	Failed  int `json:"failed"`
+}
+
+// ListWindow tells which articles the poller would save again after they were removed
+type ListWindow interface {
+	// InListWindow tells if article is still covered by the latest news listed by its feed
+	InListWindow(a types.Article) bool
+}
+
+// Pruner removes articles that are out of retention, on schedule after Start or on demand with Prune
+type Pruner struct {
+	config Config
+	s      storage.ArticleStorage
+	window ListWindow
+	cron   *cron.Cron
+	logger logr.Logger
+	// now is replaced in tests
+	now func() time.Time
+}
+
+// NewPruner creates Pruner, config has to be valid, articles in window are never removed
+func NewPruner(config Config, s storage.ArticleStorage, window ListWindow, logger logr.Logger) *Pruner {
+	return &Pruner{
+		config: config,
+		s:      s,
+		window: window,
+		cron:   cron.New(),
+		logger: logger.WithValues("workerKind", "RetentionPruner"),
+		now:    time.Now,
+	}
+}
+
+// Start schedules pruning when it is enabled, jobs use ctx and are stopped with Stop
+func (p *Pruner) Start(ctx context.Context) error {
+	if !p.config.Enabled {
+		p.logger.Info("Retention is disabled, articles are kept forever.")
+		return nil
+	}
+	p.logger.Info("Scheduling pruning with this config.", "config", p.config)
+	_, err := p.cron.AddFunc(p.config.Schedule, func() {
+		_, _ = p.Prune(ctx)
+	})
+	if err != nil {
+		return fmt.Errorf("error adding retention schedule: %w", err)
+	}
+	p.cron.Start()
+	return nil
+}
+
+// Stop stops scheduling and waits for running pruning until ctx is done, after cancelling ctx of Start it can be called again to wait for it
+func (p *Pruner) Stop(ctx context.Context) error {
+	select {
+	case <-p.cron.Stop().Done():
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("running pruning did not finish: %w", ctx.Err())
+	}
+}
+
+// Prune deletes articles out of retention, in dry run they are only logged
+func (p *Pruner) Prune(ctx context.Context) (summary Summary, err error) {
+	ctx, span := tracing.Tracer().Start(ctx, "retention.prune", trace.WithAttributes(attribute.Bool("retention.dryRun", p.config.DryRun)))
+	defer func() {
+		span.SetAttributes(attribute.Int("retention.matched", summary.Matched), attribute.Int("retention.deleted", summary.Deleted))
+		tracing.End(span, err)
+	}()
+	logger := p.logger.WithValues("dryRun", p.config.DryRun)
+	logger.Info("Looking for articles out of retention.")
+	outOfRetention, err := p.find(ctx)
+	if err != nil {
+		logger.Error(err, "Could not find articles out of retention.")
+		return summary, err
+	}
+	summary.Matched = len(outOfRetention)
+	for _, a := range outOfRetention {
+		if err = ctx.Err(); err != nil {
+			return summary, err
+		}
+		articleLogger := logger.WithValues("articleID", a.Id, "teamId", a.TeamId, "published", a.Published)
+		if p.window.InListWindow(a) {
+			articleLogger.V(1).Info("Kept article, it is still listed by its feed.")
+			summary.Skipped++
+			continue
+		}
+		if p.config.DryRun {
+			articleLogger.Info("Article would be removed.")
+			continue
+		}
+		// pinning is checked again by the storage, article could be pinned since it was found
+		err = p.s.DeleteUnpinned(ctx, a.Id)
+		if errors.Is(err, storage.ArticlePinned) {
+			articleLogger.V(1).Info("Kept article, it was pinned.")
+			summary.Skipped++
+			continue
+		}
+		if err != nil {
+			articleLogger.Error(err, "Could not remove article.")
+			summary.Failed++
+			continue
+		}
+		articleLogger.V(1).Info("Removed article.")
+		summary.Deleted++
+	}
+	logger.Info("Pruning finished.", "matched", summary.Matched, "deleted", summary.Deleted, "skipped", summary.Skipped, "failed", summary.Failed)
+	if summary.Failed > 0 {
+		return summary, fmt.Errorf("could not remove %v articles", summary.Failed)
+	}
+	return summary, nil
+}
+
+// find returns articles out of retention ordered from the oldest
+func (p *Pruner) find(ctx context.Context) ([]types.Article, error) {
+	found := make(map[types.ArticleId]types.Article)
+	notPinned := false
+	now := p.now()
+	for _, rule := range p.config.Rules {
+		filter := storage.ArticleFilter{
+			TeamId:          rule.TeamId,
+			Type:            rule.Type,
+			PublishedBefore: now.AddDate(0, 0, -rule.MaxAgeDays),
+			Pinned:          &notPinned,
+		}
+		err := p.eachArticle(ctx, storage.SortByPublished, filter, func(a types.Article) {
+			found[a.Id] = a
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	if p.config.MaxCount > 0 {
+		// articles already removed by the rules do not count towards max count
+		kept := 0
+		err := p.eachArticle(ctx, storage.SortByPublishedDesc, storage.ArticleFilter{Pinned: &notPinned}, func(a types.Article) {
+			if _, ok := found[a.Id]; ok {
+				return
+			}
+			kept++
+			if kept > p.config.MaxCount {
+				found[a.Id] = a
+			}
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	articles := make([]types.Article, 0, len(found))
+	for _, a := range found {
+		articles = append(articles, a)
+	}
+	sort.Slice(articles, func(i, j int) bool {
+		if articles[i].Published.Equal(articles[j].Published) {
+			return articles[i].Id < articles[j].Id
+		}
+		return articles[i].Published.Before(articles[j].Published)
+	})
+	return articles, nil
+}
+
+// eachArticle calls fn with every article matching filter in given order
+func (p *Pruner) eachArticle(ctx context.Context, order storage.SortOrder, filter storage.ArticleFilter, fn func(a types.Article)) error {
+	q := storage.PageQuery{Limit: pageLimit, Sort: order, Filter: filter}
+	for {
+		page, err := p.s.ListPage(ctx, q)
+		if err != nil {
+			return err
+		}
+		for _, a := range page.Articles {
+			fn(a)
+		}
+		if !page.HasMore {
+			return nil
+		}
+		q.Cursor = page.NextCursor
+	}
+}
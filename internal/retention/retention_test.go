@@ -0,0 +1,142 @@
+package retention
+
+import (
+	"context"
+	"github.com/adamdyszy/sportsnews/internal/storage/memory"
+	"github.com/adamdyszy/sportsnews/storage"
+	"github.com/adamdyszy/sportsnews/storage/storagetest"
+	"github.com/adamdyszy/sportsnews/types"
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+// writeDays writes article of the team for every given day after 2023-02-17
+func writeDays(t *testing.T, s storage.ArticleStorage, teamId string, articleType string, days ...int) []types.Article {
+	var articles []types.Article
+	for _, day := range days {
+		a := storagetest.NewArticle(t, teamId, day*24*60, false)
+		a.Type = []string{articleType}
+		require.NoError(t, s.Write(context.Background(), a))
+		articles = append(articles, a)
+	}
+	return articles
+}
+
+// fakeWindow lists articles of teams published at or after their time
+type fakeWindow map[string]time.Time
+
+func (w fakeWindow) InListWindow(a types.Article) bool {
+	oldest, listed := w[a.TeamId]
+	return listed && !a.Published.Before(oldest)
+}
+
+func newTestPruner(config Config, s storage.ArticleStorage) *Pruner {
+	return newTestPrunerWithWindow(config, s, fakeWindow{})
+}
+
+func newTestPrunerWithWindow(config Config, s storage.ArticleStorage, window ListWindow) *Pruner {
+	p := NewPruner(config, s, window, logr.Discard())
+	p.now = func() time.Time {
+		return time.Date(2023, 3, 1, 0, 0, 0, 0, time.UTC)
+	}
+	return p
+}
+
+func storedIds(t *testing.T, s storage.ArticleStorage) []types.ArticleId {
+	list, err := s.List(context.Background())
+	require.NoError(t, err)
+	var ids []types.ArticleId
+	for _, a := range list {
+		ids = append(ids, a.Id)
+	}
+	return ids
+}
+
+func TestPruneMaxAge(t *testing.T) {
+	ctx := context.Background()
+	s := memory.NewMemStorage()
+	// 2023-03-01 is 12 days after 2023-02-17
+	t94 := writeDays(t, s, "t94", "News", 0, 5, 10)
+	academy := writeDays(t, s, "t1", "Academy", 1, 8)
+	other := writeDays(t, s, "t1", "News", 2)
+	require.NoError(t, s.SetPinned(ctx, t94[0].Id, true))
+
+	p := newTestPruner(Config{Rules: []Rule{
+		{TeamId: "t94", MaxAgeDays: 5},
+		{Type: "Academy", MaxAgeDays: 7},
+	}}, s)
+	summary, err := p.Prune(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, Summary{Matched: 2, Deleted: 2}, summary)
+	assert.ElementsMatch(t, []types.ArticleId{t94[0].Id, t94[2].Id, academy[1].Id, other[0].Id}, storedIds(t, s))
+}
+
+func TestPruneMaxCount(t *testing.T) {
+	ctx := context.Background()
+	s := memory.NewMemStorage()
+	articles := writeDays(t, s, "t94", "News", 0, 1, 2, 3, 4, 5)
+	require.NoError(t, s.SetPinned(ctx, articles[0].Id, true))
+
+	p := newTestPruner(Config{MaxCount: 2, Rules: []Rule{{MaxAgeDays: 10}}}, s)
+	summary, err := p.Prune(ctx)
+	require.NoError(t, err)
+	// day 1 is removed by the rule and days 2 and 3 by max count, pinned day 0 is kept
+	assert.Equal(t, Summary{Matched: 3, Deleted: 3}, summary)
+	assert.ElementsMatch(t, []types.ArticleId{articles[0].Id, articles[4].Id, articles[5].Id}, storedIds(t, s))
+}
+
+func TestPruneKeepsListed(t *testing.T) {
+	s := memory.NewMemStorage()
+	articles := writeDays(t, s, "t94", "News", 0, 1, 2, 3)
+	writeDays(t, s, "t1", "News", 4)
+
+	// the last list of t94 starts at day 2, so removed days 2 and 3 would be saved again by list polling
+	p := newTestPrunerWithWindow(Config{MaxCount: 1, Rules: []Rule{{MaxAgeDays: 1}}}, s, fakeWindow{"t94": articles[2].Published})
+	summary, err := p.Prune(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, Summary{Matched: 5, Deleted: 3, Skipped: 2}, summary)
+	assert.ElementsMatch(t, []types.ArticleId{articles[2].Id, articles[3].Id}, storedIds(t, s))
+}
+
+// pinningWindow pins every article it is asked about, like admin pinning it while pruning runs
+type pinningWindow struct {
+	s storage.ArticleStorage
+}
+
+func (w pinningWindow) InListWindow(a types.Article) bool {
+	_ = w.s.SetPinned(context.Background(), a.Id, true)
+	return false
+}
+
+func TestPruneKeepsPinnedMeanwhile(t *testing.T) {
+	s := memory.NewMemStorage()
+	writeDays(t, s, "t94", "News", 0, 1)
+
+	p := newTestPrunerWithWindow(Config{MaxCount: 1}, s, pinningWindow{s})
+	summary, err := p.Prune(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, Summary{Matched: 1, Skipped: 1}, summary)
+	assert.Len(t, storedIds(t, s), 2)
+}
+
+func TestPruneDryRun(t *testing.T) {
+	s := memory.NewMemStorage()
+	articles := writeDays(t, s, "t94", "News", 0, 1, 2)
+
+	p := newTestPruner(Config{DryRun: true, MaxCount: 1}, s)
+	summary, err := p.Prune(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, Summary{Matched: 2}, summary)
+	assert.Len(t, storedIds(t, s), len(articles))
+}
+
+func TestConfigValidate(t *testing.T) {
+	assert.NoError(t, Config{}.Validate())
+	assert.NoError(t, Config{Enabled: true, Schedule: "@daily", MaxCount: 10, Rules: []Rule{{TeamId: "t94", MaxAgeDays: 30}}}.Validate())
+	assert.Error(t, Config{Enabled: true}.Validate())
+	assert.Error(t, Config{MaxCount: -1}.Validate())
+	assert.Error(t, Config{Rules: []Rule{{TeamId: "t94"}}}.Validate())
+}
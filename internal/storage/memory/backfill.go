@@ -0,0 +1,43 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"github.com/adamdyszy/sportsnews/storage"
+	"github.com/adamdyszy/sportsnews/types"
+	"sync"
+)
+
+type backfillStorage struct {
+	progress map[string]types.BackfillProgress
+	mx       *sync.RWMutex
+}
+
+// NewMemBackfillStorage creates backfill storage, progress is lost on restart so backfill starts again
+func NewMemBackfillStorage() storage.BackfillStorage {
+	return &backfillStorage{
+		progress: make(map[string]types.BackfillProgress),
+		mx:       &sync.RWMutex{},
+	}
+}
+
+func (b *backfillStorage) GetBackfillProgress(_ context.Context, teamId string) (types.BackfillProgress, error) {
+	b.mx.RLock()
+	defer b.mx.RUnlock()
+	progress, found := b.progress[teamId]
+	if !found {
+		return types.BackfillProgress{}, fmt.Errorf("%w with teamId: %v", storage.BackfillProgressNotFound, teamId)
+	}
+	return progress, nil
+}
+
+func (b *backfillStorage) SaveBackfillProgress(_ context.Context, progress types.BackfillProgress) error {
+	b.mx.Lock()
+	defer b.mx.Unlock()
+	b.progress[progress.TeamId] = progress
+	return nil
+}
+
+func (b *backfillStorage) Disconnect() error {
+	return nil
+}
@@ -0,0 +1,103 @@
+package memory
+
+import (
+	"github.com/adamdyszy/sportsnews/storage"
+	"github.com/adamdyszy/sportsnews/types"
+	"sort"
+)
+
+type indexEntry struct {
+	key string
+	id  types.ArticleId
+}
+
+func (e indexEntry) less(o indexEntry) bool {
+	if e.key != o.key {
+		return e.key < o.key
+	}
+	return e.id < o.id
+}
+
+/*
+sortIndex keeps article ids in ascending order of their sort key and id.
+
+Descending orders are served by walking the same index backwards.
+*/
+type sortIndex struct {
+	order   storage.SortOrder
+	entries []indexEntry
+}
+
+func newSortIndex(field string) *sortIndex {
+	return &sortIndex{order: storage.SortOrder(field)}
+}
+
+func (s *sortIndex) entryOf(a types.Article) indexEntry {
+	return indexEntry{key: s.order.SortKey(a), id: a.Id}
+}
+
+// search returns position of the first entry that is not less than e
+func (s *sortIndex) search(e indexEntry) int {
+	return sort.Search(len(s.entries), func(n int) bool {
+		return !s.entries[n].less(e)
+	})
+}
+
+func (s *sortIndex) insert(a types.Article) {
+	e := s.entryOf(a)
+	n := s.search(e)
+	s.entries = append(s.entries, indexEntry{})
+	copy(s.entries[n+1:], s.entries[n:])
+	s.entries[n] = e
+}
+
+func (s *sortIndex) remove(a types.Article) {
+	e := s.entryOf(a)
+	n := s.search(e)
+	if n < len(s.entries) && s.entries[n] == e {
+		s.entries = append(s.entries[:n], s.entries[n+1:]...)
+	}
+}
+
+/*
+page returns at most limit ids following the cursor that pass match
+and tells if there are more of them after the returned ones.
+*/
+func (s *sortIndex) page(cursor storage.Cursor, descending bool, limit int, match func(types.ArticleId) bool) ([]types.ArticleId, bool) {
+	ids := make([]types.ArticleId, 0, limit)
+	after := indexEntry{key: cursor.Key, id: cursor.LastId}
+	if !descending {
+		n := 0
+		if cursor.LastId != "" {
+			// first entry greater than cursor
+			n = sort.Search(len(s.entries), func(n int) bool {
+				return after.less(s.entries[n])
+			})
+		}
+		for ; n < len(s.entries); n++ {
+			if !match(s.entries[n].id) {
+				continue
+			}
+			if len(ids) == limit {
+				return ids, true
+			}
+			ids = append(ids, s.entries[n].id)
+		}
+		return ids, false
+	}
+	n := len(s.entries) - 1
+	if cursor.LastId != "" {
+		// last entry less than cursor
+		n = s.search(after) - 1
+	}
+	for ; n >= 0; n-- {
+		if !match(s.entries[n].id) {
+			continue
+		}
+		if len(ids) == limit {
+			return ids, true
+		}
+		ids = append(ids, s.entries[n].id)
+	}
+	return ids, false
+}
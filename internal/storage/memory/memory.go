@@ -1,55 +1,113 @@
 package memory
 
 import (
+	"context"
 	"fmt"
 	"github.com/adamdyszy/sportsnews/storage"
 	"github.com/adamdyszy/sportsnews/types"
 	"sync"
+	"time"
 )
 
+// teamNewsId identifies news in feed of the team, news ids of different teams can be the same
+type teamNewsId struct {
+	teamId string
+	newsId string
+}
+
 type innerStorage struct {
 	articles          map[types.ArticleId]types.Article
-	newsIdsForDetails map[string]struct{}
-	mx                *sync.RWMutex
+	newsIdsForDetails map[teamNewsId]struct{}
+	// sortIndexes are kept per sort field so pages can be served without sorting everything
+	sortIndexes map[string]*sortIndex
+	searchIndex *searchIndex
+	// revisions are earlier versions of articles, oldest first
+	revisions map[types.ArticleId][]types.ArticleRevision
+	mx        *sync.RWMutex
 }
 
-func (i innerStorage) Delete(id types.ArticleId) error {
+func (i *innerStorage) Delete(_ context.Context, id types.ArticleId) error {
 	i.mx.Lock()
 	defer i.mx.Unlock()
-	delete(i.newsIdsForDetails, string(id))
+	article, found := i.articles[id]
+	if !found {
+		return nil
+	}
+	i.delete(article)
+	return nil
+}
+
+func (i *innerStorage) DeleteUnpinned(_ context.Context, id types.ArticleId) error {
+	i.mx.Lock()
+	defer i.mx.Unlock()
+	article, found := i.articles[id]
+	if !found {
+		return nil
+	}
+	if article.Pinned {
+		return fmt.Errorf("%w with id: %v", storage.ArticlePinned, id)
+	}
+	i.delete(article)
+	return nil
+}
+
+// delete removes stored article from all indexes, caller has to hold the lock
+func (i *innerStorage) delete(article types.Article) {
+	id := article.Id
+	delete(i.newsIdsForDetails, teamNewsId{article.TeamId, article.NewsId})
+	for _, index := range i.sortIndexes {
+		index.remove(article)
+	}
+	i.searchIndex.remove(article)
+	delete(i.articles, id)
+	delete(i.revisions, id)
+}
+
+func (i *innerStorage) Ping(_ context.Context) error {
 	return nil
 }
 
-func (i innerStorage) Disconnect() error {
+func (i *innerStorage) Disconnect() error {
 	return nil
 }
 
-func (i innerStorage) GetNewsWithoutDetailsIDs() ([]string, error) {
+func (i *innerStorage) GetNewsWithoutDetailsIDs(_ context.Context, teamId string) ([]string, error) {
 	i.mx.RLock()
 	defer i.mx.RUnlock()
-	v := make([]string, 0, len(i.newsIdsForDetails))
-	for newsId := range i.newsIdsForDetails {
-		v = append(v, newsId)
+	// nil when there are none, same as other storages
+	var v []string
+	for key := range i.newsIdsForDetails {
+		if teamId == "" || key.teamId == teamId {
+			v = append(v, key.newsId)
+		}
 	}
 	return v, nil
 }
 
+// NewMemStorage creates storage keeping articles in memory, its calls never block on I/O, so they ignore context
 func NewMemStorage() storage.ArticleStorage {
-	s := innerStorage{articles: make(map[types.ArticleId]types.Article), mx: &sync.RWMutex{}, newsIdsForDetails: make(map[string]struct{})}
+	s := &innerStorage{articles: make(map[types.ArticleId]types.Article), mx: &sync.RWMutex{}, newsIdsForDetails: make(map[teamNewsId]struct{})}
+	s.revisions = make(map[types.ArticleId][]types.ArticleRevision)
+	s.searchIndex = newSearchIndex()
+	s.sortIndexes = map[string]*sortIndex{
+		storage.SortFieldPublished: newSortIndex(storage.SortFieldPublished),
+		storage.SortFieldTitle:     newSortIndex(storage.SortFieldTitle),
+		storage.SortFieldTeamId:    newSortIndex(storage.SortFieldTeamId),
+	}
 	return s
 }
 
-func (i innerStorage) Get(id types.ArticleId) (types.Article, error) {
+func (i *innerStorage) Get(_ context.Context, id types.ArticleId) (types.Article, error) {
 	i.mx.RLock()
 	defer i.mx.RUnlock()
 	val, found := i.articles[id]
 	if found {
 		return val, nil
 	}
-	return types.Article{}, fmt.Errorf("article with id: %v doesn't exist in memory storage", id)
+	return types.Article{}, fmt.Errorf("%w with id: %v", storage.ArticleNotFound, id)
 }
 
-func (i innerStorage) List() ([]types.Article, error) {
+func (i *innerStorage) List(_ context.Context) ([]types.Article, error) {
 	i.mx.RLock()
 	defer i.mx.RUnlock()
 	v := make([]types.Article, 0, len(i.articles))
@@ -59,19 +117,130 @@ func (i innerStorage) List() ([]types.Article, error) {
 	return v, nil
 }
 
-func (i innerStorage) Write(article types.Article) error {
+func (i *innerStorage) Count(_ context.Context, f storage.ArticleFilter) (int, error) {
+	i.mx.RLock()
+	defer i.mx.RUnlock()
+	count := 0
+	for _, a := range i.articles {
+		if f.Matches(a) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (i *innerStorage) ListPage(_ context.Context, q storage.PageQuery) (storage.ArticlePage, error) {
+	cursor, err := q.Validate()
+	if err != nil {
+		return storage.ArticlePage{}, err
+	}
+	i.mx.RLock()
+	defer i.mx.RUnlock()
+	match := func(id types.ArticleId) bool {
+		return q.Filter.Matches(i.articles[id])
+	}
+	ids, hasMore := i.sortIndexes[q.Sort.Field()].page(cursor, q.Sort.Descending(), q.Limit, match)
+	page := storage.ArticlePage{
+		Articles: make([]types.Article, 0, len(ids)),
+		HasMore:  hasMore,
+		Sort:     q.Sort,
+	}
+	for _, id := range ids {
+		page.Articles = append(page.Articles, i.articles[id])
+	}
+	if page.HasMore {
+		page.NextCursor = storage.NewCursor(q.Sort, page.Articles[len(page.Articles)-1]).Encode()
+	}
+	return page, nil
+}
+
+func (i *innerStorage) Write(_ context.Context, article types.Article) error {
 	i.mx.Lock()
 	defer i.mx.Unlock()
 	id := article.Id
-	if !article.HasDetails {
-		_, found := i.articles[id]
+	old, found := i.articles[id]
+	if found && (!article.HasDetails || old.HasDetails || old.RetractedAt != nil) {
+		// only article without details can be replaced and only with the one having them, tombstones are kept
+		return fmt.Errorf("%w with id: %v", storage.ArticleAlreadyExists, id)
+	}
+	if found {
+		article.Pinned = article.Pinned || old.Pinned
+	}
+	i.put(old, found, article)
+	return nil
+}
+
+func (i *innerStorage) Replace(_ context.Context, article types.Article) error {
+	i.mx.Lock()
+	defer i.mx.Unlock()
+	old, found := i.articles[article.Id]
+	if !found {
+		return fmt.Errorf("%w with id: %v", storage.ArticleNotFound, article.Id)
+	}
+	replacedAt := time.Now().UTC()
+	revisions := i.revisions[article.Id]
+	i.revisions[article.Id] = append(revisions, types.ArticleRevision{
+		Number:     len(revisions) + 1,
+		ReplacedAt: &replacedAt,
+		Article:    old,
+	})
+	article.Pinned = old.Pinned
+	i.put(old, found, article)
+	return nil
+}
+
+// put saves article in place of old one when it was found and updates indexes, caller has to hold the lock
+func (i *innerStorage) put(old types.Article, found bool, article types.Article) {
+	if !article.HasDetails && article.RetractedAt == nil {
+		i.newsIdsForDetails[teamNewsId{article.TeamId, article.NewsId}] = struct{}{}
+	} else {
+		delete(i.newsIdsForDetails, teamNewsId{article.TeamId, article.NewsId})
+	}
+	for _, index := range i.sortIndexes {
 		if found {
-			return fmt.Errorf("%w with id: %v", storage.ArticleAlreadyExists, id)
+			index.remove(old)
 		}
-		i.newsIdsForDetails[article.NewsId] = struct{}{}
-	} else {
-		delete(i.newsIdsForDetails, article.NewsId)
+		index.insert(article)
+	}
+	if found {
+		i.searchIndex.remove(old)
+	}
+	i.searchIndex.insert(article)
+	i.articles[article.Id] = article
+}
+
+func (i *innerStorage) ListRevisions(_ context.Context, id types.ArticleId) ([]types.ArticleRevision, error) {
+	i.mx.RLock()
+	defer i.mx.RUnlock()
+	if _, found := i.articles[id]; !found {
+		return nil, fmt.Errorf("%w with id: %v", storage.ArticleNotFound, id)
+	}
+	// copy, so appending does not change returned slice
+	return append([]types.ArticleRevision(nil), i.revisions[id]...), nil
+}
+
+func (i *innerStorage) SetPinned(_ context.Context, id types.ArticleId, pinned bool) error {
+	i.mx.Lock()
+	defer i.mx.Unlock()
+	article, found := i.articles[id]
+	if !found {
+		return fmt.Errorf("%w with id: %v", storage.ArticleNotFound, id)
+	}
+	// pinned is not part of any index, so they are left as they are
+	article.Pinned = pinned
+	i.articles[id] = article
+	return nil
+}
+
+func (i *innerStorage) SetUpdatedAt(_ context.Context, id types.ArticleId, updatedAt time.Time) error {
+	i.mx.Lock()
+	defer i.mx.Unlock()
+	article, found := i.articles[id]
+	if !found {
+		return fmt.Errorf("%w with id: %v", storage.ArticleNotFound, id)
 	}
+	// updatedAt is not part of any index either
+	article.UpdatedAt = updatedAt
 	i.articles[id] = article
 	return nil
 }
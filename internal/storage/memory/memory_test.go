@@ -0,0 +1,182 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"github.com/adamdyszy/sportsnews/storage"
+	"github.com/adamdyszy/sportsnews/storage/storagetest"
+	"github.com/adamdyszy/sportsnews/types"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func writeArticles(t *testing.T, s storage.ArticleStorage, amount int) {
+	for n := 0; n < amount; n++ {
+		a := types.Article{
+			ArticleKey: types.ArticleKey{
+				TeamId:    "t94",
+				NewsId:    fmt.Sprint(n),
+				Published: time.Date(2023, 2, 17, 14, n, 0, 0, time.UTC),
+			},
+			Title: fmt.Sprintf("Title %v", n),
+		}
+		err := a.SetGeneratedId()
+		assert.NoError(t, err)
+		assert.NoError(t, s.Write(context.Background(), a))
+	}
+}
+
+func listAllPages(t *testing.T, s storage.ArticleStorage, query storage.PageQuery) ([]types.Article, int) {
+	var got []types.Article
+	pages := 0
+	for {
+		page, err := s.ListPage(context.Background(), query)
+		assert.NoError(t, err)
+		pages++
+		got = append(got, page.Articles...)
+		if !page.HasMore {
+			assert.Empty(t, page.NextCursor)
+			return got, pages
+		}
+		assert.Len(t, page.Articles, query.Limit)
+		query.Cursor = page.NextCursor
+	}
+}
+
+func TestListPage(t *testing.T) {
+	s := NewMemStorage()
+	writeArticles(t, s, 7)
+
+	got, pages := listAllPages(t, s, storage.PageQuery{Limit: 3})
+	assert.Equal(t, 3, pages)
+	assert.Len(t, got, 7)
+	for n := 1; n < len(got); n++ {
+		assert.True(t, got[n-1].Published.After(got[n].Published), "default order should be newest first")
+	}
+}
+
+func TestListPageSort(t *testing.T) {
+	s := NewMemStorage()
+	writeArticles(t, s, 5)
+	// same title for every article, so order falls back to ids
+	for _, a := range []types.Article{
+		{ArticleKey: types.ArticleKey{TeamId: "t1", NewsId: "a"}, Title: "Same", HasDetails: true},
+		{ArticleKey: types.ArticleKey{TeamId: "t2", NewsId: "b"}, Title: "Same", HasDetails: true},
+		{ArticleKey: types.ArticleKey{TeamId: "t3", NewsId: "c"}, Title: "Same", HasDetails: true},
+	} {
+		assert.NoError(t, a.SetGeneratedId())
+		assert.NoError(t, s.Write(context.Background(), a))
+	}
+
+	for _, sort := range []storage.SortOrder{"published", "-published", "title", "-title", "teamId", "-teamId"} {
+		got, _ := listAllPages(t, s, storage.PageQuery{Limit: 2, Sort: sort})
+		assert.Len(t, got, 8, sort)
+		for n := 1; n < len(got); n++ {
+			prev := storage.NewCursor(sort, got[n-1])
+			next := storage.NewCursor(sort, got[n])
+			if sort.Descending() {
+				prev, next = next, prev
+			}
+			assert.True(t, prev.Key < next.Key || prev.Key == next.Key && prev.LastId < next.LastId,
+				"sort %v is not kept at position %v", sort, n)
+		}
+	}
+
+	page, err := s.ListPage(context.Background(), storage.PageQuery{Limit: 1, Sort: "title"})
+	assert.NoError(t, err)
+	_, err = s.ListPage(context.Background(), storage.PageQuery{Limit: 1, Sort: "teamId", Cursor: page.NextCursor})
+	assert.ErrorIs(t, err, storage.InvalidPageQuery)
+}
+
+func TestListPageInvalidQuery(t *testing.T) {
+	s := NewMemStorage()
+	_, err := s.ListPage(context.Background(), storage.PageQuery{Limit: 0})
+	assert.ErrorIs(t, err, storage.InvalidPageQuery)
+	_, err = s.ListPage(context.Background(), storage.PageQuery{Limit: 1, Cursor: "not a cursor"})
+	assert.ErrorIs(t, err, storage.InvalidPageQuery)
+	_, err = s.ListPage(context.Background(), storage.PageQuery{Limit: 1, Sort: "content"})
+	assert.ErrorIs(t, err, storage.InvalidPageQuery)
+}
+
+func TestListPageFilter(t *testing.T) {
+	s := NewMemStorage()
+	writeArticles(t, s, 6)
+	academy := types.Article{
+		ArticleKey: types.ArticleKey{
+			TeamId:    "t1",
+			NewsId:    "academy",
+			Published: time.Date(2023, 2, 17, 14, 3, 30, 0, time.UTC),
+		},
+		OptaMatchId: "g2322054",
+		Type:        []string{"Academy", "Interviews"},
+		HasDetails:  true,
+	}
+	assert.NoError(t, academy.SetGeneratedId())
+	assert.NoError(t, s.Write(context.Background(), academy))
+
+	hasDetails := true
+	withoutDetails := false
+	for _, tc := range []struct {
+		filter storage.ArticleFilter
+		amount int
+	}{
+		{storage.ArticleFilter{}, 7},
+		{storage.ArticleFilter{Type: "Academy"}, 1},
+		{storage.ArticleFilter{Type: "Academy", TeamId: "t94"}, 0},
+		{storage.ArticleFilter{TeamId: "t94"}, 6},
+		{storage.ArticleFilter{OptaMatchId: "g2322054"}, 1},
+		{storage.ArticleFilter{HasDetails: &hasDetails}, 1},
+		{storage.ArticleFilter{HasDetails: &withoutDetails}, 6},
+		{storage.ArticleFilter{
+			PublishedAfter:  time.Date(2023, 2, 17, 14, 1, 0, 0, time.UTC),
+			PublishedBefore: time.Date(2023, 2, 17, 14, 4, 0, 0, time.UTC),
+		}, 3},
+	} {
+		got, _ := listAllPages(t, s, storage.PageQuery{Limit: 2, Filter: tc.filter})
+		assert.Len(t, got, tc.amount, tc.filter)
+		for _, a := range got {
+			assert.True(t, tc.filter.Matches(a))
+		}
+	}
+}
+
+func TestSearch(t *testing.T) {
+	s := NewMemStorage()
+	writeArticles(t, s, 3)
+	for _, a := range []types.Article{
+		{ArticleKey: types.ArticleKey{TeamId: "t94", NewsId: "title", Published: time.Date(2023, 3, 1, 0, 0, 0, 0, time.UTC)}, Title: "Rosenior: We Go Again"},
+		{ArticleKey: types.ArticleKey{TeamId: "t94", NewsId: "content", Published: time.Date(2023, 3, 2, 0, 0, 0, 0, time.UTC)}, Title: "Match report",
+			Content: "<p>Liam <b>Rosenior</b> praised the players.</p>", HasDetails: true},
+		{ArticleKey: types.ArticleKey{TeamId: "t94", NewsId: "teaser", Published: time.Date(2023, 3, 3, 0, 0, 0, 0, time.UTC)}, Title: "Preview",
+			Teaser: "Head coach rosenior speaks"},
+	} {
+		assert.NoError(t, a.SetGeneratedId())
+		assert.NoError(t, s.Write(context.Background(), a))
+	}
+
+	results, err := s.Search(context.Background(), storage.SearchQuery{Text: "ROSENIOR", Limit: 10})
+	assert.NoError(t, err)
+	assert.Len(t, results, 3)
+	assert.Equal(t, "title", results[0].Article.NewsId)
+	assert.Equal(t, "teaser", results[1].Article.NewsId)
+	assert.Equal(t, "content", results[2].Article.NewsId)
+
+	results, err = s.Search(context.Background(), storage.SearchQuery{Text: "rosenior", Limit: 1})
+	assert.NoError(t, err)
+	assert.Len(t, results, 1)
+
+	// html tags are not searchable
+	results, err = s.Search(context.Background(), storage.SearchQuery{Text: "p b", Limit: 10})
+	assert.NoError(t, err)
+	assert.Empty(t, results)
+
+	_, err = s.Search(context.Background(), storage.SearchQuery{Text: " ", Limit: 10})
+	assert.ErrorIs(t, err, storage.InvalidPageQuery)
+}
+
+func TestConformance(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storage.ArticleStorage {
+		return NewMemStorage()
+	})
+}
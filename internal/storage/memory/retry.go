@@ -0,0 +1,76 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"github.com/adamdyszy/sportsnews/storage"
+	"github.com/adamdyszy/sportsnews/types"
+	"sort"
+	"sync"
+)
+
+// retryKey identifies retry of news id of the team
+type retryKey struct {
+	teamId string
+	newsId string
+}
+
+type retryStorage struct {
+	retries map[retryKey]types.DetailsRetry
+	mx      *sync.RWMutex
+}
+
+// NewMemRetryStorage creates retry storage, retries are lost on restart so failed news ids are attempted right away
+func NewMemRetryStorage() storage.RetryStorage {
+	return &retryStorage{
+		retries: make(map[retryKey]types.DetailsRetry),
+		mx:      &sync.RWMutex{},
+	}
+}
+
+func (r *retryStorage) GetRetry(_ context.Context, teamId string, newsId string) (types.DetailsRetry, error) {
+	r.mx.RLock()
+	defer r.mx.RUnlock()
+	retry, found := r.retries[retryKey{teamId: teamId, newsId: newsId}]
+	if !found {
+		return types.DetailsRetry{}, fmt.Errorf("%w with teamId: %v and newsId: %v", storage.RetryNotFound, teamId, newsId)
+	}
+	return retry, nil
+}
+
+func (r *retryStorage) SaveRetry(_ context.Context, retry types.DetailsRetry) error {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	r.retries[retryKey{teamId: retry.TeamId, newsId: retry.NewsId}] = retry
+	return nil
+}
+
+func (r *retryStorage) DeleteRetry(_ context.Context, teamId string, newsId string) error {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	key := retryKey{teamId: teamId, newsId: newsId}
+	if _, found := r.retries[key]; !found {
+		return fmt.Errorf("%w with teamId: %v and newsId: %v", storage.RetryNotFound, teamId, newsId)
+	}
+	delete(r.retries, key)
+	return nil
+}
+
+func (r *retryStorage) ListRetries(_ context.Context, teamId string) ([]types.DetailsRetry, error) {
+	r.mx.RLock()
+	defer r.mx.RUnlock()
+	v := make([]types.DetailsRetry, 0)
+	for _, retry := range r.retries {
+		if teamId == "" || retry.TeamId == teamId {
+			v = append(v, retry)
+		}
+	}
+	sort.Slice(v, func(a, b int) bool {
+		return v[a].LastAttemptAt.Before(v[b].LastAttemptAt)
+	})
+	return v, nil
+}
+
+func (r *retryStorage) Disconnect() error {
+	return nil
+}
@@ -0,0 +1,116 @@
+package memory
+
+import (
+	"context"
+	"github.com/adamdyszy/sportsnews/storage"
+	"github.com/adamdyszy/sportsnews/types"
+	"html"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+var htmlTagRegexp = regexp.MustCompile(`<[^>]*>`)
+
+/*
+searchIndex is inverted index from words to weighted amount of their occurrences in articles.
+
+Weights of fields are taken from storage.SearchWeight constants,
+so ranking is similar to the text index used by mongo storage.
+*/
+type searchIndex struct {
+	terms map[string]map[types.ArticleId]float64
+}
+
+func newSearchIndex() *searchIndex {
+	return &searchIndex{terms: make(map[string]map[types.ArticleId]float64)}
+}
+
+// tokenize splits text into lowercase words, html tags are skipped
+func tokenize(text string) []string {
+	text = html.UnescapeString(htmlTagRegexp.ReplaceAllString(text, " "))
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+func articleTermScores(a types.Article) map[string]float64 {
+	scores := make(map[string]float64)
+	for _, field := range []struct {
+		text   string
+		weight float64
+	}{
+		{a.Title, storage.SearchWeightTitle},
+		{a.Teaser, storage.SearchWeightTeaser},
+		{a.Content, storage.SearchWeightContent},
+	} {
+		for _, term := range tokenize(field.text) {
+			scores[term] += field.weight
+		}
+	}
+	return scores
+}
+
+func (s *searchIndex) insert(a types.Article) {
+	for term, score := range articleTermScores(a) {
+		ids, found := s.terms[term]
+		if !found {
+			ids = make(map[types.ArticleId]float64)
+			s.terms[term] = ids
+		}
+		ids[a.Id] = score
+	}
+}
+
+func (s *searchIndex) remove(a types.Article) {
+	for term := range articleTermScores(a) {
+		delete(s.terms[term], a.Id)
+		if len(s.terms[term]) == 0 {
+			delete(s.terms, term)
+		}
+	}
+}
+
+// search returns scores of articles having any of the words from text
+func (s *searchIndex) search(text string) map[types.ArticleId]float64 {
+	scores := make(map[types.ArticleId]float64)
+	seen := make(map[string]struct{})
+	for _, term := range tokenize(text) {
+		if _, found := seen[term]; found {
+			continue
+		}
+		seen[term] = struct{}{}
+		for id, score := range s.terms[term] {
+			scores[id] += score
+		}
+	}
+	return scores
+}
+
+func (i *innerStorage) Search(_ context.Context, q storage.SearchQuery) ([]storage.SearchResult, error) {
+	err := q.Validate()
+	if err != nil {
+		return nil, err
+	}
+	i.mx.RLock()
+	defer i.mx.RUnlock()
+	scores := i.searchIndex.search(q.Text)
+	results := make([]storage.SearchResult, 0, len(scores))
+	for id, score := range scores {
+		if i.articles[id].RetractedAt != nil {
+			continue
+		}
+		results = append(results, storage.SearchResult{Article: i.articles[id], Score: score})
+	}
+	sort.Slice(results, func(a, b int) bool {
+		if results[a].Score != results[b].Score {
+			return results[a].Score > results[b].Score
+		}
+		return results[a].Article.Published.After(results[b].Article.Published)
+	})
+	if len(results) > q.Limit {
+		results = results[:q.Limit]
+	}
+	return results, nil
+}
@@ -0,0 +1,104 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"github.com/adamdyszy/sportsnews/storage"
+	"github.com/adamdyszy/sportsnews/types"
+	"sort"
+	"sync"
+)
+
+type webhookStorage struct {
+	subscriptions map[string]types.WebhookSubscription
+	// deliveries are kept oldest first for every subscription
+	deliveries      map[string][]types.WebhookDelivery
+	deliveryLogSize int
+	mx              *sync.RWMutex
+}
+
+// NewMemWebhookStorage creates webhook storage keeping deliveryLogSize last deliveries of every subscription
+func NewMemWebhookStorage(deliveryLogSize int) storage.WebhookStorage {
+	return &webhookStorage{
+		subscriptions:   make(map[string]types.WebhookSubscription),
+		deliveries:      make(map[string][]types.WebhookDelivery),
+		deliveryLogSize: deliveryLogSize,
+		mx:              &sync.RWMutex{},
+	}
+}
+
+func (w *webhookStorage) CreateSubscription(_ context.Context, s types.WebhookSubscription) error {
+	w.mx.Lock()
+	defer w.mx.Unlock()
+	if _, found := w.subscriptions[s.Id]; found {
+		return fmt.Errorf("%w with id: %v", storage.SubscriptionAlreadyExists, s.Id)
+	}
+	w.subscriptions[s.Id] = s
+	return nil
+}
+
+func (w *webhookStorage) GetSubscription(_ context.Context, id string) (types.WebhookSubscription, error) {
+	w.mx.RLock()
+	defer w.mx.RUnlock()
+	s, found := w.subscriptions[id]
+	if !found {
+		return types.WebhookSubscription{}, fmt.Errorf("%w with id: %v", storage.SubscriptionNotFound, id)
+	}
+	return s, nil
+}
+
+func (w *webhookStorage) ListSubscriptions(_ context.Context) ([]types.WebhookSubscription, error) {
+	w.mx.RLock()
+	defer w.mx.RUnlock()
+	v := make([]types.WebhookSubscription, 0, len(w.subscriptions))
+	for _, s := range w.subscriptions {
+		v = append(v, s)
+	}
+	sort.Slice(v, func(a, b int) bool {
+		return v[a].CreatedAt.Before(v[b].CreatedAt)
+	})
+	return v, nil
+}
+
+func (w *webhookStorage) DeleteSubscription(_ context.Context, id string) error {
+	w.mx.Lock()
+	defer w.mx.Unlock()
+	if _, found := w.subscriptions[id]; !found {
+		return fmt.Errorf("%w with id: %v", storage.SubscriptionNotFound, id)
+	}
+	delete(w.subscriptions, id)
+	delete(w.deliveries, id)
+	return nil
+}
+
+func (w *webhookStorage) AddDelivery(_ context.Context, d types.WebhookDelivery) error {
+	w.mx.Lock()
+	defer w.mx.Unlock()
+	if _, found := w.subscriptions[d.SubscriptionId]; !found {
+		return fmt.Errorf("%w with id: %v", storage.SubscriptionNotFound, d.SubscriptionId)
+	}
+	log := append(w.deliveries[d.SubscriptionId], d)
+	if w.deliveryLogSize > 0 && len(log) > w.deliveryLogSize {
+		log = log[len(log)-w.deliveryLogSize:]
+	}
+	w.deliveries[d.SubscriptionId] = log
+	return nil
+}
+
+func (w *webhookStorage) ListDeliveries(_ context.Context, subscriptionId string, limit int) ([]types.WebhookDelivery, error) {
+	w.mx.RLock()
+	defer w.mx.RUnlock()
+	if _, found := w.subscriptions[subscriptionId]; !found {
+		return nil, fmt.Errorf("%w with id: %v", storage.SubscriptionNotFound, subscriptionId)
+	}
+	log := w.deliveries[subscriptionId]
+	v := make([]types.WebhookDelivery, 0, len(log))
+	for n := len(log) - 1; n >= 0 && (limit <= 0 || len(v) < limit); n-- {
+		v = append(v, log[n])
+	}
+	return v, nil
+}
+
+func (w *webhookStorage) Disconnect() error {
+	return nil
+}
@@ -18,11 +18,15 @@ type articleBson struct {
 	ImageURL    string    `bson:"imageUrl,omitempty"`
 	OptaMatchID string    `bson:"optaMatchId,omitempty"`
 	Teaser      string    `bson:"teaser,omitempty"`
-	Title       string    `bson:"title,omitempty"`
-	URL         string    `bson:"url,omitempty"`
-	VideoURL    string    `bson:"videoUrl,omitempty"`
-	Type        []string  `bson:"type,omitempty"`
-	HasDetails  bool      `bson:"hasDetails"`
+	// Title is kept even when empty, articles without it would not be compared with titles when paging
+	Title       string     `bson:"title"`
+	URL         string     `bson:"url,omitempty"`
+	VideoURL    string     `bson:"videoUrl,omitempty"`
+	Type        []string   `bson:"type,omitempty"`
+	HasDetails  bool       `bson:"hasDetails"`
+	UpdatedAt   time.Time  `bson:"updatedAt"`
+	RetractedAt *time.Time `bson:"retractedAt,omitempty"`
+	Pinned      bool       `bson:"pinned,omitempty"`
 }
 
 func fromArticle(a types.Article) articleBson {
@@ -41,6 +45,9 @@ func fromArticle(a types.Article) articleBson {
 		URL:         a.URL,
 		VideoURL:    a.VideoURL,
 		HasDetails:  a.HasDetails,
+		UpdatedAt:   a.UpdatedAt,
+		RetractedAt: a.RetractedAt,
+		Pinned:      a.Pinned,
 	}
 }
 
@@ -62,5 +69,25 @@ func (a *articleBson) ToArticle() types.Article {
 		URL:         a.URL,
 		VideoURL:    a.VideoURL,
 		HasDetails:  a.HasDetails,
+		UpdatedAt:   a.UpdatedAt,
+		RetractedAt: a.RetractedAt,
+		Pinned:      a.Pinned,
+	}
+}
+
+// revisionBson is earlier version of the article kept in revisions collection
+type revisionBson struct {
+	ArticleId  string      `bson:"articleId"`
+	Number     int         `bson:"number"`
+	ReplacedAt time.Time   `bson:"replacedAt"`
+	Article    articleBson `bson:"article"`
+}
+
+func (r *revisionBson) ToRevision() types.ArticleRevision {
+	replacedAt := r.ReplacedAt
+	return types.ArticleRevision{
+		Number:     r.Number,
+		ReplacedAt: &replacedAt,
+		Article:    r.Article.ToArticle(),
 	}
 }
@@ -0,0 +1,74 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/adamdyszy/sportsnews/storage"
+	"github.com/adamdyszy/sportsnews/types"
+	"github.com/spf13/viper"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"time"
+)
+
+type backfillBson struct {
+	TeamId     string    `bson:"teamId"`
+	NextNewsId int       `bson:"nextNewsId"`
+	Saved      int       `bson:"saved"`
+	Skipped    int       `bson:"skipped"`
+	Misses     int       `bson:"misses"`
+	Done       bool      `bson:"done"`
+	StopReason string    `bson:"stopReason,omitempty"`
+	UpdatedAt  time.Time `bson:"updatedAt"`
+}
+
+type backfillStorage struct {
+	client       *mongo.Client
+	backfillColl *mongo.Collection
+	timeout      time.Duration
+}
+
+// NewMongoBackfillStorage creates backfill storage keeping progress of every team in backfillColl,
+// it uses client of mongo article storage s, its indexes are created by migrations
+func NewMongoBackfillStorage(s storage.ArticleStorage, v *viper.Viper) (storage.BackfillStorage, error) {
+	m, ok := s.(*mongoStorage)
+	if !ok {
+		return nil, errors.New("mongo backfill storage needs mongo article storage")
+	}
+	return &backfillStorage{
+		client:       m.client,
+		backfillColl: m.client.Database(m.database).Collection(v.GetString("backfillColl")),
+		timeout:      m.timeout,
+	}, nil
+}
+
+// Disconnect does nothing, client is shared with article storage which disconnects it
+func (b *backfillStorage) Disconnect() error {
+	return nil
+}
+
+func (b *backfillStorage) GetBackfillProgress(ctx context.Context, teamId string) (types.BackfillProgress, error) {
+	ctx, cancel := context.WithTimeout(ctx, b.timeout)
+	defer cancel()
+	var found backfillBson
+	err := b.backfillColl.FindOne(ctx, bson.M{"teamId": teamId}).Decode(&found)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return types.BackfillProgress{}, fmt.Errorf("%w with teamId: %v", storage.BackfillProgressNotFound, teamId)
+		}
+		return types.BackfillProgress{}, err
+	}
+	return types.BackfillProgress(found), nil
+}
+
+func (b *backfillStorage) SaveBackfillProgress(ctx context.Context, progress types.BackfillProgress) error {
+	ctx, cancel := context.WithTimeout(ctx, b.timeout)
+	defer cancel()
+	_, err := b.backfillColl.ReplaceOne(ctx, bson.M{"teamId": progress.TeamId}, backfillBson(progress), options.Replace().SetUpsert(true))
+	if err != nil {
+		return fmt.Errorf("error saving backfill progress: %w", err)
+	}
+	return nil
+}
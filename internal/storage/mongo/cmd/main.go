@@ -6,6 +6,7 @@ import (
 	"github.com/adamdyszy/sportsnews/internal/poller"
 	storage "github.com/adamdyszy/sportsnews/internal/storage/mongo"
 	"github.com/adamdyszy/sportsnews/types"
+	"github.com/go-logr/logr"
 	"github.com/spf13/viper"
 	"reflect"
 )
@@ -17,7 +18,8 @@ func main() {
 	if err != nil {
 		panic(err)
 	}
-	s, err := storage.NewMongoStorage(v.Sub("db"), context.Background())
+	ctx := context.Background()
+	s, err := storage.NewMongoStorage(v.Sub("db"), ctx)
 	if err != nil {
 		panic(err)
 	}
@@ -33,22 +35,22 @@ func main() {
 		PublishDate:   "2023-02-17 14:20:33",
 		Taxonomies:    "T1,T2",
 		Title:         "Title",
-	}, "t94", false)
+	}, "t94", false, logr.Discard())
 
 	// delete if was already present
-	err = s.Delete(a.Id)
+	err = s.Delete(ctx, a.Id)
 	if err != nil {
 		panic(err)
 	}
 
 	// write without details
-	err = s.Write(a)
+	err = s.Write(ctx, a)
 	if err != nil {
 		panic(err)
 	}
 
 	// check if not detailed is available in list
-	withoutDetailsIDs, err := s.GetNewsWithoutDetailsIDs()
+	withoutDetailsIDs, err := s.GetNewsWithoutDetailsIDs(ctx, "")
 	if err != nil {
 		panic(err)
 	}
@@ -66,13 +68,13 @@ func main() {
 	// do override with details
 	a.Content = "we now have details!"
 	a.HasDetails = true
-	err = s.Write(a)
+	err = s.Write(ctx, a)
 	if err != nil {
 		panic(err)
 	}
 
 	// check override worked
-	aFromDB, err := s.Get(a.Id)
+	aFromDB, err := s.Get(ctx, a.Id)
 	if err != nil {
 		panic(err)
 	}
@@ -82,7 +84,7 @@ func main() {
 	}
 
 	// check list
-	list, err := s.List()
+	list, err := s.List(ctx)
 	if err != nil {
 		panic(err)
 	}
@@ -107,7 +109,7 @@ func main() {
 	}
 
 	// check if after override we no longer have it without ids
-	withoutDetailsIDs, err = s.GetNewsWithoutDetailsIDs()
+	withoutDetailsIDs, err = s.GetNewsWithoutDetailsIDs(ctx, "")
 	if err != nil {
 		panic(err)
 	}
@@ -0,0 +1,295 @@
+package mongo
+
+import (
+	"context"
+	"fmt"
+	"github.com/adamdyszy/sportsnews/storage"
+	"github.com/spf13/viper"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"sort"
+	"time"
+)
+
+// schema is what migrations can change
+type schema struct {
+	db         *mongo.Database
+	articles   *mongo.Collection
+	revisions  *mongo.Collection
+	webhooks   *mongo.Collection
+	deliveries *mongo.Collection
+	backfill   *mongo.Collection
+	retries    *mongo.Collection
+}
+
+/*
+Migration is single change of the database.
+
+Applied migrations are recorded in the database, so each of them runs only once.
+Replicas starting at the same time can run the same migration, so it has to be idempotent.
+New fields of articleBson that need value in already stored documents are added as migrations too,
+for example UpdateMany setting the value where the field does not exist.
+Released migrations must not be changed, add new one with next version instead.
+*/
+type Migration struct {
+	Version     int
+	Description string
+	Up          func(ctx context.Context, s schema) error
+}
+
+// appliedMigration is record of applied migration
+type appliedMigration struct {
+	Version     int       `bson:"version"`
+	Description string    `bson:"description"`
+	AppliedAt   time.Time `bson:"appliedAt"`
+}
+
+// createIndexes returns migration function creating indexes of articles collection
+func createIndexes(models ...mongo.IndexModel) func(ctx context.Context, s schema) error {
+	return func(ctx context.Context, s schema) error {
+		_, err := s.articles.Indexes().CreateMany(ctx, models)
+		return err
+	}
+}
+
+// createIndexesOf returns migration function creating indexes of collection chosen by coll
+func createIndexesOf(coll func(s schema) *mongo.Collection, models ...mongo.IndexModel) func(ctx context.Context, s schema) error {
+	return func(ctx context.Context, s schema) error {
+		_, err := coll(s).Indexes().CreateMany(ctx, models)
+		return err
+	}
+}
+
+// removeDuplicatedIds keeps single article of every id, the one with details and the latest update is kept
+func removeDuplicatedIds(ctx context.Context, s schema) error {
+	cur, err := s.articles.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$sort", Value: bson.D{{Key: "hasDetails", Value: -1}, {Key: "updatedAt", Value: -1}, {Key: "_id", Value: 1}}}},
+		{{Key: "$group", Value: bson.D{
+			{Key: "_id", Value: "$id"},
+			{Key: "documents", Value: bson.D{{Key: "$push", Value: "$_id"}}},
+		}}},
+		{{Key: "$match", Value: bson.D{{Key: "documents.1", Value: bson.D{{Key: "$exists", Value: true}}}}}},
+	}, options.Aggregate().SetAllowDiskUse(true))
+	if err != nil {
+		return fmt.Errorf("error looking for duplicated ids: %w", err)
+	}
+	var duplicates []struct {
+		Documents []interface{} `bson:"documents"`
+	}
+	err = cur.All(ctx, &duplicates)
+	if err != nil {
+		return fmt.Errorf("error decoding duplicated ids: %w", err)
+	}
+	for _, d := range duplicates {
+		// the first document is the one to keep
+		_, err = s.articles.DeleteMany(ctx, bson.M{"_id": bson.M{"$in": d.Documents[1:]}})
+		if err != nil {
+			return fmt.Errorf("error removing duplicated ids: %w", err)
+		}
+	}
+	return nil
+}
+
+// migrations are all migrations ordered by version
+var migrations = []Migration{
+	{
+		Version:     1,
+		Description: "unique index on id",
+		// Unique id makes Write atomic, articles duplicated by Write before it are removed first, so it can be created
+		Up: func(ctx context.Context, s schema) error {
+			err := removeDuplicatedIds(ctx, s)
+			if err != nil {
+				return err
+			}
+			return createIndexes(mongo.IndexModel{
+				Keys:    bson.D{{Key: "id", Value: 1}},
+				Options: options.Index().SetName("articlesId").SetUnique(true),
+			})(ctx, s)
+		},
+	},
+	{
+		Version:     2,
+		Description: "text index used by search",
+		Up: createIndexes(mongo.IndexModel{
+			Keys: bson.D{{Key: "title", Value: "text"}, {Key: "teaser", Value: "text"}, {Key: "content", Value: "text"}},
+			Options: options.Index().
+				SetName("articlesTextSearch").
+				// no stemming and stop words, so results are comparable with memory storage
+				SetDefaultLanguage("none").
+				SetWeights(bson.D{
+					{Key: "title", Value: storage.SearchWeightTitle},
+					{Key: "teaser", Value: storage.SearchWeightTeaser},
+					{Key: "content", Value: storage.SearchWeightContent},
+				}),
+		}),
+	},
+	{
+		Version:     3,
+		Description: "indexes on hasDetails, teamId with published and newsId",
+		Up: createIndexes(
+			mongo.IndexModel{
+				Keys:    bson.D{{Key: "hasDetails", Value: 1}},
+				Options: options.Index().SetName("articlesHasDetails"),
+			},
+			mongo.IndexModel{
+				Keys:    bson.D{{Key: "teamId", Value: 1}, {Key: "published", Value: -1}},
+				Options: options.Index().SetName("articlesTeamIdPublished"),
+			},
+			mongo.IndexModel{
+				Keys:    bson.D{{Key: "newsId", Value: 1}},
+				Options: options.Index().SetName("articlesNewsId"),
+			},
+		),
+	},
+	{
+		Version:     4,
+		Description: "unique index on articleId with number of revisions",
+		Up: func(ctx context.Context, s schema) error {
+			_, err := s.revisions.Indexes().CreateOne(ctx, mongo.IndexModel{
+				Keys:    bson.D{{Key: "articleId", Value: 1}, {Key: "number", Value: 1}},
+				Options: options.Index().SetName("revisionsArticleIdNumber").SetUnique(true),
+			})
+			return err
+		},
+	},
+	{
+		Version:     5,
+		Description: "unique index on id of webhooks and index on subscriptionId with time of deliveries",
+		// default names are the ones webhook storage used when it created these indexes itself, so they are not created twice
+		Up: func(ctx context.Context, s schema) error {
+			err := createIndexesOf(func(s schema) *mongo.Collection { return s.webhooks }, mongo.IndexModel{
+				Keys:    bson.D{{Key: "id", Value: 1}},
+				Options: options.Index().SetUnique(true),
+			})(ctx, s)
+			if err != nil {
+				return err
+			}
+			return createIndexesOf(func(s schema) *mongo.Collection { return s.deliveries }, mongo.IndexModel{
+				Keys: bson.D{{Key: "subscriptionId", Value: 1}, {Key: "time", Value: -1}},
+			})(ctx, s)
+		},
+	},
+	{
+		Version:     6,
+		Description: "unique index on teamId of backfill progress",
+		Up: createIndexesOf(func(s schema) *mongo.Collection { return s.backfill }, mongo.IndexModel{
+			Keys:    bson.D{{Key: "teamId", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		}),
+	},
+	{
+		Version:     7,
+		Description: "unique index on teamId with newsId of details retries",
+		Up: createIndexesOf(func(s schema) *mongo.Collection { return s.retries }, mongo.IndexModel{
+			Keys:    bson.D{{Key: "teamId", Value: 1}, {Key: "newsId", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		}),
+	},
+	{
+		Version:     8,
+		Description: "empty title of articles without it",
+		// comparisons used by paging match only strings, so articles without title would be skipped
+		Up: func(ctx context.Context, s schema) error {
+			_, err := s.articles.UpdateMany(ctx, bson.M{"title": bson.M{"$exists": false}}, bson.M{"$set": bson.M{"title": ""}})
+			return err
+		},
+	},
+}
+
+// migrator applies migrations to schema and records them in migrationsColl
+type migrator struct {
+	schema         schema
+	migrationsColl *mongo.Collection
+}
+
+func newMigrator(db *mongo.Database, v *viper.Viper) migrator {
+	return migrator{
+		schema: schema{
+			db:         db,
+			articles:   db.Collection(v.GetString("articlesColl")),
+			revisions:  db.Collection(v.GetString("revisionsColl")),
+			webhooks:   db.Collection(v.GetString("webhooksColl")),
+			deliveries: db.Collection(v.GetString("webhookDeliveriesColl")),
+			backfill:   db.Collection(v.GetString("backfillColl")),
+			retries:    db.Collection(v.GetString("retriesColl")),
+		},
+		migrationsColl: db.Collection(v.GetString("migrationsColl")),
+	}
+}
+
+// pending returns migrations that were not applied yet, ordered by version
+func (m migrator) pending(ctx context.Context) ([]Migration, error) {
+	cur, err := m.migrationsColl.Find(ctx, bson.M{})
+	if err != nil {
+		return nil, fmt.Errorf("error getting applied migrations: %w", err)
+	}
+	var applied []appliedMigration
+	err = cur.All(ctx, &applied)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding applied migrations: %w", err)
+	}
+	appliedVersions := make(map[int]bool, len(applied))
+	for _, a := range applied {
+		appliedVersions[a.Version] = true
+	}
+	var pending []Migration
+	for _, migration := range migrations {
+		if !appliedVersions[migration.Version] {
+			pending = append(pending, migration)
+		}
+	}
+	sort.Slice(pending, func(i, j int) bool {
+		return pending[i].Version < pending[j].Version
+	})
+	return pending, nil
+}
+
+// migrate applies pending migrations and returns them
+func (m migrator) migrate(ctx context.Context) ([]Migration, error) {
+	_, err := m.migrationsColl.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "version", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create migrations index: %w", err)
+	}
+	pending, err := m.pending(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for n, migration := range pending {
+		err = migration.Up(ctx, m.schema)
+		if err != nil {
+			return pending[:n], fmt.Errorf("migration %v (%v) failed: %w", migration.Version, migration.Description, err)
+		}
+		_, err = m.migrationsColl.InsertOne(ctx, appliedMigration{
+			Version:     migration.Version,
+			Description: migration.Description,
+			AppliedAt:   time.Now().UTC(),
+		})
+		// other replica could record the same migration in the meantime
+		if err != nil && !mongo.IsDuplicateKeyError(err) {
+			return pending[:n], fmt.Errorf("failed to record migration %v: %w", migration.Version, err)
+		}
+	}
+	return pending, nil
+}
+
+/*
+Migrate connects to mongo from config and applies migrations that were not applied yet.
+
+It returns applied migrations, even when some later migration failed.
+*/
+func Migrate(ctx context.Context, v *viper.Viper) ([]Migration, error) {
+	client, timeout, err := connect(v)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		_ = client.Disconnect(ctx)
+	}()
+	return newMigrator(client.Database(v.GetString("name")), v).migrate(ctx)
+}
@@ -10,28 +10,31 @@ import (
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+	"go.opentelemetry.io/contrib/instrumentation/go.mongodb.org/mongo-driver/mongo/otelmongo"
 	"time"
 )
 
 type mongoStorage struct {
-	client       *mongo.Client
-	database     string
-	articlesColl *mongo.Collection
-	timeout      time.Duration
+	client        *mongo.Client
+	database      string
+	articlesColl  *mongo.Collection
+	revisionsColl *mongo.Collection
+	timeout       time.Duration
 }
 
-func NewMongoStorage(v *viper.Viper, ctx context.Context) (storage.ArticleStorage, error) {
+// connect creates mongo client from config and checks the connection, timeout is taken from config too
+func connect(v *viper.Viper) (*mongo.Client, time.Duration, error) {
 	// Load the configuration values into variables.
 	dbURI := v.GetString("uri")
-	dbName := v.GetString("name")
 	user := v.GetString("user")
 	password := v.GetString("password")
-	articlesCollName := v.GetString("articlesColl")
 	timeoutSeconds := v.GetInt("timeoutSeconds")
 	timeout := time.Duration(timeoutSeconds) * time.Second
 
 	// Create a new MongoDB client.
-	clientOptions := options.Client().ApplyURI(dbURI)
+	// Every command is traced as span of the context passed to the driver
+	clientOptions := options.Client().ApplyURI(dbURI).SetMonitor(otelmongo.NewMonitor())
 	if user != "" {
 		clientOptions.SetAuth(options.Credential{
 			Username: user,
@@ -40,7 +43,7 @@ func NewMongoStorage(v *viper.Viper, ctx context.Context) (storage.ArticleStorag
 	}
 	client, err := mongo.NewClient(clientOptions)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create MongoDB client: %w", err)
+		return nil, 0, fmt.Errorf("failed to create MongoDB client: %w", err)
 	}
 
 	// Connect to the MongoDB server.
@@ -48,14 +51,26 @@ func NewMongoStorage(v *viper.Viper, ctx context.Context) (storage.ArticleStorag
 	defer cancel()
 	err = client.Connect(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("failed to connect to MongoDB server: %w", err)
+		return nil, 0, fmt.Errorf("failed to connect to MongoDB server: %w", err)
 	}
 
 	// Ping the MongoDB server to check the connection.
 	err = client.Ping(ctx, nil)
 	if err != nil {
-		return nil, fmt.Errorf("failed to ping MongoDB client: %w", err)
+		return nil, 0, fmt.Errorf("failed to ping MongoDB client: %w", err)
 	}
+	return client, timeout, nil
+}
+
+func NewMongoStorage(v *viper.Viper, ctx context.Context) (storage.ArticleStorage, error) {
+	dbName := v.GetString("name")
+	articlesCollName := v.GetString("articlesColl")
+	client, timeout, err := connect(v)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
 
 	// Check if collection exists, if not create it
 	collection := client.Database(dbName).Collection(articlesCollName)
@@ -63,11 +78,29 @@ func NewMongoStorage(v *viper.Viper, ctx context.Context) (storage.ArticleStorag
 		return nil, errors.New("error creating mongo collection")
 	}
 
+	// Apply migrations, or make sure they were applied with Migrate
+	m := newMigrator(client.Database(dbName), v)
+	if v.GetBool("migrateOnStart") {
+		_, err = m.migrate(ctx)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		pending, err := m.pending(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if len(pending) > 0 {
+			return nil, fmt.Errorf("there are %v pending migrations, apply them with migrations mode or migrateOnStart", len(pending))
+		}
+	}
+
 	return &mongoStorage{
-		client:       client,
-		database:     dbName,
-		articlesColl: collection,
-		timeout:      timeout,
+		client:        client,
+		database:      dbName,
+		articlesColl:  collection,
+		revisionsColl: m.schema.revisions,
+		timeout:       timeout,
 	}, nil
 }
 
@@ -77,18 +110,158 @@ func (m mongoStorage) Disconnect() error {
 	return m.client.Disconnect(ctx)
 }
 
-func (m mongoStorage) Delete(id types.ArticleId) error {
-	ctx, cancel := context.WithTimeout(context.Background(), m.timeout)
+func (m mongoStorage) Ping(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+	return m.client.Ping(ctx, readpref.Primary())
+}
+
+func (m mongoStorage) Delete(ctx context.Context, id types.ArticleId) error {
+	ctx, cancel := context.WithTimeout(ctx, m.timeout)
 	defer cancel()
 	_, err := m.articlesColl.DeleteMany(ctx, bson.M{"id": id})
+	if err != nil {
+		return err
+	}
+	_, err = m.revisionsColl.DeleteMany(ctx, bson.M{"articleId": id})
 	return err
 }
 
-func (m mongoStorage) GetNewsWithoutDetailsIDs() ([]string, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), m.timeout)
+// DeleteUnpinned checks pinning in the same operation that deletes article, so article pinned meanwhile is kept
+func (m mongoStorage) DeleteUnpinned(ctx context.Context, id types.ArticleId) error {
+	ctx, cancel := context.WithTimeout(ctx, m.timeout)
 	defer cancel()
+	result, err := m.articlesColl.DeleteMany(ctx, bson.M{"id": id, "pinned": bson.M{"$ne": true}})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		pinned, err := m.articlesColl.CountDocuments(ctx, bson.M{"id": id, "pinned": true})
+		if err != nil {
+			return err
+		}
+		if pinned > 0 {
+			return fmt.Errorf("%w with id: %v", storage.ArticlePinned, id)
+		}
+		return nil
+	}
+	_, err = m.revisionsColl.DeleteMany(ctx, bson.M{"articleId": id})
+	return err
+}
+
+/*
+Replace keeps stored article as revision and then replaces it.
 
-	filter := bson.M{"hasDetails": false}
+Revision is numbered by count of earlier revisions, unique index on articleId with number
+makes concurrent replacing of the same article fail instead of losing revision.
+*/
+func (m mongoStorage) Replace(ctx context.Context, article types.Article) error {
+	ctx, cancel := context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+	var old articleBson
+	err := m.articlesColl.FindOne(ctx, bson.M{"id": article.Id}).Decode(&old)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return fmt.Errorf("%w with id: %v", storage.ArticleNotFound, article.Id)
+		}
+		return fmt.Errorf("%w with id: %v: %v", storage.ArticleWriteFailed, article.Id, err)
+	}
+	count, err := m.revisionsColl.CountDocuments(ctx, bson.M{"articleId": article.Id})
+	if err != nil {
+		return fmt.Errorf("%w with id: %v: %v", storage.ArticleWriteFailed, article.Id, err)
+	}
+	revision := revisionBson{
+		ArticleId:  string(article.Id),
+		Number:     int(count) + 1,
+		ReplacedAt: time.Now().UTC(),
+		Article:    old,
+	}
+	_, err = m.revisionsColl.InsertOne(ctx, revision)
+	if err != nil {
+		return fmt.Errorf("%w with id: %v: could not keep revision: %v", storage.ArticleWriteFailed, article.Id, err)
+	}
+	doc := fromArticle(article)
+	doc.Pinned = old.Pinned
+	result, err := m.articlesColl.ReplaceOne(ctx, bson.M{"id": article.Id}, doc)
+	if err == nil && result.MatchedCount == 1 {
+		return nil
+	}
+	// article was not replaced, so its revision would duplicate the current one
+	_, deleteErr := m.revisionsColl.DeleteOne(ctx, bson.M{"articleId": article.Id, "number": revision.Number})
+	if deleteErr != nil {
+		return fmt.Errorf("%w with id: %v: could not remove revision %v: %v", storage.ArticleWriteFailed, article.Id, revision.Number, deleteErr)
+	}
+	if err != nil {
+		return fmt.Errorf("%w with id: %v: %v", storage.ArticleWriteFailed, article.Id, err)
+	}
+	// deleted in the meantime
+	return fmt.Errorf("%w with id: %v", storage.ArticleNotFound, article.Id)
+}
+
+func (m mongoStorage) ListRevisions(ctx context.Context, id types.ArticleId) ([]types.ArticleRevision, error) {
+	ctx, cancel := context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+	count, err := m.articlesColl.CountDocuments(ctx, bson.M{"id": id})
+	if err != nil {
+		return nil, fmt.Errorf("error checking article: %w", err)
+	}
+	if count == 0 {
+		return nil, fmt.Errorf("%w with id: %v", storage.ArticleNotFound, id)
+	}
+	cur, err := m.revisionsColl.Find(ctx, bson.M{"articleId": id}, options.Find().SetSort(bson.D{{Key: "number", Value: 1}}))
+	if err != nil {
+		return nil, fmt.Errorf("error getting revisions: %w", err)
+	}
+	var docs []revisionBson
+	err = cur.All(ctx, &docs)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding revisions: %w", err)
+	}
+	var revisions []types.ArticleRevision
+	for _, doc := range docs {
+		revisions = append(revisions, doc.ToRevision())
+	}
+	return revisions, nil
+}
+
+func (m mongoStorage) SetPinned(ctx context.Context, id types.ArticleId, pinned bool) error {
+	ctx, cancel := context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+	update := bson.M{"$unset": bson.M{"pinned": ""}}
+	if pinned {
+		update = bson.M{"$set": bson.M{"pinned": true}}
+	}
+	result, err := m.articlesColl.UpdateOne(ctx, bson.M{"id": id}, update)
+	if err != nil {
+		return fmt.Errorf("%w with id: %v: %v", storage.ArticleWriteFailed, id, err)
+	}
+	if result.MatchedCount == 0 {
+		return fmt.Errorf("%w with id: %v", storage.ArticleNotFound, id)
+	}
+	return nil
+}
+
+func (m mongoStorage) SetUpdatedAt(ctx context.Context, id types.ArticleId, updatedAt time.Time) error {
+	ctx, cancel := context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+	result, err := m.articlesColl.UpdateOne(ctx, bson.M{"id": id}, bson.M{"$set": bson.M{"updatedAt": updatedAt}})
+	if err != nil {
+		return fmt.Errorf("%w with id: %v: %v", storage.ArticleWriteFailed, id, err)
+	}
+	if result.MatchedCount == 0 {
+		return fmt.Errorf("%w with id: %v", storage.ArticleNotFound, id)
+	}
+	return nil
+}
+
+func (m mongoStorage) GetNewsWithoutDetailsIDs(ctx context.Context, teamId string) ([]string, error) {
+	ctx, cancel := context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+
+	filter := bson.M{"hasDetails": false, "retractedAt": bson.M{"$exists": false}}
+	if teamId != "" {
+		filter["teamId"] = teamId
+	}
 	cur, err := m.articlesColl.Find(ctx, filter)
 	if err != nil {
 		return nil, fmt.Errorf("error getting news without details: %w", err)
@@ -114,8 +287,8 @@ func (m mongoStorage) GetNewsWithoutDetailsIDs() ([]string, error) {
 	return newsIds, nil
 }
 
-func (m mongoStorage) List() ([]types.Article, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), m.timeout)
+func (m mongoStorage) List(ctx context.Context) ([]types.Article, error) {
+	ctx, cancel := context.WithTimeout(ctx, m.timeout)
 	defer cancel()
 
 	var articles []types.Article
@@ -143,58 +316,228 @@ func (m mongoStorage) List() ([]types.Article, error) {
 	return articles, nil
 }
 
-func (m mongoStorage) Get(id types.ArticleId) (types.Article, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), m.timeout)
+func (m mongoStorage) Count(ctx context.Context, f storage.ArticleFilter) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, m.timeout)
 	defer cancel()
+	count, err := m.articlesColl.CountDocuments(ctx, articleFilter(f))
+	if err != nil {
+		return 0, fmt.Errorf("error counting articles: %w", err)
+	}
+	return int(count), nil
+}
 
-	filter := bson.M{"id": id}
-	var article articleBson
-	err := m.articlesColl.FindOne(ctx, filter).Decode(&article)
+func (m mongoStorage) ListPage(ctx context.Context, q storage.PageQuery) (storage.ArticlePage, error) {
+	cursor, err := q.Validate()
 	if err != nil {
-		if err == mongo.ErrNoDocuments {
-			return types.Article{}, fmt.Errorf("%w with id: %v", storage.ArticleNotFound, id)
+		return storage.ArticlePage{}, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+
+	filter := articleFilter(q.Filter)
+	if cursor.LastId != "" {
+		after, err := afterCursorFilter(q.Sort, cursor)
+		if err != nil {
+			return storage.ArticlePage{}, err
 		}
-		return types.Article{}, err
+		filter = bson.M{"$and": bson.A{filter, after}}
+	}
+	direction := 1
+	if q.Sort.Descending() {
+		direction = -1
 	}
+	// ask for one more article than needed, so we know if there is next page
+	findOptions := options.Find().
+		SetSort(bson.D{{Key: q.Sort.Field(), Value: direction}, {Key: "id", Value: direction}}).
+		SetLimit(int64(q.Limit + 1))
+	cur, err := m.articlesColl.Find(ctx, filter, findOptions)
+	if err != nil {
+		return storage.ArticlePage{}, fmt.Errorf("error getting page of articles: %w", err)
+	}
+	defer func(cur *mongo.Cursor, ctx context.Context) {
+		err := cur.Close(ctx)
+		if err != nil {
+			fmt.Printf("error closing cursor %s", err)
+		}
+	}(cur, ctx)
 
-	return article.ToArticle(), nil
+	page := storage.ArticlePage{Articles: make([]types.Article, 0, q.Limit), Sort: q.Sort}
+	for cur.Next(ctx) {
+		if len(page.Articles) == q.Limit {
+			page.HasMore = true
+			break
+		}
+		var article articleBson
+		if err := cur.Decode(&article); err != nil {
+			return storage.ArticlePage{}, fmt.Errorf("error decoding article: %w", err)
+		}
+		page.Articles = append(page.Articles, article.ToArticle())
+	}
+	if err := cur.Err(); err != nil {
+		return storage.ArticlePage{}, fmt.Errorf("error iterating articles: %w", err)
+	}
+	if page.HasMore {
+		page.NextCursor = storage.NewCursor(q.Sort, page.Articles[len(page.Articles)-1]).Encode()
+	}
+	return page, nil
+}
+
+// articleFilter translates storage.ArticleFilter into mongo query
+func articleFilter(f storage.ArticleFilter) bson.M {
+	filter := bson.M{}
+	if f.Type != "" {
+		filter["type"] = f.Type
+	}
+	if f.TeamId != "" {
+		filter["teamId"] = f.TeamId
+	}
+	if f.OptaMatchId != "" {
+		filter["optaMatchId"] = f.OptaMatchId
+	}
+	published := bson.M{}
+	if !f.PublishedAfter.IsZero() {
+		published["$gt"] = f.PublishedAfter
+	}
+	if !f.PublishedBefore.IsZero() {
+		published["$lt"] = f.PublishedBefore
+	}
+	if len(published) > 0 {
+		filter["published"] = published
+	}
+	if f.HasDetails != nil {
+		filter["hasDetails"] = *f.HasDetails
+	}
+	if f.Retracted != nil {
+		filter["retractedAt"] = bson.M{"$exists": *f.Retracted}
+	}
+	if len(f.Ids) > 0 {
+		filter["id"] = bson.M{"$in": f.Ids}
+	}
+	if f.Pinned != nil {
+		// pinned is omitted from documents of articles that are not pinned
+		filter["pinned"] = bson.M{"$ne": true}
+		if *f.Pinned {
+			filter["pinned"] = true
+		}
+	}
+	return filter
 }
 
-func (m mongoStorage) Write(article types.Article) error {
-	foundArticle, err := m.Get(article.Id)
-	override := false
+func (m mongoStorage) Search(ctx context.Context, q storage.SearchQuery) ([]storage.SearchResult, error) {
+	err := q.Validate()
 	if err != nil {
-		if !errors.Is(err, storage.ArticleNotFound) {
-			return err
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+
+	score := bson.M{"$meta": "textScore"}
+	findOptions := options.Find().
+		SetProjection(bson.M{"score": score}).
+		SetSort(bson.D{{Key: "score", Value: score}, {Key: "published", Value: -1}}).
+		SetLimit(int64(q.Limit))
+	cur, err := m.articlesColl.Find(ctx, bson.M{"$text": bson.M{"$search": q.Text}, "retractedAt": bson.M{"$exists": false}}, findOptions)
+	if err != nil {
+		return nil, fmt.Errorf("error searching articles: %w", err)
+	}
+	defer func(cur *mongo.Cursor, ctx context.Context) {
+		err := cur.Close(ctx)
+		if err != nil {
+			fmt.Printf("error closing cursor %s", err)
 		}
-		// if not found we are ok with creation
-	} else {
-		// if found
-		override = true
-		if !article.HasDetails {
-			// if our new article doesn't have details
-			return fmt.Errorf("%w with articleID %v with NewsId %v", storage.ArticleAlreadyExists, foundArticle.Id, foundArticle.NewsId)
+	}(cur, ctx)
+
+	results := make([]storage.SearchResult, 0)
+	for cur.Next(ctx) {
+		var found struct {
+			articleBson `bson:",inline"`
+			Score       float64 `bson:"score"`
 		}
-		if foundArticle.HasDetails {
-			// if our old article already had details
-			return fmt.Errorf("%w with articleID %v with NewsId %v, but wanted to override with NewsId %v", storage.ArticleAlreadyExists, foundArticle.Id, foundArticle.NewsId, article.NewsId)
+		if err := cur.Decode(&found); err != nil {
+			return nil, fmt.Errorf("error decoding article: %w", err)
 		}
+		results = append(results, storage.SearchResult{Article: found.ToArticle(), Score: found.Score})
+	}
+	if err := cur.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating articles: %w", err)
 	}
+	return results, nil
+}
 
-	// Delete previous articles with that Id
-	if override {
-		err = m.Delete(article.Id)
+/*
+afterCursorFilter matches articles that come after the cursor in given sort order,
+which means they have further sort field value or the same value and further id.
+*/
+func afterCursorFilter(sort storage.SortOrder, cursor storage.Cursor) (bson.M, error) {
+	op := "$gt"
+	if sort.Descending() {
+		op = "$lt"
+	}
+	// every sorted field is stored also when empty, as comparisons match only values of the same type
+	var key interface{} = cursor.Key
+	if sort.Field() == storage.SortFieldPublished {
+		published, err := storage.ParsePublishedSortKey(cursor.Key)
 		if err != nil {
-			return err
+			return nil, err
 		}
+		key = published
 	}
+	return bson.M{"$or": bson.A{
+		bson.M{sort.Field(): bson.M{op: key}},
+		bson.M{sort.Field(): key, "id": bson.M{op: cursor.LastId}},
+	}}, nil
+}
 
-	// Insert the article into the collection
-	ctx, cancel := context.WithTimeout(context.Background(), m.timeout)
+func (m mongoStorage) Get(ctx context.Context, id types.ArticleId) (types.Article, error) {
+	ctx, cancel := context.WithTimeout(ctx, m.timeout)
 	defer cancel()
-	_, err = m.articlesColl.InsertOne(ctx, fromArticle(article))
+
+	filter := bson.M{"id": id}
+	var article articleBson
+	err := m.articlesColl.FindOne(ctx, filter).Decode(&article)
 	if err != nil {
-		return fmt.Errorf("%w with id: %v", storage.ArticleWriteFailed, article.Id)
+		if err == mongo.ErrNoDocuments {
+			return types.Article{}, fmt.Errorf("%w with id: %v", storage.ArticleNotFound, id)
+		}
+		return types.Article{}, err
+	}
+
+	return article.ToArticle(), nil
+}
+
+/*
+Write saves article with single atomic operation, backed by unique index on id.
+
+Article without details is only inserted when there is no article with its id.
+Article with details is inserted or replaces stored article that has no details and is not retracted,
+only pinning of the stored article is kept.
+In all other cases storage.ArticleAlreadyExists is returned.
+*/
+func (m mongoStorage) Write(ctx context.Context, article types.Article) error {
+	ctx, cancel := context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+	var err error
+	if article.HasDetails {
+		// stored article is replaced by the new one, only its pinning is kept,
+		// $literal keeps values starting with $ from being read as field paths
+		replacement := bson.A{bson.M{"$replaceWith": bson.M{"$mergeObjects": bson.A{
+			bson.M{"$literal": fromArticle(article)},
+			bson.M{"pinned": "$pinned"},
+		}}}}
+		_, err = m.articlesColl.UpdateOne(ctx,
+			bson.M{"id": article.Id, "hasDetails": false, "retractedAt": bson.M{"$exists": false}},
+			replacement,
+			options.Update().SetUpsert(true),
+		)
+	} else {
+		_, err = m.articlesColl.InsertOne(ctx, fromArticle(article))
+	}
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			// upsert found no article without details, so the one stored already has them
+			return fmt.Errorf("%w with id: %v", storage.ArticleAlreadyExists, article.Id)
+		}
+		return fmt.Errorf("%w with id: %v: %v", storage.ArticleWriteFailed, article.Id, err)
 	}
 	return nil
 }
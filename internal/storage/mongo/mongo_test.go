@@ -0,0 +1,146 @@
+package mongo
+
+import (
+	"context"
+	"fmt"
+	"github.com/adamdyszy/sportsnews/storage"
+	"github.com/adamdyszy/sportsnews/storage/storagetest"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testURIEnv names environment variable with uri of mongo used by tests, tests are skipped when it is not set
+const testURIEnv = "SPORTSNEWS_TEST_MONGO_URI"
+
+// collKeys are config keys of all collections used by mongo storages
+var collKeys = []string{"articlesColl", "migrationsColl", "revisionsColl", "webhooksColl", "webhookDeliveriesColl", "backfillColl", "retriesColl"}
+
+// newTestConfig returns config of test mongo with new collections
+func newTestConfig(t *testing.T) *viper.Viper {
+	uri := os.Getenv(testURIEnv)
+	if uri == "" {
+		t.Skipf("%v is not set", testURIEnv)
+	}
+	v := viper.New()
+	v.Set("uri", uri)
+	v.Set("name", "sportsnewsTest")
+	suffix := time.Now().UnixNano()
+	for _, key := range collKeys {
+		v.Set(key, fmt.Sprintf("%v%d", strings.TrimSuffix(key, "Coll"), suffix))
+	}
+	v.Set("migrateOnStart", true)
+	v.Set("timeoutSeconds", 10)
+	return v
+}
+
+// newTestStorageWithConfig connects to test mongo with config v and returns storage, its collections are dropped after the test
+func newTestStorageWithConfig(t *testing.T, v *viper.Viper) *mongoStorage {
+	s, err := NewMongoStorage(v, context.Background())
+	require.NoError(t, err)
+	m := s.(*mongoStorage)
+	t.Cleanup(func() {
+		ctx := context.Background()
+		for _, key := range collKeys {
+			assert.NoError(t, m.client.Database(m.database).Collection(v.GetString(key)).Drop(ctx))
+		}
+		assert.NoError(t, m.Disconnect())
+	})
+	return m
+}
+
+// newTestStorage connects to test mongo and returns storage using new collections, which are dropped after the test
+func newTestStorage(t *testing.T) storage.ArticleStorage {
+	return newTestStorageWithConfig(t, newTestConfig(t))
+}
+
+func TestConformance(t *testing.T) {
+	storagetest.Run(t, newTestStorage)
+}
+
+func TestMigrate(t *testing.T) {
+	v := newTestConfig(t)
+	m := newTestStorageWithConfig(t, v)
+	migrator := newMigrator(m.client.Database(m.database), v)
+	migrator.migrationsColl = m.client.Database(m.database).Collection(m.articlesColl.Name() + "Migrations")
+	t.Cleanup(func() {
+		assert.NoError(t, migrator.migrationsColl.Drop(context.Background()))
+	})
+
+	// indexes already exist, so applying migrations again only records them
+	applied, err := migrator.migrate(context.Background())
+	require.NoError(t, err)
+	assert.Len(t, applied, len(migrations))
+	applied, err = migrator.migrate(context.Background())
+	require.NoError(t, err)
+	assert.Empty(t, applied)
+
+	specs, err := m.articlesColl.Indexes().ListSpecifications(context.Background())
+	require.NoError(t, err)
+	var names []string
+	for _, spec := range specs {
+		names = append(names, spec.Name)
+	}
+	assert.Subset(t, names, []string{"articlesId", "articlesTextSearch", "articlesHasDetails", "articlesTeamIdPublished", "articlesNewsId"})
+	specs, err = m.revisionsColl.Indexes().ListSpecifications(context.Background())
+	require.NoError(t, err)
+	names = nil
+	for _, spec := range specs {
+		names = append(names, spec.Name)
+	}
+	assert.Contains(t, names, "revisionsArticleIdNumber")
+	for coll, index := range map[*mongo.Collection]string{
+		migrator.schema.webhooks:   "id_1",
+		migrator.schema.deliveries: "subscriptionId_1_time_-1",
+		migrator.schema.backfill:   "teamId_1",
+		migrator.schema.retries:    "teamId_1_newsId_1",
+	} {
+		specs, err = coll.Indexes().ListSpecifications(context.Background())
+		require.NoError(t, err)
+		names = nil
+		for _, spec := range specs {
+			names = append(names, spec.Name)
+		}
+		assert.Contains(t, names, index, coll.Name())
+	}
+}
+
+func TestMigrateRemovesDuplicatedIds(t *testing.T) {
+	ctx := context.Background()
+	v := newTestConfig(t)
+	client, _, err := connect(v)
+	require.NoError(t, err)
+	migrator := newMigrator(client.Database(v.GetString("name")), v)
+	t.Cleanup(func() {
+		for _, key := range collKeys {
+			assert.NoError(t, client.Database(v.GetString("name")).Collection(v.GetString(key)).Drop(ctx))
+		}
+		assert.NoError(t, client.Disconnect(ctx))
+	})
+
+	// racy Write of old versions could store the same article twice
+	_, err = migrator.schema.articles.InsertMany(ctx, []interface{}{
+		bson.M{"id": "a", "title": "listed", "hasDetails": false},
+		bson.M{"id": "a", "title": "detailed", "hasDetails": true},
+		bson.M{"id": "a", "title": "listed again", "hasDetails": false},
+		bson.M{"id": "b", "title": "single", "hasDetails": false},
+	})
+	require.NoError(t, err)
+	_, err = migrator.migrate(ctx)
+	require.NoError(t, err)
+
+	var kept []bson.M
+	cur, err := migrator.schema.articles.Find(ctx, bson.M{}, options.Find().SetSort(bson.D{{Key: "id", Value: 1}}))
+	require.NoError(t, err)
+	require.NoError(t, cur.All(ctx, &kept))
+	require.Len(t, kept, 2)
+	assert.Equal(t, "detailed", kept[0]["title"])
+	assert.Equal(t, "single", kept[1]["title"])
+}
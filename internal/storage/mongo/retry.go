@@ -0,0 +1,108 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/adamdyszy/sportsnews/storage"
+	"github.com/adamdyszy/sportsnews/types"
+	"github.com/spf13/viper"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"time"
+)
+
+type retryBson struct {
+	TeamId        string     `bson:"teamId"`
+	NewsId        string     `bson:"newsId"`
+	Attempts      int        `bson:"attempts"`
+	LastError     string     `bson:"lastError"`
+	LastAttemptAt time.Time  `bson:"lastAttemptAt"`
+	NextAttemptAt *time.Time `bson:"nextAttemptAt,omitempty"`
+	Dead          bool       `bson:"dead"`
+}
+
+type retryStorage struct {
+	client      *mongo.Client
+	retriesColl *mongo.Collection
+	timeout     time.Duration
+}
+
+// NewMongoRetryStorage creates retry storage keeping news ids waiting for retry in retriesColl,
+// it uses client of mongo article storage s, its indexes are created by migrations
+func NewMongoRetryStorage(s storage.ArticleStorage, v *viper.Viper) (storage.RetryStorage, error) {
+	m, ok := s.(*mongoStorage)
+	if !ok {
+		return nil, errors.New("mongo retry storage needs mongo article storage")
+	}
+	return &retryStorage{
+		client:      m.client,
+		retriesColl: m.client.Database(m.database).Collection(v.GetString("retriesColl")),
+		timeout:     m.timeout,
+	}, nil
+}
+
+// Disconnect does nothing, client is shared with article storage which disconnects it
+func (r *retryStorage) Disconnect() error {
+	return nil
+}
+
+func (r *retryStorage) GetRetry(ctx context.Context, teamId string, newsId string) (types.DetailsRetry, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	var found retryBson
+	err := r.retriesColl.FindOne(ctx, bson.M{"teamId": teamId, "newsId": newsId}).Decode(&found)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return types.DetailsRetry{}, fmt.Errorf("%w with teamId: %v and newsId: %v", storage.RetryNotFound, teamId, newsId)
+		}
+		return types.DetailsRetry{}, err
+	}
+	return types.DetailsRetry(found), nil
+}
+
+func (r *retryStorage) SaveRetry(ctx context.Context, retry types.DetailsRetry) error {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	_, err := r.retriesColl.ReplaceOne(ctx, bson.M{"teamId": retry.TeamId, "newsId": retry.NewsId}, retryBson(retry), options.Replace().SetUpsert(true))
+	if err != nil {
+		return fmt.Errorf("error saving details retry: %w", err)
+	}
+	return nil
+}
+
+func (r *retryStorage) DeleteRetry(ctx context.Context, teamId string, newsId string) error {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	result, err := r.retriesColl.DeleteOne(ctx, bson.M{"teamId": teamId, "newsId": newsId})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return fmt.Errorf("%w with teamId: %v and newsId: %v", storage.RetryNotFound, teamId, newsId)
+	}
+	return nil
+}
+
+func (r *retryStorage) ListRetries(ctx context.Context, teamId string) ([]types.DetailsRetry, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	filter := bson.M{}
+	if teamId != "" {
+		filter["teamId"] = teamId
+	}
+	cur, err := r.retriesColl.Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "lastAttemptAt", Value: 1}}))
+	if err != nil {
+		return nil, fmt.Errorf("error getting details retries: %w", err)
+	}
+	var found []retryBson
+	if err := cur.All(ctx, &found); err != nil {
+		return nil, fmt.Errorf("error decoding details retries: %w", err)
+	}
+	retries := make([]types.DetailsRetry, 0, len(found))
+	for _, retry := range found {
+		retries = append(retries, types.DetailsRetry(retry))
+	}
+	return retries, nil
+}
@@ -0,0 +1,206 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/adamdyszy/sportsnews/storage"
+	"github.com/adamdyszy/sportsnews/types"
+	"github.com/spf13/viper"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"time"
+)
+
+type webhookBson struct {
+	Id        string    `bson:"id"`
+	URL       string    `bson:"url"`
+	Secret    string    `bson:"secret"`
+	TeamId    string    `bson:"teamId,omitempty"`
+	Type      string    `bson:"type,omitempty"`
+	CreatedAt time.Time `bson:"createdAt"`
+}
+
+type deliveryBson struct {
+	SubscriptionId string    `bson:"subscriptionId"`
+	EventId        uint64    `bson:"eventId"`
+	Event          string    `bson:"event"`
+	ArticleId      string    `bson:"articleId"`
+	Attempt        int       `bson:"attempt"`
+	StatusCode     int       `bson:"statusCode,omitempty"`
+	Error          string    `bson:"error,omitempty"`
+	Succeeded      bool      `bson:"succeeded"`
+	Time           time.Time `bson:"time"`
+}
+
+func (w webhookBson) toSubscription() types.WebhookSubscription {
+	return types.WebhookSubscription(w)
+}
+
+func (d deliveryBson) toDelivery() types.WebhookDelivery {
+	return types.WebhookDelivery{
+		SubscriptionId: d.SubscriptionId,
+		EventId:        d.EventId,
+		Event:          d.Event,
+		ArticleId:      types.ArticleId(d.ArticleId),
+		Attempt:        d.Attempt,
+		StatusCode:     d.StatusCode,
+		Error:          d.Error,
+		Succeeded:      d.Succeeded,
+		Time:           d.Time,
+	}
+}
+
+type webhookStorage struct {
+	client          *mongo.Client
+	webhooksColl    *mongo.Collection
+	deliveriesColl  *mongo.Collection
+	deliveryLogSize int
+	timeout         time.Duration
+}
+
+// NewMongoWebhookStorage creates webhook storage keeping deliveryLogSize last deliveries of every subscription,
+// it uses client of mongo article storage s, its indexes are created by migrations
+func NewMongoWebhookStorage(s storage.ArticleStorage, v *viper.Viper, deliveryLogSize int) (storage.WebhookStorage, error) {
+	m, ok := s.(*mongoStorage)
+	if !ok {
+		return nil, errors.New("mongo webhook storage needs mongo article storage")
+	}
+	db := m.client.Database(m.database)
+	return &webhookStorage{
+		client:          m.client,
+		webhooksColl:    db.Collection(v.GetString("webhooksColl")),
+		deliveriesColl:  db.Collection(v.GetString("webhookDeliveriesColl")),
+		deliveryLogSize: deliveryLogSize,
+		timeout:         m.timeout,
+	}, nil
+}
+
+// Disconnect does nothing, client is shared with article storage which disconnects it
+func (w *webhookStorage) Disconnect() error {
+	return nil
+}
+
+func (w *webhookStorage) CreateSubscription(ctx context.Context, s types.WebhookSubscription) error {
+	ctx, cancel := context.WithTimeout(ctx, w.timeout)
+	defer cancel()
+	_, err := w.webhooksColl.InsertOne(ctx, webhookBson(s))
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return fmt.Errorf("%w with id: %v", storage.SubscriptionAlreadyExists, s.Id)
+		}
+		return fmt.Errorf("error creating webhook subscription: %w", err)
+	}
+	return nil
+}
+
+func (w *webhookStorage) GetSubscription(ctx context.Context, id string) (types.WebhookSubscription, error) {
+	ctx, cancel := context.WithTimeout(ctx, w.timeout)
+	defer cancel()
+	var s webhookBson
+	err := w.webhooksColl.FindOne(ctx, bson.M{"id": id}).Decode(&s)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return types.WebhookSubscription{}, fmt.Errorf("%w with id: %v", storage.SubscriptionNotFound, id)
+		}
+		return types.WebhookSubscription{}, err
+	}
+	return s.toSubscription(), nil
+}
+
+func (w *webhookStorage) ListSubscriptions(ctx context.Context) ([]types.WebhookSubscription, error) {
+	ctx, cancel := context.WithTimeout(ctx, w.timeout)
+	defer cancel()
+	cur, err := w.webhooksColl.Find(ctx, bson.M{}, options.Find().SetSort(bson.D{{Key: "createdAt", Value: 1}}))
+	if err != nil {
+		return nil, fmt.Errorf("error getting webhook subscriptions: %w", err)
+	}
+	var found []webhookBson
+	if err := cur.All(ctx, &found); err != nil {
+		return nil, fmt.Errorf("error decoding webhook subscriptions: %w", err)
+	}
+	subscriptions := make([]types.WebhookSubscription, 0, len(found))
+	for _, s := range found {
+		subscriptions = append(subscriptions, s.toSubscription())
+	}
+	return subscriptions, nil
+}
+
+func (w *webhookStorage) DeleteSubscription(ctx context.Context, id string) error {
+	ctx, cancel := context.WithTimeout(ctx, w.timeout)
+	defer cancel()
+	result, err := w.webhooksColl.DeleteOne(ctx, bson.M{"id": id})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return fmt.Errorf("%w with id: %v", storage.SubscriptionNotFound, id)
+	}
+	_, err = w.deliveriesColl.DeleteMany(ctx, bson.M{"subscriptionId": id})
+	return err
+}
+
+func (w *webhookStorage) AddDelivery(ctx context.Context, d types.WebhookDelivery) error {
+	ctx, cancel := context.WithTimeout(ctx, w.timeout)
+	defer cancel()
+	_, err := w.deliveriesColl.InsertOne(ctx, deliveryBson{
+		SubscriptionId: d.SubscriptionId,
+		EventId:        d.EventId,
+		Event:          d.Event,
+		ArticleId:      string(d.ArticleId),
+		Attempt:        d.Attempt,
+		StatusCode:     d.StatusCode,
+		Error:          d.Error,
+		Succeeded:      d.Succeeded,
+		Time:           d.Time,
+	})
+	if err != nil {
+		return fmt.Errorf("error adding webhook delivery: %w", err)
+	}
+	if w.deliveryLogSize <= 0 {
+		return nil
+	}
+	// find the newest delivery that no longer fits into the log and remove it with all older ones
+	var oldest deliveryBson
+	err = w.deliveriesColl.FindOne(ctx, bson.M{"subscriptionId": d.SubscriptionId},
+		options.FindOne().SetSort(bson.D{{Key: "time", Value: -1}}).SetSkip(int64(w.deliveryLogSize)),
+	).Decode(&oldest)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil
+		}
+		return fmt.Errorf("error trimming webhook delivery log: %w", err)
+	}
+	_, err = w.deliveriesColl.DeleteMany(ctx, bson.M{"subscriptionId": d.SubscriptionId, "time": bson.M{"$lte": oldest.Time}})
+	if err != nil {
+		return fmt.Errorf("error trimming webhook delivery log: %w", err)
+	}
+	return nil
+}
+
+func (w *webhookStorage) ListDeliveries(ctx context.Context, subscriptionId string, limit int) ([]types.WebhookDelivery, error) {
+	_, err := w.GetSubscription(ctx, subscriptionId)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, w.timeout)
+	defer cancel()
+	findOptions := options.Find().SetSort(bson.D{{Key: "time", Value: -1}})
+	if limit > 0 {
+		findOptions.SetLimit(int64(limit))
+	}
+	cur, err := w.deliveriesColl.Find(ctx, bson.M{"subscriptionId": subscriptionId}, findOptions)
+	if err != nil {
+		return nil, fmt.Errorf("error getting webhook deliveries: %w", err)
+	}
+	var found []deliveryBson
+	if err := cur.All(ctx, &found); err != nil {
+		return nil, fmt.Errorf("error decoding webhook deliveries: %w", err)
+	}
+	deliveries := make([]types.WebhookDelivery, 0, len(found))
+	for _, d := range found {
+		deliveries = append(deliveries, d.toDelivery())
+	}
+	return deliveries, nil
+}
@@ -0,0 +1,141 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"github.com/adamdyszy/sportsnews/storage"
+	"github.com/adamdyszy/sportsnews/types"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"time"
+)
+
+// tracedStorage starts span for every call to wrapped storage
+type tracedStorage struct {
+	s storage.ArticleStorage
+}
+
+// NewTracedStorage wraps s, so each of its calls is traced as span named storage.<operation>
+func NewTracedStorage(s storage.ArticleStorage) storage.ArticleStorage {
+	return tracedStorage{s: s}
+}
+
+// start starts span of storage operation as child of the span in ctx
+func start(ctx context.Context, operation string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return Tracer().Start(ctx, "storage."+operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attrs...),
+	)
+}
+
+// end ends the span, not found and already existing articles are expected results, not errors
+func end(span trace.Span, err error) {
+	if errors.Is(err, storage.ArticleNotFound) || errors.Is(err, storage.ArticleAlreadyExists) {
+		span.SetAttributes(attribute.String("storage.result", err.Error()))
+		err = nil
+	}
+	End(span, err)
+}
+
+func (t tracedStorage) Get(ctx context.Context, id types.ArticleId) (types.Article, error) {
+	ctx, span := start(ctx, "get", attribute.String("article.id", string(id)))
+	a, err := t.s.Get(ctx, id)
+	end(span, err)
+	return a, err
+}
+
+func (t tracedStorage) GetNewsWithoutDetailsIDs(ctx context.Context, teamId string) ([]string, error) {
+	ctx, span := start(ctx, "getNewsWithoutDetailsIDs", attribute.String("team.id", teamId))
+	ids, err := t.s.GetNewsWithoutDetailsIDs(ctx, teamId)
+	span.SetAttributes(attribute.Int("news.count", len(ids)))
+	end(span, err)
+	return ids, err
+}
+
+func (t tracedStorage) List(ctx context.Context) ([]types.Article, error) {
+	ctx, span := start(ctx, "list")
+	articles, err := t.s.List(ctx)
+	end(span, err)
+	return articles, err
+}
+
+func (t tracedStorage) Count(ctx context.Context, f storage.ArticleFilter) (int, error) {
+	ctx, span := start(ctx, "count")
+	count, err := t.s.Count(ctx, f)
+	end(span, err)
+	return count, err
+}
+
+func (t tracedStorage) ListPage(ctx context.Context, q storage.PageQuery) (storage.ArticlePage, error) {
+	ctx, span := start(ctx, "listPage", attribute.Int("page.limit", q.Limit), attribute.String("page.sort", string(q.Sort)))
+	page, err := t.s.ListPage(ctx, q)
+	end(span, err)
+	return page, err
+}
+
+func (t tracedStorage) Search(ctx context.Context, q storage.SearchQuery) ([]storage.SearchResult, error) {
+	ctx, span := start(ctx, "search", attribute.Int("search.limit", q.Limit))
+	results, err := t.s.Search(ctx, q)
+	end(span, err)
+	return results, err
+}
+
+func (t tracedStorage) Write(ctx context.Context, a types.Article) error {
+	ctx, span := start(ctx, "write", attribute.String("article.id", string(a.Id)), attribute.Bool("article.hasDetails", a.HasDetails))
+	err := t.s.Write(ctx, a)
+	end(span, err)
+	return err
+}
+
+func (t tracedStorage) ListRevisions(ctx context.Context, id types.ArticleId) ([]types.ArticleRevision, error) {
+	ctx, span := start(ctx, "listRevisions", attribute.String("article.id", string(id)))
+	revisions, err := t.s.ListRevisions(ctx, id)
+	end(span, err)
+	return revisions, err
+}
+
+func (t tracedStorage) Replace(ctx context.Context, a types.Article) error {
+	ctx, span := start(ctx, "replace", attribute.String("article.id", string(a.Id)), attribute.Bool("article.hasDetails", a.HasDetails))
+	err := t.s.Replace(ctx, a)
+	end(span, err)
+	return err
+}
+
+func (t tracedStorage) Delete(ctx context.Context, id types.ArticleId) error {
+	ctx, span := start(ctx, "delete", attribute.String("article.id", string(id)))
+	err := t.s.Delete(ctx, id)
+	end(span, err)
+	return err
+}
+
+func (t tracedStorage) DeleteUnpinned(ctx context.Context, id types.ArticleId) error {
+	ctx, span := start(ctx, "deleteUnpinned", attribute.String("article.id", string(id)))
+	err := t.s.DeleteUnpinned(ctx, id)
+	end(span, err)
+	return err
+}
+
+func (t tracedStorage) SetPinned(ctx context.Context, id types.ArticleId, pinned bool) error {
+	ctx, span := start(ctx, "setPinned", attribute.String("article.id", string(id)), attribute.Bool("article.pinned", pinned))
+	err := t.s.SetPinned(ctx, id, pinned)
+	end(span, err)
+	return err
+}
+
+func (t tracedStorage) SetUpdatedAt(ctx context.Context, id types.ArticleId, updatedAt time.Time) error {
+	ctx, span := start(ctx, "setUpdatedAt", attribute.String("article.id", string(id)))
+	err := t.s.SetUpdatedAt(ctx, id, updatedAt)
+	end(span, err)
+	return err
+}
+
+func (t tracedStorage) Ping(ctx context.Context) error {
+	ctx, span := start(ctx, "ping")
+	err := t.s.Ping(ctx)
+	end(span, err)
+	return err
+}
+
+func (t tracedStorage) Disconnect() error {
+	return t.s.Disconnect()
+}
@@ -0,0 +1,55 @@
+package tracing
+
+import (
+	"context"
+	"github.com/adamdyszy/sportsnews/internal/storage/memory"
+	"github.com/adamdyszy/sportsnews/storage"
+	"github.com/adamdyszy/sportsnews/types"
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"testing"
+	"time"
+)
+
+func TestSetup(t *testing.T) {
+	shutdown, err := Setup(context.Background(), Config{}, logr.Discard())
+	require.NoError(t, err)
+	assert.NoError(t, shutdown(context.Background()))
+
+	// exporter connects lazily, so this only checks that the provider can be created
+	shutdown, err = Setup(context.Background(), Config{
+		Enabled:     true,
+		Endpoint:    "localhost:4318",
+		Insecure:    true,
+		ServiceName: "sportsnews",
+		SampleRatio: 1,
+	}, logr.Discard())
+	require.NoError(t, err)
+	assert.NoError(t, shutdown(context.Background()))
+}
+
+func TestTracedStorage(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	s := NewTracedStorage(memory.NewMemStorage())
+
+	a := types.Article{Id: "a1", ArticleKey: types.ArticleKey{NewsId: "1", TeamId: "t94", Published: time.Now()}}
+	require.NoError(t, s.Write(context.Background(), a))
+	assert.ErrorIs(t, s.Write(context.Background(), a), storage.ArticleAlreadyExists)
+	_, err := s.ListPage(context.Background(), storage.PageQuery{Limit: 10, Sort: "bogus"})
+	assert.Error(t, err)
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 3)
+	assert.Equal(t, "storage.write", spans[0].Name())
+	assert.Equal(t, codes.Unset, spans[0].Status().Code)
+	// already existing article is expected result, not error
+	assert.Equal(t, codes.Unset, spans[1].Status().Code)
+	assert.Equal(t, "storage.listPage", spans[2].Name())
+	assert.Equal(t, codes.Error, spans[2].Status().Code)
+}
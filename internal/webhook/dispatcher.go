@@ -0,0 +1,328 @@
+// Package webhook sends saved articles to registered webhook subscriptions
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/adamdyszy/sportsnews/internal/events"
+	"github.com/adamdyszy/sportsnews/storage"
+	"github.com/adamdyszy/sportsnews/types"
+	"github.com/go-logr/logr"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Headers sent with every delivery
+const (
+	SignatureHeader = "X-Sportsnews-Signature"
+	EventHeader     = "X-Sportsnews-Event"
+	EventIdHeader   = "X-Sportsnews-Event-Id"
+)
+
+// Config is the webhooks section of the config file
+type Config struct {
+	MaxAttempts           int `mapstructure:"maxAttempts"`
+	InitialBackoffSeconds int `mapstructure:"initialBackoffSeconds"`
+	TimeoutSeconds        int `mapstructure:"timeoutSeconds"`
+	DeliveryLogSize       int `mapstructure:"deliveryLogSize"`
+}
+
+// Validate checks that config values can be used
+func (c Config) Validate() error {
+	if c.MaxAttempts <= 0 {
+		return fmt.Errorf("maxAttempts has to be positive, got %v", c.MaxAttempts)
+	}
+	if c.InitialBackoffSeconds < 0 {
+		return fmt.Errorf("initialBackoffSeconds cannot be negative, got %v", c.InitialBackoffSeconds)
+	}
+	if c.TimeoutSeconds <= 0 {
+		return fmt.Errorf("timeoutSeconds has to be positive, got %v", c.TimeoutSeconds)
+	}
+	if c.DeliveryLogSize < 0 {
+		return fmt.Errorf("deliveryLogSize cannot be negative, got %v", c.DeliveryLogSize)
+	}
+	return nil
+}
+
+// ForbiddenAddress is returned by deliveries to urls that resolve to address which is not public
+var ForbiddenAddress = errors.New("webhook address is not public")
+
+// PublicIP tells if ip is public, so deliveries to it cannot reach the server itself or its network
+func PublicIP(ip net.IP) bool {
+	return !ip.IsLoopback() && !ip.IsPrivate() && !ip.IsUnspecified() && !ip.IsLinkLocalUnicast() && !ip.IsLinkLocalMulticast()
+}
+
+// allowedIP tells if deliveries can connect to ip, it is replaced in tests
+var allowedIP = PublicIP
+
+/*
+newClient creates client of deliveries, which connects only to allowed addresses and does not follow redirects.
+
+Address is checked when connecting, after host was resolved, so neither changed DNS record nor redirect
+leads deliveries to the server itself or its network.
+*/
+func newClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout:   timeout,
+		KeepAlive: 30 * time.Second,
+		Control: func(_, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			ip := net.ParseIP(host)
+			if ip == nil || !allowedIP(ip) {
+				return fmt.Errorf("%w: %v", ForbiddenAddress, host)
+			}
+			return nil
+		},
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// connection through proxy would check address of the proxy instead of the subscriber
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+		CheckRedirect: func(_ *http.Request, _ []*http.Request) error {
+			// redirect response is returned as it is and fails the attempt
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// Sign returns value of SignatureHeader for the payload, it is hex encoded HMAC-SHA256 prefixed with "sha256="
+func Sign(secret string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Matches tells if subscription wants to get the article
+func Matches(s types.WebhookSubscription, a types.Article) bool {
+	return storage.ArticleFilter{TeamId: s.TeamId, Type: s.Type}.Matches(a)
+}
+
+/*
+Dispatcher listens to events of the broker and POSTs article json
+to every matching subscription.
+
+Failed deliveries are retried with exponential backoff and every attempt is
+saved in the delivery log of the subscription.
+*/
+type Dispatcher struct {
+	broker *events.Broker
+	ws     storage.WebhookStorage
+	client *http.Client
+	config Config
+	logger logr.Logger
+	// ctx is the one given to Start, storage uses it, so attempts cancelled by Stop are still saved
+	ctx context.Context
+	// deliveryCtx is ctx of requests, it is cancelled with cancelDeliveries when Stop does not want to wait for them anymore
+	deliveryCtx      context.Context
+	cancelDeliveries context.CancelFunc
+	// stop is closed by Stop, stopped is closed when dispatching stops
+	stop       chan struct{}
+	stopped    chan struct{}
+	deliveries sync.WaitGroup
+}
+
+// NewDispatcher creates Dispatcher, config has to be valid
+func NewDispatcher(b *events.Broker, ws storage.WebhookStorage, config Config, logger logr.Logger) *Dispatcher {
+	return &Dispatcher{
+		broker: b,
+		ws:     ws,
+		client: newClient(time.Duration(config.TimeoutSeconds) * time.Second),
+		config: config,
+		logger: logger.WithValues("workerKind", "WebhookDispatcher"),
+	}
+}
+
+/*
+Start subscribes to events right away, so no event published after it returns is missed,
+and dispatches them in the background until Stop is called or ctx is done.
+*/
+func (d *Dispatcher) Start(ctx context.Context) {
+	d.ctx = ctx
+	d.deliveryCtx, d.cancelDeliveries = context.WithCancel(ctx)
+	d.stop = make(chan struct{})
+	d.stopped = make(chan struct{})
+	missed, ch, unsubscribe := d.broker.Subscribe(0)
+	go func() {
+		defer close(d.stopped)
+		d.run(missed, ch, unsubscribe)
+	}()
+}
+
+/*
+Stop stops dispatching and waits for started deliveries to finish, so they are not cut off by disconnected storage.
+
+When ctx is done first deliveries are cancelled and error is returned after they return.
+*/
+func (d *Dispatcher) Stop(ctx context.Context) error {
+	close(d.stop)
+	<-d.stopped
+	done := make(chan struct{})
+	go func() {
+		d.deliveries.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		d.cancelDeliveries()
+		<-done
+		return fmt.Errorf("running webhook deliveries did not finish: %w", ctx.Err())
+	}
+}
+
+// run dispatches events from subscription, it subscribes again when dispatcher falls behind
+func (d *Dispatcher) run(missed []events.Event, ch <-chan events.Event, unsubscribe func()) {
+	var lastId uint64
+	for {
+		for _, e := range missed {
+			d.dispatch(e)
+			lastId = e.Id
+		}
+		fellBehind := d.receive(ch, &lastId)
+		unsubscribe()
+		if !fellBehind {
+			return
+		}
+		// channel is closed when dispatcher falls behind, subscribe again and replay what was missed
+		d.logger.Info("Resubscribing to events after falling behind.", "lastEventId", lastId)
+		missed, ch, unsubscribe = d.broker.Subscribe(lastId)
+	}
+}
+
+// receive dispatches events from ch until it is closed, which means dispatcher fell behind, or until it is stopped
+func (d *Dispatcher) receive(ch <-chan events.Event, lastId *uint64) (fellBehind bool) {
+	for {
+		select {
+		case <-d.ctx.Done():
+			return false
+		case <-d.stop:
+			// events published before Stop are still dispatched
+			for {
+				select {
+				case e, ok := <-ch:
+					if !ok {
+						return false
+					}
+					d.dispatch(e)
+					*lastId = e.Id
+				default:
+					return false
+				}
+			}
+		case e, ok := <-ch:
+			if !ok {
+				return true
+			}
+			d.dispatch(e)
+			*lastId = e.Id
+		}
+	}
+}
+
+// dispatch starts delivery of event to every matching subscription
+func (d *Dispatcher) dispatch(e events.Event) {
+	subscriptions, err := d.ws.ListSubscriptions(d.ctx)
+	if err != nil {
+		d.logger.Error(err, "Could not list webhook subscriptions.", "eventId", e.Id)
+		return
+	}
+	payload, err := json.Marshal(e.Article)
+	if err != nil {
+		d.logger.Error(err, "Could not encode article.", "eventId", e.Id)
+		return
+	}
+	for _, s := range subscriptions {
+		if Matches(s, e.Article) {
+			d.deliveries.Add(1)
+			go func(s types.WebhookSubscription) {
+				defer d.deliveries.Done()
+				d.deliver(d.deliveryCtx, s, e, payload)
+			}(s)
+		}
+	}
+}
+
+// deliver sends payload to subscription, retrying with exponential backoff
+func (d *Dispatcher) deliver(ctx context.Context, s types.WebhookSubscription, e events.Event, payload []byte) {
+	logger := d.logger.WithValues("subscriptionId", s.Id, "eventId", e.Id, "articleId", e.Article.Id)
+	backoff := time.Duration(d.config.InitialBackoffSeconds) * time.Second
+	for attempt := 1; attempt <= d.config.MaxAttempts; attempt++ {
+		delivery := types.WebhookDelivery{
+			SubscriptionId: s.Id,
+			EventId:        e.Id,
+			Event:          string(e.Kind),
+			ArticleId:      e.Article.Id,
+			Attempt:        attempt,
+			Time:           time.Now(),
+		}
+		statusCode, err := d.post(ctx, s, e, payload)
+		delivery.StatusCode = statusCode
+		if err != nil {
+			delivery.Error = err.Error()
+		} else {
+			delivery.Succeeded = true
+		}
+		if err := d.ws.AddDelivery(d.ctx, delivery); err != nil {
+			logger.Error(err, "Could not save webhook delivery.")
+		}
+		if delivery.Succeeded {
+			return
+		}
+		logger.Info("Webhook delivery failed.", "attempt", attempt, "error", delivery.Error)
+		if attempt == d.config.MaxAttempts {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+	logger.Info("Giving up webhook delivery.", "attempts", d.config.MaxAttempts)
+}
+
+// post does single delivery attempt, any status other than 2xx is an error
+func (d *Dispatcher) post(ctx context.Context, s types.WebhookSubscription, e events.Event, payload []byte) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", s.URL, bytes.NewReader(payload))
+	if err != nil {
+		return 0, fmt.Errorf("failed to create new POST request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(SignatureHeader, Sign(s.Secret, payload))
+	req.Header.Set(EventHeader, string(e.Kind))
+	req.Header.Set(EventIdHeader, strconv.FormatUint(e.Id, 10))
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to do http request: %w", err)
+	}
+	defer func(Body io.ReadCloser) {
+		_, _ = io.Copy(io.Discard, Body)
+		err := Body.Close()
+		if err != nil {
+			d.logger.Error(err, "Error during close of response.")
+		}
+	}(resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("status error: %v", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
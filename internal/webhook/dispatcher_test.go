@@ -0,0 +1,190 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/adamdyszy/sportsnews/internal/events"
+	"github.com/adamdyszy/sportsnews/internal/storage/memory"
+	"github.com/adamdyszy/sportsnews/types"
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// allowLoopback lets deliveries reach test servers on loopback until the test ends
+func allowLoopback(t *testing.T) {
+	allowedIP = func(ip net.IP) bool {
+		return ip.IsLoopback() || PublicIP(ip)
+	}
+	t.Cleanup(func() {
+		allowedIP = PublicIP
+	})
+}
+
+func TestDispatcherRetriesAndSigns(t *testing.T) {
+	allowLoopback(t)
+	received := make(chan types.Article, 1)
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		assert.Equal(t, Sign("secret", body), r.Header.Get(SignatureHeader))
+		assert.Equal(t, "created", r.Header.Get(EventHeader))
+		var a types.Article
+		assert.NoError(t, json.Unmarshal(body, &a))
+		received <- a
+	}))
+	defer server.Close()
+
+	ws := memory.NewMemWebhookStorage(10)
+	assert.NoError(t, ws.CreateSubscription(context.Background(), types.WebhookSubscription{Id: "s1", URL: server.URL, Secret: "secret", TeamId: "t94"}))
+	b := events.NewBroker(10)
+	d := NewDispatcher(b, ws, Config{MaxAttempts: 3, TimeoutSeconds: 5}, logr.Discard())
+	d.Start(context.Background())
+	defer func() {
+		assert.NoError(t, d.Stop(context.Background()))
+	}()
+
+	b.Publish(events.ArticleCreated, types.Article{Id: "other", ArticleKey: types.ArticleKey{TeamId: "t8"}})
+	b.Publish(events.ArticleCreated, types.Article{Id: "mine", ArticleKey: types.ArticleKey{TeamId: "t94"}})
+	select {
+	case a := <-received:
+		assert.Equal(t, types.ArticleId("mine"), a.Id)
+	case <-time.After(5 * time.Second):
+		assert.Fail(t, "webhook was not delivered")
+	}
+
+	assert.Eventually(t, func() bool {
+		deliveries, err := ws.ListDeliveries(context.Background(), "s1", 0)
+		return err == nil && len(deliveries) == 2
+	}, time.Second, 10*time.Millisecond)
+	deliveries, _ := ws.ListDeliveries(context.Background(), "s1", 0)
+	assert.True(t, deliveries[0].Succeeded)
+	assert.Equal(t, 2, deliveries[0].Attempt)
+	assert.False(t, deliveries[1].Succeeded)
+	assert.Equal(t, http.StatusServiceUnavailable, deliveries[1].StatusCode)
+}
+
+func TestDispatcherStopWaitsForDeliveries(t *testing.T) {
+	allowLoopback(t)
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	ws := memory.NewMemWebhookStorage(10)
+	assert.NoError(t, ws.CreateSubscription(context.Background(), types.WebhookSubscription{Id: "s1", URL: server.URL, Secret: "secret"}))
+	b := events.NewBroker(10)
+	d := NewDispatcher(b, ws, Config{MaxAttempts: 1, TimeoutSeconds: 5}, logr.Discard())
+	d.Start(context.Background())
+
+	// event published right after start is delivered and Stop waits for its delivery
+	b.Publish(events.ArticleCreated, types.Article{Id: "mine"})
+	time.AfterFunc(50*time.Millisecond, func() {
+		close(release)
+	})
+	assert.NoError(t, d.Stop(context.Background()))
+	deliveries, err := ws.ListDeliveries(context.Background(), "s1", 0)
+	assert.NoError(t, err)
+	if assert.Len(t, deliveries, 1) {
+		assert.True(t, deliveries[0].Succeeded)
+	}
+}
+
+func TestDispatcherStopCancelsDeliveries(t *testing.T) {
+	allowLoopback(t)
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+	ws := memory.NewMemWebhookStorage(10)
+	assert.NoError(t, ws.CreateSubscription(context.Background(), types.WebhookSubscription{Id: "s1", URL: server.URL, Secret: "secret"}))
+	b := events.NewBroker(10)
+	d := NewDispatcher(b, ws, Config{MaxAttempts: 1, TimeoutSeconds: 5}, logr.Discard())
+	d.Start(context.Background())
+
+	b.Publish(events.ArticleCreated, types.Article{Id: "mine"})
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, d.Stop(ctx), context.DeadlineExceeded)
+	deliveries, err := ws.ListDeliveries(context.Background(), "s1", 0)
+	assert.NoError(t, err)
+	if assert.Len(t, deliveries, 1, "cancelled delivery should be saved before Stop returns") {
+		assert.False(t, deliveries[0].Succeeded)
+	}
+}
+
+// deliverOnce publishes article to subscription of url and returns its only delivery after dispatcher stops
+func deliverOnce(t *testing.T, url string) types.WebhookDelivery {
+	ws := memory.NewMemWebhookStorage(10)
+	assert.NoError(t, ws.CreateSubscription(context.Background(), types.WebhookSubscription{Id: "s1", URL: url, Secret: "secret"}))
+	b := events.NewBroker(10)
+	d := NewDispatcher(b, ws, Config{MaxAttempts: 1, TimeoutSeconds: 5}, logr.Discard())
+	d.Start(context.Background())
+	b.Publish(events.ArticleCreated, types.Article{Id: "mine"})
+	assert.NoError(t, d.Stop(context.Background()))
+	deliveries, err := ws.ListDeliveries(context.Background(), "s1", 0)
+	assert.NoError(t, err)
+	if !assert.Len(t, deliveries, 1) {
+		return types.WebhookDelivery{}
+	}
+	return deliveries[0]
+}
+
+func TestDispatcherRefusesPrivateAddress(t *testing.T) {
+	called := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+	defer server.Close()
+
+	delivery := deliverOnce(t, server.URL)
+	assert.False(t, delivery.Succeeded)
+	assert.Contains(t, delivery.Error, ForbiddenAddress.Error())
+	assert.False(t, called, "loopback address should be refused before connecting")
+}
+
+func TestDispatcherDoesNotFollowRedirects(t *testing.T) {
+	allowLoopback(t)
+	followed := false
+	mux := http.NewServeMux()
+	mux.HandleFunc("/hook", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/internal", http.StatusTemporaryRedirect)
+	})
+	mux.HandleFunc("/internal", func(w http.ResponseWriter, r *http.Request) {
+		followed = true
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	delivery := deliverOnce(t, server.URL+"/hook")
+	assert.False(t, delivery.Succeeded)
+	assert.Equal(t, http.StatusTemporaryRedirect, delivery.StatusCode)
+	assert.False(t, followed)
+}
+
+func TestConfigValidate(t *testing.T) {
+	valid := Config{MaxAttempts: 5, InitialBackoffSeconds: 10, TimeoutSeconds: 10, DeliveryLogSize: 100}
+	assert.NoError(t, valid.Validate())
+	for name, change := range map[string]func(c *Config){
+		"no attempts":       func(c *Config) { c.MaxAttempts = 0 },
+		"negative backoff":  func(c *Config) { c.InitialBackoffSeconds = -1 },
+		"no timeout":        func(c *Config) { c.TimeoutSeconds = 0 },
+		"negative log size": func(c *Config) { c.DeliveryLogSize = -1 },
+	} {
+		c := valid
+		change(&c)
+		assert.Error(t, c.Validate(), name)
+	}
+}
@@ -0,0 +1,18 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"github.com/adamdyszy/sportsnews/types"
+)
+
+// BackfillStorage keeps progress of historical backfill, so it resumes where it stopped
+type BackfillStorage interface {
+	// GetBackfillProgress returns saved progress of the team, BackfillProgressNotFound is returned when there is none
+	GetBackfillProgress(ctx context.Context, teamId string) (types.BackfillProgress, error)
+	// SaveBackfillProgress creates or overwrites progress of its team
+	SaveBackfillProgress(ctx context.Context, progress types.BackfillProgress) error
+	Disconnect() error
+}
+
+var BackfillProgressNotFound = errors.New("backfill progress not found")
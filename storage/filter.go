@@ -0,0 +1,79 @@
+package storage
+
+import (
+	"github.com/adamdyszy/sportsnews/types"
+	"time"
+)
+
+/*
+ArticleFilter narrows articles returned by ArticleReader.ListPage.
+
+Zero value of a field means it is not used for filtering,
+so zero ArticleFilter matches every article.
+*/
+type ArticleFilter struct {
+	// Type is taxonomy that has to be one of article types
+	Type        string
+	TeamId      string
+	OptaMatchId string
+	// PublishedAfter matches articles published strictly after given time
+	PublishedAfter time.Time
+	// PublishedBefore matches articles published strictly before given time
+	PublishedBefore time.Time
+	HasDetails      *bool
+	Pinned          *bool
+	// Retracted matches tombstones of articles withdrawn upstream
+	Retracted *bool
+	// Ids matches articles with one of the ids, empty matches any
+	Ids []types.ArticleId
+}
+
+// Matches tells if article passes the filter, backends that cannot push filter down to the database can use it
+func (f ArticleFilter) Matches(a types.Article) bool {
+	if f.Type != "" && !containsString(a.Type, f.Type) {
+		return false
+	}
+	if f.TeamId != "" && a.TeamId != f.TeamId {
+		return false
+	}
+	if f.OptaMatchId != "" && a.OptaMatchId != f.OptaMatchId {
+		return false
+	}
+	if !f.PublishedAfter.IsZero() && !a.Published.After(f.PublishedAfter) {
+		return false
+	}
+	if !f.PublishedBefore.IsZero() && !a.Published.Before(f.PublishedBefore) {
+		return false
+	}
+	if f.HasDetails != nil && a.HasDetails != *f.HasDetails {
+		return false
+	}
+	if f.Pinned != nil && a.Pinned != *f.Pinned {
+		return false
+	}
+	if f.Retracted != nil && (a.RetractedAt != nil) != *f.Retracted {
+		return false
+	}
+	if len(f.Ids) > 0 && !containsId(f.Ids, a.Id) {
+		return false
+	}
+	return true
+}
+
+func containsId(ids []types.ArticleId, id types.ArticleId) bool {
+	for _, v := range ids {
+		if v == id {
+			return true
+		}
+	}
+	return false
+}
+
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,95 @@
+package storage
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/adamdyszy/sportsnews/types"
+)
+
+// PageQuery describes which page of articles should be returned by ArticleReader.ListPage
+type PageQuery struct {
+	// Limit is maximum amount of articles in page, it has to be positive
+	Limit int
+	// Cursor is opaque value taken from ArticlePage.NextCursor, empty means first page
+	Cursor string
+	// Sort is order of articles, empty means DefaultSortOrder, has to stay the same between pages
+	Sort SortOrder
+	// Filter selects which articles are listed, it should stay the same between pages
+	Filter ArticleFilter
+}
+
+// ArticlePage is single page of articles returned by ArticleReader.ListPage
+type ArticlePage struct {
+	Articles   []types.Article
+	NextCursor string
+	HasMore    bool
+	Sort       SortOrder
+}
+
+var InvalidPageQuery = errors.New("invalid page query")
+
+/*
+Cursor is decoded form of opaque cursor passed between pages.
+
+It points to the last article returned in previous page,
+so next page starts right after it in the same sort order.
+*/
+type Cursor struct {
+	Sort   SortOrder       `json:"s"`
+	Key    string          `json:"k"`
+	LastId types.ArticleId `json:"id"`
+}
+
+// NewCursor creates cursor pointing right after the given article
+func NewCursor(sort SortOrder, a types.Article) Cursor {
+	return Cursor{Sort: sort, Key: sort.SortKey(a), LastId: a.Id}
+}
+
+// Encode returns opaque string form of the cursor
+func (c Cursor) Encode() string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// DecodeCursor parses cursor created by Cursor.Encode, empty string gives zero Cursor
+func DecodeCursor(s string) (Cursor, error) {
+	var c Cursor
+	if s == "" {
+		return c, nil
+	}
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, fmt.Errorf("%w: cursor is not valid base64", InvalidPageQuery)
+	}
+	err = json.Unmarshal(b, &c)
+	if err != nil || c.LastId == "" {
+		return c, fmt.Errorf("%w: cursor has unknown format", InvalidPageQuery)
+	}
+	return c, nil
+}
+
+/*
+Validate checks if query can be used for listing and returns its decoded cursor.
+
+Empty Sort is replaced with DefaultSortOrder.
+*/
+func (q *PageQuery) Validate() (Cursor, error) {
+	if q.Limit <= 0 {
+		return Cursor{}, fmt.Errorf("%w: limit has to be positive, got %v", InvalidPageQuery, q.Limit)
+	}
+	var err error
+	q.Sort, err = ParseSortOrder(string(q.Sort))
+	if err != nil {
+		return Cursor{}, err
+	}
+	c, err := DecodeCursor(q.Cursor)
+	if err != nil {
+		return Cursor{}, err
+	}
+	if q.Cursor != "" && c.Sort != q.Sort {
+		return Cursor{}, fmt.Errorf("%w: cursor was created for sort %q, not %q", InvalidPageQuery, c.Sort, q.Sort)
+	}
+	return c, nil
+}
@@ -0,0 +1,22 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"github.com/adamdyszy/sportsnews/types"
+)
+
+// RetryStorage keeps news ids whose details could not be polled, one retry for every team and news id
+type RetryStorage interface {
+	// GetRetry returns retry of the news id, RetryNotFound is returned when there is none
+	GetRetry(ctx context.Context, teamId string, newsId string) (types.DetailsRetry, error)
+	// SaveRetry creates or overwrites retry of its news id
+	SaveRetry(ctx context.Context, retry types.DetailsRetry) error
+	// DeleteRetry removes retry of the news id, RetryNotFound is returned when there is none
+	DeleteRetry(ctx context.Context, teamId string, newsId string) error
+	// ListRetries returns retries of the team ordered by last attempt, empty teamId means all teams
+	ListRetries(ctx context.Context, teamId string) ([]types.DetailsRetry, error)
+	Disconnect() error
+}
+
+var RetryNotFound = errors.New("details retry not found")
@@ -0,0 +1,42 @@
+package storage
+
+import (
+	"fmt"
+	"github.com/adamdyszy/sportsnews/types"
+	"strings"
+)
+
+// SearchQuery describes full-text search done by ArticleReader.Search
+type SearchQuery struct {
+	// Text is searched in title, teaser and content of articles, any of its words can match
+	Text string
+	// Limit is maximum amount of returned results, it has to be positive
+	Limit int
+}
+
+// SearchResult is single article found by ArticleReader.Search, higher Score means better match
+type SearchResult struct {
+	Article types.Article
+	Score   float64
+}
+
+/*
+Search weights of article fields, match in title is worth more than match in content.
+Every backend should use them so results are comparable.
+*/
+const (
+	SearchWeightTitle   = 10
+	SearchWeightTeaser  = 5
+	SearchWeightContent = 1
+)
+
+// Validate checks if query can be used for searching
+func (q SearchQuery) Validate() error {
+	if strings.TrimSpace(q.Text) == "" {
+		return fmt.Errorf("%w: search text cannot be empty", InvalidPageQuery)
+	}
+	if q.Limit <= 0 {
+		return fmt.Errorf("%w: limit has to be positive, got %v", InvalidPageQuery, q.Limit)
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package storage
+
+import (
+	"fmt"
+	"github.com/adamdyszy/sportsnews/types"
+	"strings"
+	"time"
+)
+
+/*
+SortOrder tells how articles in ArticleReader.ListPage should be ordered.
+
+It is a name of the field, optionally prefixed with "-" for descending order.
+Articles with the same value of the field are ordered by their id in the same direction.
+*/
+type SortOrder string
+
+const (
+	SortByPublished     SortOrder = "published"
+	SortByPublishedDesc SortOrder = "-published"
+	SortByTitle         SortOrder = "title"
+	SortByTeamId        SortOrder = "teamId"
+	// DefaultSortOrder is used when PageQuery has no Sort, newest articles come first
+	DefaultSortOrder = SortByPublishedDesc
+)
+
+const (
+	SortFieldPublished = "published"
+	SortFieldTitle     = "title"
+	SortFieldTeamId    = "teamId"
+)
+
+// sortKeyTimeLayout has fixed width, so sort keys of published dates can be compared as strings
+const sortKeyTimeLayout = "2006-01-02T15:04:05.000000000Z"
+
+// ParseSortOrder validates sort order taken from the user, empty string gives DefaultSortOrder
+func ParseSortOrder(s string) (SortOrder, error) {
+	if s == "" {
+		return DefaultSortOrder, nil
+	}
+	switch strings.TrimPrefix(s, "-") {
+	case SortFieldPublished, SortFieldTitle, SortFieldTeamId:
+		return SortOrder(s), nil
+	}
+	return "", fmt.Errorf("%w: unknown sort order %q", InvalidPageQuery, s)
+}
+
+// Field returns name of the field articles are sorted by
+func (o SortOrder) Field() string {
+	return strings.TrimPrefix(string(o), "-")
+}
+
+// Descending tells if articles should be ordered from the biggest value
+func (o SortOrder) Descending() bool {
+	return strings.HasPrefix(string(o), "-")
+}
+
+// SortKey returns value of the sort field in article as string that keeps the order when compared
+func (o SortOrder) SortKey(a types.Article) string {
+	switch o.Field() {
+	case SortFieldPublished:
+		return a.Published.UTC().Format(sortKeyTimeLayout)
+	case SortFieldTitle:
+		return a.Title
+	case SortFieldTeamId:
+		return a.TeamId
+	}
+	return ""
+}
+
+// ParsePublishedSortKey turns sort key created for SortFieldPublished back into time
+func ParsePublishedSortKey(key string) (time.Time, error) {
+	t, err := time.Parse(sortKeyTimeLayout, key)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: cursor has invalid published date", InvalidPageQuery)
+	}
+	return t, nil
+}
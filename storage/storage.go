@@ -1,30 +1,59 @@
 package storage
 
 import (
+	"context"
 	"errors"
 	"github.com/adamdyszy/sportsnews/types"
+	"time"
 )
 
+/*
+ArticleStorage keeps articles.
+
+Every method takes context of the caller as the first argument,
+when it is cancelled or its deadline passes the call is aborted.
+*/
 type ArticleStorage interface {
 	ArticleReader
 	ArticleWriter
+	// Ping checks that storage is reachable
+	Ping(ctx context.Context) error
 	Disconnect() error
 }
 
 type ArticleReader interface {
-	Get(types.ArticleId) (types.Article, error)
-	GetNewsWithoutDetailsIDs() ([]string, error)
-	List() ([]types.Article, error)
+	Get(ctx context.Context, id types.ArticleId) (types.Article, error)
+	// GetNewsWithoutDetailsIDs returns news ids of team articles that have no details yet and are not retracted, empty teamId means all teams
+	GetNewsWithoutDetailsIDs(ctx context.Context, teamId string) ([]string, error)
+	List(ctx context.Context) ([]types.Article, error)
+	// ListPage returns single page of articles in PageQuery.Sort order, next pages are got with ArticlePage.NextCursor
+	ListPage(ctx context.Context, q PageQuery) (ArticlePage, error)
+	// Count returns how many articles match the filter
+	Count(ctx context.Context, f ArticleFilter) (int, error)
+	// Search returns articles matching SearchQuery.Text ordered from the best match, retracted articles are never returned
+	Search(ctx context.Context, q SearchQuery) ([]SearchResult, error)
+	// ListRevisions returns earlier revisions of the article oldest first, without the current one
+	ListRevisions(ctx context.Context, id types.ArticleId) ([]types.ArticleRevision, error)
 }
 
 var ArticleNotFound = errors.New("article not found")
 
 type ArticleWriter interface {
-	// Write takes article to save
-	Write(types.Article) error
-	// Delete takes articleID and tries to delete it from the storage
-	Delete(id types.ArticleId) error
+	// Write takes article to save, only article without details that is not retracted can be replaced by the one with details
+	Write(ctx context.Context, a types.Article) error
+	// Replace saves article in place of stored one with the same id, even when it has details,
+	// stored article is kept as revision and its pinning is carried over, ArticleNotFound is returned when there is none
+	Replace(ctx context.Context, a types.Article) error
+	// Delete takes articleID and tries to delete it from the storage together with its revisions
+	Delete(ctx context.Context, id types.ArticleId) error
+	// DeleteUnpinned deletes article like Delete, unless it is pinned, then ArticlePinned is returned
+	DeleteUnpinned(ctx context.Context, id types.ArticleId) error
+	// SetPinned marks article as pinned or not, pinning is kept when article is written again with details
+	SetPinned(ctx context.Context, id types.ArticleId, pinned bool) error
+	// SetUpdatedAt sets last update of stored article without keeping revision, it fills it in articles stored before it was kept
+	SetUpdatedAt(ctx context.Context, id types.ArticleId, updatedAt time.Time) error
 }
 
 var ArticleAlreadyExists = errors.New("tried to write to already existing article id")
 var ArticleWriteFailed = errors.New("could not write article")
+var ArticlePinned = errors.New("article is pinned")
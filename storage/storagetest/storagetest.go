@@ -0,0 +1,558 @@
+/*
+Package storagetest checks that storage.ArticleStorage implementation follows the contract of the interface.
+
+Backend calls Run from its tests with function creating empty storage:
+
+	func TestConformance(t *testing.T) {
+		storagetest.Run(t, func(t *testing.T) storage.ArticleStorage {
+			return NewMemStorage()
+		})
+	}
+*/
+package storagetest
+
+import (
+	"context"
+	"fmt"
+	"github.com/adamdyszy/sportsnews/storage"
+	"github.com/adamdyszy/sportsnews/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// NewStorage returns empty storage, it is called for every subtest and the storage is disconnected after it
+type NewStorage func(t *testing.T) storage.ArticleStorage
+
+// Run runs all conformance tests against storages created by newStorage
+func Run(t *testing.T, newStorage NewStorage) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, s storage.ArticleStorage)
+	}{
+		{"WriteAndGet", testWriteAndGet},
+		{"UpgradeWithDetails", testUpgradeWithDetails},
+		{"UpgradeClearsFields", testUpgradeClearsFields},
+		{"DuplicateRejected", testDuplicateRejected},
+		{"ListPageSort", testListPageSort},
+		{"Delete", testDelete},
+		{"NotFound", testNotFound},
+		{"Pinned", testPinned},
+		{"SetUpdatedAt", testSetUpdatedAt},
+		{"ListPageByIds", testListPageByIds},
+		{"Count", testCount},
+		{"DeleteUnpinned", testDeleteUnpinned},
+		{"ReplaceKeepsRevisions", testReplaceKeepsRevisions},
+		{"Retracted", testRetracted},
+		{"NewsWithoutDetailsPerTeam", testNewsWithoutDetailsPerTeam},
+		{"ConcurrentWrites", testConcurrentWrites},
+		{"ConcurrentDuplicates", testConcurrentDuplicates},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var s storage.ArticleStorage
+			// registered first so it runs after cleanups registered by newStorage
+			t.Cleanup(func() {
+				if s != nil {
+					assert.NoError(t, s.Disconnect())
+				}
+			})
+			s = newStorage(t)
+			tt.test(t, s)
+		})
+	}
+}
+
+// NewArticle creates article of the team with generated id, published time is derived from newsId
+func NewArticle(t *testing.T, teamId string, newsId int, hasDetails bool) types.Article {
+	a := types.Article{
+		ArticleKey: types.ArticleKey{
+			TeamId:    teamId,
+			NewsId:    fmt.Sprint(newsId),
+			Published: time.Date(2023, 2, 17, 0, 0, 0, 0, time.UTC).Add(time.Duration(newsId) * time.Minute),
+		},
+		Title:      fmt.Sprintf("Title %v", newsId),
+		Teaser:     fmt.Sprintf("Teaser %v", newsId),
+		HasDetails: hasDetails,
+	}
+	a.UpdatedAt = a.Published
+	if hasDetails {
+		a.Content = fmt.Sprintf("Content %v", newsId)
+	}
+	require.NoError(t, a.SetGeneratedId())
+	return a
+}
+
+// assertSameArticle compares articles field by field, storages may return published time in other location
+func assertSameArticle(t *testing.T, want types.Article, got types.Article) {
+	assert.Equal(t, want.Id, got.Id)
+	assert.Equal(t, want.TeamId, got.TeamId)
+	assert.Equal(t, want.NewsId, got.NewsId)
+	assert.True(t, want.Published.Equal(got.Published), "published %v should equal %v", got.Published, want.Published)
+	assert.Equal(t, want.Title, got.Title)
+	assert.Equal(t, want.Teaser, got.Teaser)
+	assert.Equal(t, want.Content, got.Content)
+	assert.Equal(t, want.HasDetails, got.HasDetails)
+	assert.True(t, want.UpdatedAt.Equal(got.UpdatedAt), "updatedAt %v should equal %v", got.UpdatedAt, want.UpdatedAt)
+}
+
+func testWriteAndGet(t *testing.T, s storage.ArticleStorage) {
+	ctx := context.Background()
+	a := NewArticle(t, "t94", 1, false)
+	require.NoError(t, s.Write(ctx, a))
+
+	got, err := s.Get(ctx, a.Id)
+	require.NoError(t, err)
+	assertSameArticle(t, a, got)
+
+	list, err := s.List(ctx)
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+	assertSameArticle(t, a, list[0])
+
+	ids, err := s.GetNewsWithoutDetailsIDs(ctx, "")
+	require.NoError(t, err)
+	assert.Equal(t, []string{a.NewsId}, ids)
+}
+
+func testUpgradeWithDetails(t *testing.T, s storage.ArticleStorage) {
+	ctx := context.Background()
+	a := NewArticle(t, "t94", 1, false)
+	require.NoError(t, s.Write(ctx, a))
+	detailed := NewArticle(t, "t94", 1, true)
+	require.NoError(t, s.Write(ctx, detailed))
+
+	got, err := s.Get(ctx, a.Id)
+	require.NoError(t, err)
+	assertSameArticle(t, detailed, got)
+	list, err := s.List(ctx)
+	require.NoError(t, err)
+	assert.Len(t, list, 1, "upgraded article should replace the old one")
+	ids, err := s.GetNewsWithoutDetailsIDs(ctx, "")
+	require.NoError(t, err)
+	assert.Nil(t, ids)
+
+	// article with details can be written even when there was no article without them
+	other := NewArticle(t, "t94", 2, true)
+	require.NoError(t, s.Write(ctx, other))
+	got, err = s.Get(ctx, other.Id)
+	require.NoError(t, err)
+	assertSameArticle(t, other, got)
+}
+
+func testUpgradeClearsFields(t *testing.T, s storage.ArticleStorage) {
+	ctx := context.Background()
+	a := NewArticle(t, "t94", 1, false)
+	a.ImageURL = "https://example.com/image.png"
+	a.Type = []string{"News"}
+	require.NoError(t, s.Write(ctx, a))
+	require.NoError(t, s.SetPinned(ctx, a.Id, true))
+	// fields missing from the version with details were cleared upstream, they are not kept
+	detailed := NewArticle(t, "t94", 1, true)
+	detailed.Teaser = ""
+	require.NoError(t, s.Write(ctx, detailed))
+
+	got, err := s.Get(ctx, a.Id)
+	require.NoError(t, err)
+	assertSameArticle(t, detailed, got)
+	assert.Empty(t, got.ImageURL)
+	assert.Empty(t, got.Type)
+	assert.True(t, got.Pinned, "pinning should be kept")
+}
+
+func testDuplicateRejected(t *testing.T, s storage.ArticleStorage) {
+	ctx := context.Background()
+	a := NewArticle(t, "t94", 1, false)
+	require.NoError(t, s.Write(ctx, a))
+	assert.ErrorIs(t, s.Write(ctx, a), storage.ArticleAlreadyExists)
+
+	detailed := NewArticle(t, "t94", 1, true)
+	require.NoError(t, s.Write(ctx, detailed))
+	assert.ErrorIs(t, s.Write(ctx, a), storage.ArticleAlreadyExists, "article without details should not replace detailed one")
+	changed := detailed
+	changed.Content = "Changed content"
+	assert.ErrorIs(t, s.Write(ctx, changed), storage.ArticleAlreadyExists, "detailed article should not be replaced")
+
+	got, err := s.Get(ctx, a.Id)
+	require.NoError(t, err)
+	assertSameArticle(t, detailed, got)
+}
+
+// listAllPages follows cursors of ListPage until the last page
+func listAllPages(t *testing.T, s storage.ArticleStorage, q storage.PageQuery) []types.Article {
+	var got []types.Article
+	for {
+		page, err := s.ListPage(context.Background(), q)
+		require.NoError(t, err)
+		got = append(got, page.Articles...)
+		if !page.HasMore {
+			assert.Empty(t, page.NextCursor)
+			return got
+		}
+		require.Len(t, page.Articles, q.Limit)
+		q.Cursor = page.NextCursor
+	}
+}
+
+func testListPageSort(t *testing.T, s storage.ArticleStorage) {
+	ctx := context.Background()
+	var articles []types.Article
+	for newsId := 1; newsId <= 9; newsId++ {
+		a := NewArticle(t, fmt.Sprintf("t%v", newsId%3), newsId, false)
+		switch {
+		case newsId > 6:
+			// untitled articles have to be paged too
+			a.Title = ""
+		case newsId > 3:
+			// same title, so order falls back to ids
+			a.Title = "Same"
+		}
+		require.NoError(t, s.Write(ctx, a))
+		articles = append(articles, a)
+	}
+
+	for _, sort := range []storage.SortOrder{"published", "-published", "title", "-title", "teamId", "-teamId"} {
+		got := listAllPages(t, s, storage.PageQuery{Limit: 2, Sort: sort})
+		require.Len(t, got, len(articles), sort)
+		for n := 1; n < len(got); n++ {
+			prev := storage.NewCursor(sort, got[n-1])
+			next := storage.NewCursor(sort, got[n])
+			if sort.Descending() {
+				prev, next = next, prev
+			}
+			assert.True(t, prev.Key < next.Key || prev.Key == next.Key && prev.LastId < next.LastId,
+				"sort %v is not kept at position %v", sort, n)
+		}
+	}
+}
+
+func testDelete(t *testing.T, s storage.ArticleStorage) {
+	ctx := context.Background()
+	a := NewArticle(t, "t94", 1, false)
+	kept := NewArticle(t, "t94", 2, false)
+	require.NoError(t, s.Write(ctx, a))
+	require.NoError(t, s.Write(ctx, kept))
+
+	require.NoError(t, s.Delete(ctx, a.Id))
+	_, err := s.Get(ctx, a.Id)
+	assert.ErrorIs(t, err, storage.ArticleNotFound)
+	list, err := s.List(ctx)
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+	assert.Equal(t, kept.Id, list[0].Id)
+	page, err := s.ListPage(ctx, storage.PageQuery{Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, page.Articles, 1)
+	assert.Equal(t, kept.Id, page.Articles[0].Id)
+	results, err := s.Search(ctx, storage.SearchQuery{Text: "title", Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, kept.Id, results[0].Article.Id)
+	ids, err := s.GetNewsWithoutDetailsIDs(ctx, "")
+	require.NoError(t, err)
+	assert.Equal(t, []string{kept.NewsId}, ids)
+
+	// deleted article can be written again
+	require.NoError(t, s.Write(ctx, a))
+	// deleting missing article is not an error
+	require.NoError(t, s.Delete(ctx, a.Id))
+	assert.NoError(t, s.Delete(ctx, a.Id))
+}
+
+func testNotFound(t *testing.T, s storage.ArticleStorage) {
+	ctx := context.Background()
+	_, err := s.Get(ctx, "missing")
+	assert.ErrorIs(t, err, storage.ArticleNotFound)
+
+	list, err := s.List(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, list)
+	ids, err := s.GetNewsWithoutDetailsIDs(ctx, "")
+	require.NoError(t, err)
+	assert.Nil(t, ids, "no news without details should be nil")
+}
+
+func testPinned(t *testing.T, s storage.ArticleStorage) {
+	ctx := context.Background()
+	assert.ErrorIs(t, s.SetPinned(ctx, "missing", true), storage.ArticleNotFound)
+	a := NewArticle(t, "t94", 1, false)
+	other := NewArticle(t, "t94", 2, false)
+	require.NoError(t, s.Write(ctx, a))
+	require.NoError(t, s.Write(ctx, other))
+	require.NoError(t, s.SetPinned(ctx, a.Id, true))
+
+	got, err := s.Get(ctx, a.Id)
+	require.NoError(t, err)
+	assert.True(t, got.Pinned)
+	pinned := true
+	page, err := s.ListPage(ctx, storage.PageQuery{Limit: 10, Filter: storage.ArticleFilter{Pinned: &pinned}})
+	require.NoError(t, err)
+	require.Len(t, page.Articles, 1)
+	assert.Equal(t, a.Id, page.Articles[0].Id)
+	notPinned := false
+	page, err = s.ListPage(ctx, storage.PageQuery{Limit: 10, Filter: storage.ArticleFilter{Pinned: &notPinned}})
+	require.NoError(t, err)
+	require.Len(t, page.Articles, 1)
+	assert.Equal(t, other.Id, page.Articles[0].Id)
+
+	// pinning is kept when article gets details
+	require.NoError(t, s.Write(ctx, NewArticle(t, "t94", 1, true)))
+	got, err = s.Get(ctx, a.Id)
+	require.NoError(t, err)
+	assert.True(t, got.Pinned)
+	assert.True(t, got.HasDetails)
+
+	require.NoError(t, s.SetPinned(ctx, a.Id, false))
+	got, err = s.Get(ctx, a.Id)
+	require.NoError(t, err)
+	assert.False(t, got.Pinned)
+}
+
+func testSetUpdatedAt(t *testing.T, s storage.ArticleStorage) {
+	ctx := context.Background()
+	assert.ErrorIs(t, s.SetUpdatedAt(ctx, "missing", time.Now()), storage.ArticleNotFound)
+	a := NewArticle(t, "t94", 1, true)
+	// article stored before last update was kept
+	a.UpdatedAt = time.Time{}
+	require.NoError(t, s.Write(ctx, a))
+
+	a.UpdatedAt = a.Published.Add(time.Hour)
+	require.NoError(t, s.SetUpdatedAt(ctx, a.Id, a.UpdatedAt))
+	got, err := s.Get(ctx, a.Id)
+	require.NoError(t, err)
+	assertSameArticle(t, a, got)
+	revisions, err := s.ListRevisions(ctx, a.Id)
+	require.NoError(t, err)
+	assert.Empty(t, revisions)
+}
+
+func testListPageByIds(t *testing.T, s storage.ArticleStorage) {
+	ctx := context.Background()
+	var ids []types.ArticleId
+	for n := 1; n <= 4; n++ {
+		a := NewArticle(t, "t94", n, false)
+		require.NoError(t, s.Write(ctx, a))
+		if n%2 == 0 {
+			ids = append(ids, a.Id)
+		}
+	}
+	got := listAllPages(t, s, storage.PageQuery{Limit: 1, Filter: storage.ArticleFilter{Ids: append(ids, "missing")}})
+	var gotIds []types.ArticleId
+	for _, a := range got {
+		gotIds = append(gotIds, a.Id)
+	}
+	assert.ElementsMatch(t, ids, gotIds)
+}
+
+func testCount(t *testing.T, s storage.ArticleStorage) {
+	ctx := context.Background()
+	count, err := s.Count(ctx, storage.ArticleFilter{})
+	require.NoError(t, err)
+	assert.Equal(t, 0, count)
+	for n := 1; n <= 3; n++ {
+		require.NoError(t, s.Write(ctx, NewArticle(t, "t94", n, n == 1)))
+	}
+	require.NoError(t, s.Write(ctx, NewArticle(t, "t8", 4, false)))
+
+	count, err = s.Count(ctx, storage.ArticleFilter{})
+	require.NoError(t, err)
+	assert.Equal(t, 4, count)
+	notDetailed := false
+	count, err = s.Count(ctx, storage.ArticleFilter{TeamId: "t94", HasDetails: &notDetailed})
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+}
+
+func testReplaceKeepsRevisions(t *testing.T, s storage.ArticleStorage) {
+	ctx := context.Background()
+	assert.ErrorIs(t, s.Replace(ctx, NewArticle(t, "t94", 1, true)), storage.ArticleNotFound)
+	_, err := s.ListRevisions(ctx, "missing")
+	assert.ErrorIs(t, err, storage.ArticleNotFound)
+
+	first := NewArticle(t, "t94", 1, true)
+	require.NoError(t, s.Write(ctx, first))
+	require.NoError(t, s.SetPinned(ctx, first.Id, true))
+	revisions, err := s.ListRevisions(ctx, first.Id)
+	require.NoError(t, err)
+	assert.Empty(t, revisions)
+
+	second := first
+	second.Content = "Corrected content"
+	require.NoError(t, s.Replace(ctx, second))
+	// edited article waiting for details again
+	third := NewArticle(t, "t94", 1, false)
+	third.Title = "Corrected title"
+	require.NoError(t, s.Replace(ctx, third))
+
+	got, err := s.Get(ctx, first.Id)
+	require.NoError(t, err)
+	assertSameArticle(t, third, got)
+	assert.True(t, got.Pinned, "pinning should be carried over")
+	ids, err := s.GetNewsWithoutDetailsIDs(ctx, "")
+	require.NoError(t, err)
+	assert.Equal(t, []string{third.NewsId}, ids)
+	list, err := s.List(ctx)
+	require.NoError(t, err)
+	assert.Len(t, list, 1)
+
+	revisions, err = s.ListRevisions(ctx, first.Id)
+	require.NoError(t, err)
+	require.Len(t, revisions, 2)
+	for n, want := range []types.Article{first, second} {
+		assert.Equal(t, n+1, revisions[n].Number)
+		assert.NotNil(t, revisions[n].ReplacedAt)
+		assertSameArticle(t, want, revisions[n].Article)
+	}
+
+	// deleted article loses its revisions
+	require.NoError(t, s.Delete(ctx, first.Id))
+	require.NoError(t, s.Write(ctx, first))
+	revisions, err = s.ListRevisions(ctx, first.Id)
+	require.NoError(t, err)
+	assert.Empty(t, revisions)
+}
+
+func testRetracted(t *testing.T, s storage.ArticleStorage) {
+	ctx := context.Background()
+	a := NewArticle(t, "t94", 1, false)
+	kept := NewArticle(t, "t94", 2, false)
+	require.NoError(t, s.Write(ctx, a))
+	require.NoError(t, s.Write(ctx, kept))
+	tombstone := a
+	retractedAt := a.Published.Add(time.Hour)
+	tombstone.RetractedAt = &retractedAt
+	require.NoError(t, s.Replace(ctx, tombstone))
+
+	got, err := s.Get(ctx, a.Id)
+	require.NoError(t, err)
+	require.NotNil(t, got.RetractedAt)
+	assert.True(t, retractedAt.Equal(*got.RetractedAt))
+	ids, err := s.GetNewsWithoutDetailsIDs(ctx, "")
+	require.NoError(t, err)
+	assert.Equal(t, []string{kept.NewsId}, ids, "retracted article should not wait for details")
+	notRetracted := false
+	page, err := s.ListPage(ctx, storage.PageQuery{Limit: 10, Filter: storage.ArticleFilter{Retracted: &notRetracted}})
+	require.NoError(t, err)
+	require.Len(t, page.Articles, 1)
+	assert.Equal(t, kept.Id, page.Articles[0].Id)
+	retracted := true
+	page, err = s.ListPage(ctx, storage.PageQuery{Limit: 10, Filter: storage.ArticleFilter{Retracted: &retracted}})
+	require.NoError(t, err)
+	require.Len(t, page.Articles, 1)
+	assert.Equal(t, a.Id, page.Articles[0].Id)
+	results, err := s.Search(ctx, storage.SearchQuery{Text: "title", Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, kept.Id, results[0].Article.Id)
+
+	// details do not bring tombstone back
+	assert.ErrorIs(t, s.Write(ctx, NewArticle(t, "t94", 1, true)), storage.ArticleAlreadyExists)
+	got, err = s.Get(ctx, a.Id)
+	require.NoError(t, err)
+	assert.NotNil(t, got.RetractedAt)
+}
+
+func testDeleteUnpinned(t *testing.T, s storage.ArticleStorage) {
+	ctx := context.Background()
+	pinned := NewArticle(t, "t94", 1, false)
+	a := NewArticle(t, "t94", 2, false)
+	require.NoError(t, s.Write(ctx, pinned))
+	require.NoError(t, s.Write(ctx, a))
+	require.NoError(t, s.SetPinned(ctx, pinned.Id, true))
+
+	assert.ErrorIs(t, s.DeleteUnpinned(ctx, pinned.Id), storage.ArticlePinned)
+	_, err := s.Get(ctx, pinned.Id)
+	assert.NoError(t, err, "pinned article should be kept")
+	require.NoError(t, s.DeleteUnpinned(ctx, a.Id))
+	_, err = s.Get(ctx, a.Id)
+	assert.ErrorIs(t, err, storage.ArticleNotFound)
+	// deleting missing article is not an error
+	assert.NoError(t, s.DeleteUnpinned(ctx, a.Id))
+}
+
+func testNewsWithoutDetailsPerTeam(t *testing.T, s storage.ArticleStorage) {
+	ctx := context.Background()
+	// news ids of different teams can be the same
+	require.NoError(t, s.Write(ctx, NewArticle(t, "t94", 1, false)))
+	require.NoError(t, s.Write(ctx, NewArticle(t, "t1", 1, false)))
+	require.NoError(t, s.Write(ctx, NewArticle(t, "t1", 2, false)))
+	require.NoError(t, s.Write(ctx, NewArticle(t, "t1", 2, true)))
+
+	ids, err := s.GetNewsWithoutDetailsIDs(ctx, "t94")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"1"}, ids)
+	ids, err = s.GetNewsWithoutDetailsIDs(ctx, "t1")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"1"}, ids)
+	ids, err = s.GetNewsWithoutDetailsIDs(ctx, "")
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"1", "1"}, ids)
+	ids, err = s.GetNewsWithoutDetailsIDs(ctx, "t2")
+	require.NoError(t, err)
+	assert.Nil(t, ids)
+}
+
+func testConcurrentWrites(t *testing.T, s storage.ArticleStorage) {
+	ctx := context.Background()
+	const amount = 20
+	var wg sync.WaitGroup
+	for n := 0; n < amount; n++ {
+		a := NewArticle(t, "t94", n, false)
+		detailed := NewArticle(t, "t94", n, true)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, s.Write(ctx, a))
+			_, err := s.Get(ctx, a.Id)
+			assert.NoError(t, err)
+			_, err = s.ListPage(ctx, storage.PageQuery{Limit: 5})
+			assert.NoError(t, err)
+			assert.NoError(t, s.Write(ctx, detailed))
+		}()
+	}
+	wg.Wait()
+
+	list, err := s.List(ctx)
+	require.NoError(t, err)
+	assert.Len(t, list, amount)
+	ids, err := s.GetNewsWithoutDetailsIDs(ctx, "")
+	require.NoError(t, err)
+	assert.Nil(t, ids)
+}
+
+// writeConcurrently writes article from many goroutines and returns how many writes succeeded
+func writeConcurrently(t *testing.T, s storage.ArticleStorage, a types.Article) int {
+	const writers = 10
+	var succeeded int32
+	var wg sync.WaitGroup
+	for n := 0; n < writers; n++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := s.Write(context.Background(), a)
+			if err == nil {
+				atomic.AddInt32(&succeeded, 1)
+				return
+			}
+			assert.ErrorIs(t, err, storage.ArticleAlreadyExists)
+		}()
+	}
+	wg.Wait()
+	return int(succeeded)
+}
+
+func testConcurrentDuplicates(t *testing.T, s storage.ArticleStorage) {
+	ctx := context.Background()
+	a := NewArticle(t, "t94", 1, false)
+	assert.Equal(t, 1, writeConcurrently(t, s, a), "article should be written only once")
+	detailed := NewArticle(t, "t94", 1, true)
+	assert.Equal(t, 1, writeConcurrently(t, s, detailed), "article should be upgraded only once")
+
+	list, err := s.List(ctx)
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+	assertSameArticle(t, detailed, list[0])
+}
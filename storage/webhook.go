@@ -0,0 +1,28 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"github.com/adamdyszy/sportsnews/types"
+)
+
+/*
+WebhookStorage keeps webhook subscriptions and log of their deliveries.
+
+Every method takes context of the caller as the first argument like ArticleStorage.
+*/
+type WebhookStorage interface {
+	CreateSubscription(ctx context.Context, s types.WebhookSubscription) error
+	GetSubscription(ctx context.Context, id string) (types.WebhookSubscription, error)
+	ListSubscriptions(ctx context.Context) ([]types.WebhookSubscription, error)
+	// DeleteSubscription removes subscription and its delivery log
+	DeleteSubscription(ctx context.Context, id string) error
+	// AddDelivery appends delivery to the log of its subscription, only last deliveries are kept
+	AddDelivery(ctx context.Context, d types.WebhookDelivery) error
+	// ListDeliveries returns at most limit newest deliveries of subscription, newest first
+	ListDeliveries(ctx context.Context, subscriptionId string, limit int) ([]types.WebhookDelivery, error)
+	Disconnect() error
+}
+
+var SubscriptionNotFound = errors.New("webhook subscription not found")
+var SubscriptionAlreadyExists = errors.New("webhook subscription already exists")
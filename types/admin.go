@@ -0,0 +1,48 @@
+package types
+
+import "time"
+
+// PollSummary counts what happened with polled articles
+type PollSummary struct {
+	Saved   int `json:"saved"`
+	Skipped int `json:"skipped"`
+	Failed  int `json:"failed"`
+}
+
+// Add returns sum of both summaries
+func (s PollSummary) Add(o PollSummary) PollSummary {
+	return PollSummary{
+		Saved:   s.Saved + o.Saved,
+		Skipped: s.Skipped + o.Skipped,
+		Failed:  s.Failed + o.Failed,
+	}
+}
+
+// Statuses of AdminJob
+const (
+	JobRunning  = "running"
+	JobFinished = "finished"
+	JobFailed   = "failed"
+)
+
+// AdminJob is poll requested through admin api, Summary is filled when it is not running anymore
+type AdminJob struct {
+	Id         string       `json:"id"`
+	Kind       string       `json:"kind"`
+	Status     string       `json:"status"`
+	StartedAt  time.Time    `json:"startedAt"`
+	FinishedAt *time.Time   `json:"finishedAt,omitempty"`
+	Summary    *PollSummary `json:"summary,omitempty"`
+	Error      string       `json:"error,omitempty"`
+}
+
+// AdminJobResponse is struct returned by admin api
+type AdminJobResponse struct {
+	Status  string    `json:"status"`
+	Data    *AdminJob `json:"data,omitempty"`
+	Message string    `json:"message,omitempty"`
+}
+
+func (r AdminJobResponse) GetMessage() string {
+	return r.Message
+}
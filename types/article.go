@@ -45,6 +45,8 @@ type ArticleListMetadata struct {
 	CreatedAt  time.Time `json:"createdAt"`
 	TotalItems int       `json:"totalItems,omitempty"`
 	Sort       string    `json:"sort,omitempty"`
+	NextCursor string    `json:"nextCursor,omitempty"`
+	HasMore    bool      `json:"hasMore"`
 }
 
 type ArticleId string
@@ -67,6 +69,12 @@ type Article struct {
 	URL         string    `json:"url,omitempty"`
 	VideoURL    string    `json:"videoUrl,omitempty"`
 	HasDetails  bool      `json:"hasDetails"`
+	// UpdatedAt is the time of the last upstream edit, it is the same as published when article was never edited
+	UpdatedAt time.Time `json:"updatedAt"`
+	// RetractedAt is set when article was withdrawn upstream, such tombstone is not served by API
+	RetractedAt *time.Time `json:"retractedAt,omitempty"`
+	// Pinned articles are never removed by retention
+	Pinned bool `json:"pinned,omitempty"`
 }
 
 // ArticleKey represents fields that are used to generate hash from article.
@@ -0,0 +1,21 @@
+package types
+
+import "time"
+
+/*
+BackfillProgress is saved state of historical backfill of a team.
+
+Backfill walks news ids downward, NextNewsId is the next one to fetch,
+so the walk resumes from it after restart.
+*/
+type BackfillProgress struct {
+	TeamId     string `json:"teamId"`
+	NextNewsId int    `json:"nextNewsId"`
+	Saved      int    `json:"saved"`
+	Skipped    int    `json:"skipped"`
+	// Misses is how many news ids in a row had no news
+	Misses     int       `json:"misses"`
+	Done       bool      `json:"done"`
+	StopReason string    `json:"stopReason,omitempty"`
+	UpdatedAt  time.Time `json:"updatedAt"`
+}
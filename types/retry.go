@@ -0,0 +1,31 @@
+package types
+
+import "time"
+
+/*
+DetailsRetry is news id whose details could not be polled.
+
+It is attempted again by details polling once NextAttemptAt passes, after too many attempts
+it is dead and details polling skips it until it is replayed.
+*/
+type DetailsRetry struct {
+	TeamId        string    `json:"teamId"`
+	NewsId        string    `json:"newsId"`
+	Attempts      int       `json:"attempts"`
+	LastError     string    `json:"lastError"`
+	LastAttemptAt time.Time `json:"lastAttemptAt"`
+	// NextAttemptAt is not set for dead news ids
+	NextAttemptAt *time.Time `json:"nextAttemptAt,omitempty"`
+	Dead          bool       `json:"dead"`
+}
+
+// DetailsRetryList is struct returned by admin api for news ids waiting for retry
+type DetailsRetryList struct {
+	Status  string         `json:"status"`
+	Data    []DetailsRetry `json:"data,omitempty"`
+	Message string         `json:"message,omitempty"`
+}
+
+func (r DetailsRetryList) GetMessage() string {
+	return r.Message
+}
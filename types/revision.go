@@ -0,0 +1,112 @@
+package types
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"time"
+)
+
+/*
+ArticleRevision is single version of the article.
+
+Revisions are numbered from 1 in order they were saved, the last one is the current article.
+*/
+type ArticleRevision struct {
+	Number int `json:"number"`
+	// ReplacedAt is when newer revision replaced this one, current revision has none
+	ReplacedAt *time.Time `json:"replacedAt,omitempty"`
+	Article    Article    `json:"article"`
+}
+
+// ArticleChange is value of single article field that differs between revisions
+type ArticleChange struct {
+	Field string      `json:"field"`
+	From  interface{} `json:"from,omitempty"`
+	To    interface{} `json:"to,omitempty"`
+}
+
+// ArticleDiff lists fields changed between two revisions of the article
+type ArticleDiff struct {
+	Id      ArticleId       `json:"id"`
+	From    int             `json:"from"`
+	To      int             `json:"to"`
+	Changes []ArticleChange `json:"changes"`
+}
+
+// ArticleRevisionList is struct returned by sports news api for revisions of the article
+type ArticleRevisionList struct {
+	Status  string            `json:"status"`
+	Data    []ArticleRevision `json:"data,omitempty"`
+	Message string            `json:"message,omitempty"`
+}
+
+// ArticleRevisionResponse is struct returned by sports news api for single revision of the article
+type ArticleRevisionResponse struct {
+	Status  string           `json:"status"`
+	Data    *ArticleRevision `json:"data,omitempty"`
+	Message string           `json:"message,omitempty"`
+}
+
+// ArticleDiffResponse is struct returned by sports news api for diff of revisions
+type ArticleDiffResponse struct {
+	Status  string       `json:"status"`
+	Data    *ArticleDiff `json:"data,omitempty"`
+	Message string       `json:"message,omitempty"`
+}
+
+func (r ArticleRevisionList) GetMessage() string {
+	return r.Message
+}
+
+func (r ArticleRevisionResponse) GetMessage() string {
+	return r.Message
+}
+
+func (r ArticleDiffResponse) GetMessage() string {
+	return r.Message
+}
+
+/*
+DiffArticles returns fields of article json that differ between from and to, ordered by field name.
+
+Field missing in one of the articles, because it is empty, has no value on that side of the change.
+*/
+func DiffArticles(from Article, to Article) ([]ArticleChange, error) {
+	fromFields, err := articleFields(from)
+	if err != nil {
+		return nil, err
+	}
+	toFields, err := articleFields(to)
+	if err != nil {
+		return nil, err
+	}
+	names := make(map[string]struct{}, len(fromFields))
+	for name := range fromFields {
+		names[name] = struct{}{}
+	}
+	for name := range toFields {
+		names[name] = struct{}{}
+	}
+	changes := make([]ArticleChange, 0)
+	for name := range names {
+		if !reflect.DeepEqual(fromFields[name], toFields[name]) {
+			changes = append(changes, ArticleChange{Field: name, From: fromFields[name], To: toFields[name]})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Field < changes[j].Field
+	})
+	return changes, nil
+}
+
+// articleFields returns fields of article as they are in its json
+func articleFields(a Article) (map[string]interface{}, error) {
+	data, err := json.Marshal(a)
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]interface{}
+	err = json.Unmarshal(data, &fields)
+	return fields, err
+}
@@ -0,0 +1,47 @@
+package types
+
+import "time"
+
+// Kinds of poller cron jobs
+const (
+	PollerJobList    = "list"
+	PollerJobDetails = "details"
+)
+
+/*
+PollerJobStatus is state of single poller cron job.
+
+LastError is error of the last failed run, it stays set after later successful runs,
+compare LastErrorAt with LastSuccess to tell if the job recovered.
+*/
+type PollerJobStatus struct {
+	TeamId         string      `json:"teamId"`
+	Job            string      `json:"job"`
+	Schedule       string      `json:"schedule"`
+	Running        bool        `json:"running"`
+	LastRun        *time.Time  `json:"lastRun,omitempty"`
+	LastSuccess    *time.Time  `json:"lastSuccess,omitempty"`
+	LastError      string      `json:"lastError,omitempty"`
+	LastErrorAt    *time.Time  `json:"lastErrorAt,omitempty"`
+	NextRun        *time.Time  `json:"nextRun,omitempty"`
+	LastSummary    PollSummary `json:"lastSummary"`
+	ItemsProcessed int         `json:"itemsProcessed"`
+}
+
+// Status is state of the application returned by /status
+type Status struct {
+	PollerJobs []PollerJobStatus `json:"pollerJobs"`
+	// Backfill is progress of historical backfill of every team, when it is enabled
+	Backfill []BackfillProgress `json:"backfill,omitempty"`
+}
+
+// StatusResponse is struct returned by /status
+type StatusResponse struct {
+	Status  string  `json:"status"`
+	Data    *Status `json:"data,omitempty"`
+	Message string  `json:"message,omitempty"`
+}
+
+func (r StatusResponse) GetMessage() string {
+	return r.Message
+}
@@ -0,0 +1,64 @@
+package types
+
+import "time"
+
+/*
+WebhookSubscription is registered url that gets POST with article json
+whenever article is created or gets details.
+
+Secret is used to sign the payload, it is returned only when subscription is created.
+*/
+type WebhookSubscription struct {
+	Id        string    `json:"id"`
+	URL       string    `json:"url"`
+	Secret    string    `json:"secret,omitempty"`
+	TeamId    string    `json:"teamId,omitempty"`
+	Type      string    `json:"type,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// WebhookDelivery is single attempt of sending article to the subscription
+type WebhookDelivery struct {
+	SubscriptionId string    `json:"subscriptionId"`
+	EventId        uint64    `json:"eventId"`
+	Event          string    `json:"event"`
+	ArticleId      ArticleId `json:"articleId"`
+	Attempt        int       `json:"attempt"`
+	StatusCode     int       `json:"statusCode,omitempty"`
+	Error          string    `json:"error,omitempty"`
+	Succeeded      bool      `json:"succeeded"`
+	Time           time.Time `json:"time"`
+}
+
+// WebhookResponse is struct returned by sports news api for single subscription
+type WebhookResponse struct {
+	Status  string               `json:"status"`
+	Data    *WebhookSubscription `json:"data,omitempty"`
+	Message string               `json:"message,omitempty"`
+}
+
+// WebhookList is struct returned by sports news api for list of subscriptions
+type WebhookList struct {
+	Status  string                `json:"status"`
+	Data    []WebhookSubscription `json:"data,omitempty"`
+	Message string                `json:"message,omitempty"`
+}
+
+// WebhookDeliveryList is struct returned by sports news api for delivery log of the subscription
+type WebhookDeliveryList struct {
+	Status  string            `json:"status"`
+	Data    []WebhookDelivery `json:"data,omitempty"`
+	Message string            `json:"message,omitempty"`
+}
+
+func (r WebhookResponse) GetMessage() string {
+	return r.Message
+}
+
+func (r WebhookList) GetMessage() string {
+	return r.Message
+}
+
+func (r WebhookDeliveryList) GetMessage() string {
+	return r.Message
+}